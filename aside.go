@@ -0,0 +1,191 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// defaultAsidePrefix namespaces the keys of values stored by an Aside so that they do
+// not collide with the URLs used as keys by the Transport.
+const defaultAsidePrefix = "aside:"
+
+// Aside stores arbitrary values in a Cache using the cache-aside pattern so that the
+// backend of the HTTP cache can be reused for lookups that are not HTTP requests, e.g.
+// the results of token exchanges or computed aggregates. Values are serialized with
+// encoding/json by default and expire after the TTL. Concurrent calls to Fill for the
+// same key are collapsed so that the value is only computed once.
+//
+// Entries stored by an Aside in a cache that is shared with a versioned Transport are
+// removed by CollectGarbage.
+type Aside[V any] struct {
+	// Cache stores the serialized values.
+	Cache Cache
+
+	// TTL is the duration after which stored values expire. Zero stores values until
+	// they are removed from the cache.
+	TTL time.Duration
+
+	// Prefix is prepended to keys to separate the values from other entries in the
+	// cache. Defaults to "aside:".
+	Prefix string
+
+	// Marshal and Unmarshal serialize the values. Default to json.Marshal and
+	// json.Unmarshal.
+	Marshal   func(v any) ([]byte, error)
+	Unmarshal func(data []byte, v any) error
+
+	// Clock provides the current time used to expire values. If nil, the system time
+	// is used.
+	Clock Clock
+
+	flights flights
+}
+
+// NewAside returns an Aside that stores values in the cache for the TTL.
+func NewAside[V any](cache Cache, ttl time.Duration) *Aside[V] {
+	return &Aside[V]{Cache: cache, TTL: ttl}
+}
+
+// Get returns the value stored for the key if it has not expired. Expired and corrupt
+// entries are removed from the cache and treated as a miss.
+func (a *Aside[V]) Get(key string) (val V, ok bool) {
+	key = a.key(key)
+	data, found := a.Cache.Get(key)
+	if !found {
+		return val, false
+	}
+
+	expires, payload, err := decodeAside(data)
+	if err == nil {
+		if err = a.unmarshal(payload, &val); err != nil {
+			err = fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+		}
+	}
+
+	if err != nil {
+		GetLogger().Warn("removing corrupt cache entry", slog.String("key", key), slog.Any("error", err))
+		a.Cache.Del(key)
+		return val, false
+	}
+
+	if !expires.IsZero() && !a.now().Before(expires) {
+		a.Cache.Del(key)
+		var zero V
+		return zero, false
+	}
+	return val, true
+}
+
+// Put stores the value for the key until the TTL has passed.
+func (a *Aside[V]) Put(key string, val V) error {
+	payload, err := a.marshal(val)
+	if err != nil {
+		return err
+	}
+
+	var expires time.Time
+	if a.TTL > 0 {
+		expires = a.now().Add(a.TTL)
+	}
+
+	a.Cache.Put(a.key(key), encodeAside(expires, payload))
+	return nil
+}
+
+// Del removes the value stored for the key.
+func (a *Aside[V]) Del(key string) {
+	a.Cache.Del(a.key(key))
+}
+
+// Fill returns the value stored for the key or, if there is none, computes it with fill
+// and stores it. Concurrent calls for the same key wait for the first call to compute
+// the value rather than each computing it; if the first call fails, the others compute
+// the value themselves. Errors returned by fill are not cached.
+func (a *Aside[V]) Fill(ctx context.Context, key string, fill func(context.Context) (V, error)) (val V, err error) {
+	if val, ok := a.Get(key); ok {
+		return val, nil
+	}
+
+	call, leader := a.flights.join(key)
+	if !leader {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return val, ctx.Err()
+		}
+
+		if val, ok := a.Get(key); ok {
+			return val, nil
+		}
+	} else {
+		// The value may have been stored by a call that completed after the lookup.
+		defer call.finish()
+		if val, ok := a.Get(key); ok {
+			return val, nil
+		}
+	}
+
+	if val, err = fill(ctx); err != nil {
+		return val, err
+	}
+
+	if err = a.Put(key, val); err != nil {
+		return val, err
+	}
+	return val, nil
+}
+
+func (a *Aside[V]) key(key string) string {
+	if a.Prefix == "" {
+		return defaultAsidePrefix + key
+	}
+	return a.Prefix + key
+}
+
+func (a *Aside[V]) marshal(val V) ([]byte, error) {
+	if a.Marshal != nil {
+		return a.Marshal(val)
+	}
+	return json.Marshal(val)
+}
+
+func (a *Aside[V]) unmarshal(data []byte, val *V) error {
+	if a.Unmarshal != nil {
+		return a.Unmarshal(data, val)
+	}
+	return json.Unmarshal(data, val)
+}
+
+func (a *Aside[V]) now() time.Time {
+	if a.Clock != nil {
+		return a.Clock.Now()
+	}
+	return time.Now()
+}
+
+// asideHeaderSize is the size of the expiration time, in Unix nanoseconds, that
+// precedes the serialized value; zero indicates that the value does not expire.
+const asideHeaderSize = 8
+
+func encodeAside(expires time.Time, payload []byte) []byte {
+	data := make([]byte, asideHeaderSize, asideHeaderSize+len(payload))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(expires.UnixNano()))
+	}
+	return append(data, payload...)
+}
+
+func decodeAside(data []byte) (expires time.Time, payload []byte, err error) {
+	if len(data) < asideHeaderSize {
+		return expires, nil, fmt.Errorf("%w: value is too short", ErrEntryCorrupt)
+	}
+
+	if nsec := int64(binary.BigEndian.Uint64(data)); nsec != 0 {
+		expires = time.Unix(0, nsec)
+	}
+	return expires, data[asideHeaderSize:], nil
+}
@@ -0,0 +1,101 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+type token struct {
+	Value   string `json:"value"`
+	Expires int64  `json:"expires"`
+}
+
+func TestAside(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	clock := &fakeClock{now: time.Now()}
+	aside := httpcache.NewAside[token](cache, time.Minute)
+	aside.Clock = clock
+
+	_, ok := aside.Get("alice")
+	require.False(t, ok)
+
+	require.NoError(t, aside.Put("alice", token{Value: "secret", Expires: 42}))
+	val, ok := aside.Get("alice")
+	require.True(t, ok)
+	require.Equal(t, token{Value: "secret", Expires: 42}, val)
+
+	_, ok = cache.Get("alice")
+	require.False(t, ok, "expected keys to be prefixed")
+
+	clock.Advance(time.Minute)
+	_, ok = aside.Get("alice")
+	require.False(t, ok, "expected the value to expire")
+	_, ok = cache.Get("aside:alice")
+	require.False(t, ok, "expected the expired value to be removed")
+
+	t.Run("Corrupt", func(t *testing.T) {
+		cache.Put("aside:corrupt", []byte("bad"))
+		_, ok := aside.Get("corrupt")
+		require.False(t, ok)
+		_, ok = cache.Get("aside:corrupt")
+		require.False(t, ok, "expected the corrupt value to be removed")
+	})
+
+	t.Run("Del", func(t *testing.T) {
+		require.NoError(t, aside.Put("bob", token{Value: "b"}))
+		aside.Del("bob")
+		_, ok := aside.Get("bob")
+		require.False(t, ok)
+	})
+}
+
+func TestAsideFill(t *testing.T) {
+	aside := httpcache.NewAside[int](&httpcache.InMemoryCache{}, 0)
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	fill := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	vals := make([]int, 8)
+	for i := range vals {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := aside.Fill(context.Background(), "answer", fill)
+			require.NoError(t, err)
+			vals[i] = val
+		}(i)
+	}
+
+	// Wait for the first call to start computing the value before releasing it.
+	require.Eventually(t, func() bool { return calls.Load() > 0 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int64(1), calls.Load(), "expected concurrent fills to be collapsed")
+	for _, val := range vals {
+		require.Equal(t, 42, val)
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		failure := errors.New("token exchange failed")
+		_, err := aside.Fill(context.Background(), "failed", func(context.Context) (int, error) { return 0, failure })
+		require.ErrorIs(t, err, failure)
+
+		val, err := aside.Fill(context.Background(), "failed", func(context.Context) (int, error) { return 7, nil })
+		require.NoError(t, err)
+		require.Equal(t, 7, val, "expected errors not to be cached")
+	})
+}
@@ -0,0 +1,183 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+const defaultMaxBackgroundTasks = 8
+
+// BackpressurePolicy determines what happens to background work, such as revalidating
+// stale-while-revalidate responses, when the maximum number of background tasks are
+// already running.
+type BackpressurePolicy uint8
+
+const (
+	// BackpressureDrop skips the work; e.g. a stale response is served without being
+	// revalidated. This is the default policy.
+	BackpressureDrop BackpressurePolicy = iota
+
+	// BackpressureBlock waits until a running task completes before starting the work
+	// in the background, delaying the caller.
+	BackpressureBlock
+
+	// BackpressureInline performs the work synchronously in the caller's goroutine;
+	// e.g. a stale response is revalidated before it is served.
+	BackpressureInline
+)
+
+// background tracks tasks that are running in the background so that the number of
+// concurrent tasks is bounded and the work for each key is performed only once.
+type background struct {
+	once     sync.Once
+	mu       sync.Mutex
+	sem      chan struct{}
+	inflight map[string]struct{}
+}
+
+// goBackground runs the task in a background goroutine unless a task for the same key
+// is already in progress. If the maximum number of background tasks are running the
+// backpressure policy is applied; inline is true if the caller must perform the work.
+func (t *Transport) goBackground(ctx context.Context, key string, task func()) (inline bool) {
+	bg := &t.background
+	bg.once.Do(func() {
+		limit := t.MaxBackgroundTasks
+		if limit <= 0 {
+			limit = defaultMaxBackgroundTasks
+		}
+		bg.sem = make(chan struct{}, limit)
+		bg.inflight = make(map[string]struct{})
+	})
+
+	bg.mu.Lock()
+	if _, ok := bg.inflight[key]; ok {
+		bg.mu.Unlock()
+		return false
+	}
+	bg.inflight[key] = struct{}{}
+	bg.mu.Unlock()
+
+	done := func() {
+		bg.mu.Lock()
+		delete(bg.inflight, key)
+		bg.mu.Unlock()
+	}
+
+	select {
+	case bg.sem <- struct{}{}:
+	default:
+		switch t.BackgroundPolicy {
+		case BackpressureBlock:
+			select {
+			case bg.sem <- struct{}{}:
+			case <-ctx.Done():
+				done()
+				return false
+			}
+		case BackpressureInline:
+			done()
+			return true
+		default:
+			GetLogger().Debug("background task skipped: too many in progress", slog.String("key", key))
+			t.failOpen.add(FailOpenBackpressure)
+			done()
+			return false
+		}
+	}
+
+	t.tasks.start()
+	go func() {
+		defer func() {
+			done()
+			<-bg.sem
+			t.tasks.done()
+		}()
+		task()
+	}()
+	return false
+}
+
+// revalidate starts a background revalidation of the stored response for the key, or
+// schedules it for batch revalidation if a BatchRevalidator is configured. It returns
+// true if the revalidation must instead be performed by the caller.
+func (t *Transport) revalidate(key string, req *http.Request, stored http.Header) (inline bool) {
+	// The revalidation must not be canceled when the caller's request completes.
+	bgreq := req.Clone(context.WithoutCancel(req.Context()))
+	if t.BatchRevalidator != nil && hasValidators(stored) {
+		header := stored.Clone()
+		removeInternalHeaders(header)
+		t.persistRevalidation(key, bgreq, stored, t.now())
+		t.scheduleBatch(StaleEntry{Key: key, Request: bgreq, Header: header})
+		return false
+	}
+
+	t.persistRevalidation(key, bgreq, stored, t.now())
+	inline = t.goBackground(req.Context(), key, func() {
+		t.refresh(key, bgreq)
+		t.completeRevalidations(key)
+	})
+	if inline {
+		t.completeRevalidations(key)
+	}
+	return inline
+}
+
+// refresh revalidates or refetches the stored response for the key and updates the
+// cache with the result.
+func (t *Transport) refresh(key string, req *http.Request) {
+	policy := t.policy(req)
+	primary := policy.key(req)
+	_, cached, _ := t.lookup(primary, req, policy)
+	if cached == nil {
+		return
+	}
+	defer cached.Body.Close()
+
+	outreq := conditionalRequest(req, cached.Header)
+	if outreq == nil {
+		outreq = req
+	}
+
+	if _, err := t.limit(req, policy, false); err != nil {
+		return
+	}
+
+	requestTime := t.now()
+	rep, err := t.forward(outreq)
+	if err != nil {
+		GetLogger().Warn("background revalidation failed", slog.String("key", key), slog.Any("error", err))
+		t.failOpen.add(FailOpenRevalidation)
+		return
+	}
+	defer rep.Body.Close()
+	responseTime := t.now()
+
+	if rep.StatusCode == http.StatusNotModified {
+		body, err := io.ReadAll(cached.Body)
+		if err != nil {
+			return
+		}
+
+		updateHeaders(cached.Header, rep.Header)
+		t.store(primary, req, cached, body, policy, requestTime, responseTime)
+		return
+	}
+
+	if parseCacheControl(rep.Header).has("no-store") {
+		t.remove(policy.cache, key)
+		return
+	}
+
+	if storable(req, rep, policy) {
+		body, err := io.ReadAll(rep.Body)
+		if err != nil {
+			GetLogger().Warn("background revalidation failed", slog.String("key", key), slog.Any("error", err))
+			t.failOpen.add(FailOpenRevalidation)
+			return
+		}
+		t.store(primary, req, rep, body, policy, requestTime, responseTime)
+	}
+}
@@ -0,0 +1,124 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// backpressureOrigin serves stale-while-revalidate responses; revalidations of /slow
+// block until the release channel is closed.
+func backpressureOrigin(t *testing.T, release chan struct{}) *Origin {
+	var warmed atomic.Bool
+	return NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.URL.Path == "/slow" && warmed.Swap(true) {
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+}
+
+func TestBackpressurePolicy(t *testing.T) {
+	setup := func(t *testing.T, policy httpcache.BackpressurePolicy) (*Origin, *http.Client, chan struct{}) {
+		release := make(chan struct{})
+		origin := backpressureOrigin(t, release)
+		transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, MaxBackgroundTasks: 1, BackgroundPolicy: policy}
+		client := transport.Client()
+
+		// Warm both entries then occupy the only background slot.
+		Get(t, client, origin.URL+"/slow")
+		Get(t, client, origin.URL+"/fast")
+		Get(t, client, origin.URL+"/slow")
+		require.Eventually(t, func() bool { return origin.Requests() == 3 }, time.Second, time.Millisecond)
+		return origin, client, release
+	}
+
+	t.Run("Drop", func(t *testing.T) {
+		origin, client, release := setup(t, httpcache.BackpressureDrop)
+		defer close(release)
+
+		_, body := Get(t, client, origin.URL+"/fast")
+		require.Equal(t, "response 2", body)
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, int64(3), origin.Requests())
+	})
+
+	t.Run("Inline", func(t *testing.T) {
+		origin, client, release := setup(t, httpcache.BackpressureInline)
+		defer close(release)
+
+		_, body := Get(t, client, origin.URL+"/fast")
+		require.Equal(t, "response 4", body)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		origin, client, release := setup(t, httpcache.BackpressureBlock)
+
+		done := make(chan string)
+		go func() {
+			rep, err := client.Get(origin.URL + "/fast")
+			if err == nil {
+				rep.Body.Close()
+			}
+			done <- rep.Header.Get("Age")
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("request should block while the background slot is occupied")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(release)
+		require.NotEmpty(t, <-done, "expected the stale response to be served")
+		require.Eventually(t, func() bool { return origin.Requests() == 4 }, time.Second, time.Millisecond)
+	})
+}
+
+func TestPersistRevalidations(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	defer origin.Close()
+
+	// The first transport never completes its revalidation, as if the process exited.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	var calls atomic.Int64
+	cache := &httpcache.InMemoryCache{}
+	first := httpcache.NewTransport(cache,
+		httpcache.WithPersistentRevalidations(),
+		httpcache.WithTransport(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if calls.Add(1) > 1 {
+				<-stop
+				return nil, errors.New("stopped")
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		})),
+	)
+
+	client := first.Client()
+	Get(t, client, origin.URL)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	// A new transport using the same cache resumes the pending revalidation.
+	second := httpcache.NewTransport(cache, httpcache.WithPersistentRevalidations())
+	require.NoError(t, second.Flush(context.Background()))
+	require.Equal(t, int64(2), origin.Requests())
+	require.Zero(t, second.ResumeRevalidations(), "expected the revalidation to be completed")
+
+	_, body = Get(t, second.Client(), origin.URL)
+	require.Equal(t, "response 2", body)
+}
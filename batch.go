@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize  = 100
+	defaultBatchDelay = 100 * time.Millisecond
+)
+
+// BatchRevalidator revalidates many stale stored responses with a single request to an
+// origin that supports batch validation, e.g. an endpoint that accepts a list of entity
+// tags and reports which of them are still current.
+type BatchRevalidator interface {
+	// RevalidateBatch validates the stale entries with the origin and returns a result
+	// for each entry that it validated. Entries without a result are left unchanged and
+	// are revalidated again when they are next served stale.
+	RevalidateBatch(ctx context.Context, entries []StaleEntry) ([]BatchResult, error)
+}
+
+// StaleEntry is a stale stored response that is scheduled for batch revalidation.
+type StaleEntry struct {
+	// Key is the key the response is stored at.
+	Key string
+
+	// Request is the request that the stale response was served to.
+	Request *http.Request
+
+	// Header is the header of the stored response, including its validators.
+	Header http.Header
+}
+
+// BatchResult is the outcome of the revalidation of a StaleEntry.
+type BatchResult struct {
+	// Key is the key of the entry that was revalidated.
+	Key string
+
+	// NotModified is true if the stored response is still current. Its header is
+	// updated with the Header fields, e.g. Cache-Control and Date, as if the origin had
+	// responded with 304 Not Modified, and its freshness is reset. Otherwise the stored
+	// response is removed so that it is fetched again when it is next requested.
+	NotModified bool
+	Header      http.Header
+}
+
+// batches collects stale entries until a batch is full or the batch delay has passed.
+type batches struct {
+	mu        sync.Mutex
+	pending   []StaleEntry
+	scheduled map[string]struct{}
+	timer     *time.Timer
+}
+
+// scheduleBatch adds the stale entry to the next batch unless it is already scheduled.
+func (t *Transport) scheduleBatch(entry StaleEntry) {
+	b := &t.batches
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.scheduled[entry.Key]; ok {
+		return
+	}
+
+	if b.scheduled == nil {
+		b.scheduled = make(map[string]struct{})
+	}
+	b.scheduled[entry.Key] = struct{}{}
+	b.pending = append(b.pending, entry)
+
+	size := t.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	if len(b.pending) >= size {
+		t.sendBatch()
+		return
+	}
+
+	if b.timer == nil {
+		delay := t.BatchDelay
+		if delay <= 0 {
+			delay = defaultBatchDelay
+		}
+		b.timer = time.AfterFunc(delay, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			t.sendBatch()
+		})
+	}
+}
+
+// sendBatch revalidates the pending entries in the background; the lock must be held by
+// the caller.
+func (t *Transport) sendBatch() {
+	b := &t.batches
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return
+	}
+
+	entries := b.pending
+	b.pending = nil
+	t.tasks.start()
+	go func() {
+		defer t.tasks.done()
+		t.revalidateBatch(entries)
+	}()
+}
+
+// revalidateBatch revalidates the entries with the BatchRevalidator and updates the
+// cache with the results.
+func (t *Transport) revalidateBatch(entries []StaleEntry) {
+	defer func() {
+		keys := make([]string, 0, len(entries))
+		t.batches.mu.Lock()
+		for _, entry := range entries {
+			delete(t.batches.scheduled, entry.Key)
+			keys = append(keys, entry.Key)
+		}
+		t.batches.mu.Unlock()
+		t.completeRevalidations(keys...)
+	}()
+
+	requestTime := t.now()
+	results, err := t.BatchRevalidator.RevalidateBatch(context.Background(), entries)
+	if err != nil {
+		GetLogger().Warn("batch revalidation failed", slog.Int("entries", len(entries)), slog.Any("error", err))
+		t.failOpen.add(FailOpenRevalidation)
+		return
+	}
+	responseTime := t.now()
+
+	index := make(map[string]StaleEntry, len(entries))
+	for _, entry := range entries {
+		index[entry.Key] = entry
+	}
+
+	for _, result := range results {
+		if entry, ok := index[result.Key]; ok {
+			t.batchRevalidated(entry, result, requestTime, responseTime)
+		}
+	}
+}
+
+// batchRevalidated updates the stored response of the entry with the result.
+func (t *Transport) batchRevalidated(entry StaleEntry, result BatchResult, requestTime, responseTime time.Time) {
+	policy := t.policy(entry.Request)
+	if !result.NotModified {
+		t.remove(policy.cache, entry.Key)
+		return
+	}
+
+	primary := policy.key(entry.Request)
+	key, cached, _ := t.lookup(primary, entry.Request, policy)
+	if cached == nil || key != entry.Key {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return
+	}
+	defer cached.Body.Close()
+
+	body, err := io.ReadAll(cached.Body)
+	if err != nil {
+		return
+	}
+
+	updateHeaders(cached.Header, result.Header)
+	t.store(primary, entry.Request, cached, body, policy, requestTime, responseTime)
+}
@@ -0,0 +1,122 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// batchRevalidator records the batches it receives and reports the entries with the
+// current entity tag as not modified.
+type batchRevalidator struct {
+	mu      sync.Mutex
+	current string
+	err     error
+	batches [][]httpcache.StaleEntry
+}
+
+func (b *batchRevalidator) RevalidateBatch(ctx context.Context, entries []httpcache.StaleEntry) ([]httpcache.BatchResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, entries)
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	results := make([]httpcache.BatchResult, 0, len(entries))
+	for _, entry := range entries {
+		result := httpcache.BatchResult{Key: entry.Key}
+		if entry.Header.Get("ETag") == b.current {
+			result.NotModified = true
+			result.Header = http.Header{"Cache-Control": {"max-age=60, stale-while-revalidate=3600"}}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (b *batchRevalidator) Batches() [][]httpcache.StaleEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batches
+}
+
+func TestTransportBatchRevalidator(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=3600")
+		if r.URL.Path != "/unvalidated" {
+			w.Header().Set("ETag", fmt.Sprintf(`"%s"`, r.URL.Path[1:]))
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	revalidator := &batchRevalidator{current: `"current"`}
+	transport := &httpcache.Transport{
+		Cache:            &httpcache.InMemoryCache{},
+		Clock:            clock,
+		BatchRevalidator: revalidator,
+		BatchDelay:       10 * time.Millisecond,
+	}
+	client := transport.Client()
+
+	paths := []string{"/current", "/changed", "/unvalidated"}
+	for _, path := range paths {
+		Get(t, client, origin.URL+path)
+	}
+	clock.Advance(2 * time.Second)
+
+	// Stale responses are served while they are revalidated in a single batch.
+	for _, path := range paths {
+		Get(t, client, origin.URL+path)
+	}
+	require.Eventually(t, func() bool { return len(revalidator.Batches()) == 1 }, time.Second, time.Millisecond)
+
+	batch := revalidator.Batches()[0]
+	require.Len(t, batch, 2, "expected only entries with validators to be batched")
+	require.Empty(t, batch[0].Header.Get("X-Httpcache-Key"), "expected internal headers to be removed")
+
+	// The responses without validators are revalidated individually.
+	require.Eventually(t, func() bool { return origin.Requests() == 4 }, time.Second, time.Millisecond)
+
+	// The current response is fresh again and the changed response was removed.
+	require.Eventually(t, func() bool {
+		before := origin.Requests()
+		_, body := Get(t, client, origin.URL+"/current")
+		return body == "response 1" && origin.Requests() == before
+	}, time.Second, time.Millisecond)
+
+	before := origin.Requests()
+	Get(t, client, origin.URL+"/changed")
+	require.Equal(t, before+1, origin.Requests())
+
+	t.Run("Size", func(t *testing.T) {
+		transport.BatchSize = 1
+		transport.BatchDelay = time.Hour
+		clock.Advance(2 * time.Minute)
+		Get(t, client, origin.URL+"/current")
+		require.Eventually(t, func() bool { return len(revalidator.Batches()) == 2 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		revalidator.mu.Lock()
+		revalidator.err = errors.New("batch endpoint unavailable")
+		revalidator.mu.Unlock()
+
+		clock.Advance(2 * time.Minute)
+		stats := transport.Stats()
+		_, body := Get(t, client, origin.URL+"/current")
+		require.Equal(t, "response 1", body)
+		require.Eventually(t, func() bool {
+			return transport.Stats().FailOpen[httpcache.FailOpenRevalidation] > stats.FailOpen[httpcache.FailOpenRevalidation]
+		}, time.Second, time.Millisecond)
+	})
+}
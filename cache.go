@@ -3,12 +3,19 @@ package httpcache
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 )
 
 // Cache implements the basic mechanism to store and retrieve responses.
+//
+// Values are shared between the cache and its callers rather than copied: a value
+// passed to Put belongs to the cache and must not be modified by the caller afterwards,
+// and a value returned by Get must not be modified by the caller since the cache may
+// retain it. The Transport never modifies these values. Callers that cannot guarantee
+// this, or backends that reuse their buffers, should be wrapped with CopyValues.
 type Cache interface {
 	// Get returns the []byte representation of a cached response and a boolean
 	// indicating whether the response was found in the cache.
@@ -23,27 +30,40 @@ type Cache interface {
 
 // CachedResponse returns the cached http.Response for the request if present and nil
 // otherwise. Used to quickly create a client-side response from the cache.
+// If the cached value cannot be parsed, an error wrapping ErrEntryCorrupt is returned.
 func CachedResponse(cache Cache, req *http.Request) (rep *http.Response, err error) {
-	val, ok := cache.Get(cacheKey(req))
-	if !ok {
-		return nil, nil
-	}
-
-	buf := bytes.NewBuffer(val)
-	return http.ReadResponse(bufio.NewReader(buf), req)
+	return cachedResponse(cache, cacheKey(req), req)
 }
 
 // cachedResponse is an internal function that creates an http.Response from a cached
 // value as returned by the specified key. Used internally by the Transport to handle
 // headers and vary keys.
 func cachedResponse(cache Cache, key string, req *http.Request) (rep *http.Response, err error) {
+	_, rep, err = cachedVariant(cache, key, req)
+	return rep, err
+}
+
+// cachedVariant returns the cached http.Response for the key and the key that it is
+// stored at. If the stored responses vary, a vary index is stored at the key and the
+// response is looked up with the vary key of the request.
+func cachedVariant(cache Cache, key string, req *http.Request) (_ string, rep *http.Response, err error) {
 	val, ok := cache.Get(key)
 	if !ok {
-		return nil, nil
+		return key, nil, nil
+	}
+
+	if vary, _, isIndex := parseVaryIndex(val); isIndex {
+		key = varyKey(key, req, vary)
+		if val, ok = cache.Get(key); !ok {
+			return key, nil, nil
+		}
 	}
 
 	buf := bytes.NewBuffer(val)
-	return http.ReadResponse(bufio.NewReader(buf), req)
+	if rep, err = http.ReadResponse(bufio.NewReader(buf), req); err != nil {
+		return key, nil, fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+	return key, rep, nil
 }
 
 // cacheKey returns the cache key for the given request.
@@ -86,8 +106,11 @@ func cacheKeyWithHeaders(req *http.Request, headers []string) string {
 // the cached response. This implements RFC 9111 vary seperation. Header values are
 // normalized before inclusion in the cache key.
 func cacheKeyWithVary(req *http.Request, varyHeaders []string) string {
-	key := cacheKey(req)
+	return varyKey(cacheKey(req), req, varyHeaders)
+}
 
+// varyKey extends the key with the normalized values of the vary headers of the request.
+func varyKey(key string, req *http.Request, varyHeaders []string) string {
 	if len(varyHeaders) == 0 {
 		return key
 	}
@@ -0,0 +1,54 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"testing"
+
+	"go.rtnl.ai/httpcache"
+)
+
+func BenchmarkNormalize(b *testing.B) {
+	for _, value := range []string{"application/json", "gzip, deflate, br", "  en-US,\ten;q=0.9 "} {
+		b.Run(value, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				httpcache.Normalize(value)
+			}
+		})
+	}
+}
+
+func BenchmarkCacheKey(b *testing.B) {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/v1/items?page=2", nil)
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Accept", "application/json")
+
+	b.Run("NoHeaders", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			httpcache.CacheKey(req)
+		}
+	})
+
+	b.Run("Headers", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			httpcache.CacheKeyWithHeaders(req, []string{"X-Tenant", "Accept"})
+		}
+	})
+
+	b.Run("Vary", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			httpcache.CacheKeyWithVary(req, []string{"Accept"})
+		}
+	})
+
+	b.Run("Transport", func(b *testing.B) {
+		transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			transport.Key(req)
+		}
+	})
+}
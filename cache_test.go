@@ -1,7 +1,12 @@
 package httpcache_test
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.rtnl.ai/httpcache"
@@ -178,3 +183,138 @@ func TestCacheKeyWithVary(t *testing.T) {
 		require.Equal(t, test.expected, result, "Test Case: %q", test.name)
 	}
 }
+
+func TestCachedResponse(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	req := (&TestRequest{url: "http://example.com/resource"}).HTTP()
+
+	t.Run("Miss", func(t *testing.T) {
+		rep, err := httpcache.CachedResponse(cache, req)
+		require.NoError(t, err)
+		require.Nil(t, rep)
+	})
+
+	t.Run("Hit", func(t *testing.T) {
+		cache.Put("http://example.com/resource", []byte("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello"))
+		rep, err := httpcache.CachedResponse(cache, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+
+		body, err := io.ReadAll(rep.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello"), body)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		cache.Put("http://example.com/resource", []byte("not a response"))
+		rep, err := httpcache.CachedResponse(cache, req)
+		require.ErrorIs(t, err, httpcache.ErrEntryCorrupt)
+		require.Nil(t, rep)
+	})
+}
+
+func TestKeyAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not counted reliably with the race detector")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com/v1/items?page=2", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+
+	// Without key headers, building the key only allocates to format the URL.
+	url := testing.AllocsPerRun(100, func() { _ = req.URL.String() })
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	require.Equal(t, url, testing.AllocsPerRun(100, func() { transport.Key(req) }))
+	require.Equal(t, url, testing.AllocsPerRun(100, func() { httpcache.CacheKeyWithHeaders(req, nil) }))
+
+	// Header values that are already normalized are not copied.
+	require.Zero(t, testing.AllocsPerRun(100, func() { httpcache.Normalize("application/json") }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { httpcache.Normalize("gzip,deflate,br") }))
+}
+
+func TestTransportCacheSelector(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tokens, catalog := &httpcache.InMemoryCache{}, &httpcache.InMemoryCache{}
+	transport := httpcache.NewTransport(catalog, httpcache.WithCacheSelector(func(req *http.Request) httpcache.Cache {
+		if strings.HasPrefix(req.URL.Path, "/tokens/") {
+			return tokens
+		}
+		return nil
+	}))
+	client := transport.Client()
+
+	Get(t, client, origin.URL+"/tokens/a")
+	Get(t, client, origin.URL+"/items/a")
+	_, ok := tokens.Get(origin.URL + "/tokens/a")
+	require.True(t, ok, "expected the selected cache to store the response")
+	_, ok = catalog.Get(origin.URL + "/tokens/a")
+	require.False(t, ok)
+	_, ok = catalog.Get(origin.URL + "/items/a")
+	require.True(t, ok, "expected Cache to be used if no cache is selected")
+
+	// Responses are served from the selected cache.
+	_, body := Get(t, client, origin.URL+"/tokens/a")
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Invalidation removes the responses from the selected cache.
+	require.NoError(t, transport.Invalidate(origin.URL+"/tokens/a"))
+	require.Zero(t, tokens.Len())
+
+	// A selector can enable caching for a Transport without a Cache.
+	transport = httpcache.NewTransport(nil, httpcache.WithCacheSelector(func(req *http.Request) httpcache.Cache { return tokens }))
+	Get(t, transport.Client(), origin.URL+"/tokens/b")
+	_, ok = tokens.Get(origin.URL + "/tokens/b")
+	require.True(t, ok)
+}
+
+func TestTransportIgnoredQueryParams(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	clock := &fakeClock{now: time.Now()}
+	client := httpcache.NewTransport(cache, httpcache.WithClock(clock), httpcache.WithIgnoredQueryParams("v", "_")).Client()
+
+	testCases := []struct {
+		query string
+		body  string
+	}{
+		{"?v=1", "response 1"},
+		{"?v=2", "response 1"},
+		{"?_=1700000000&v=3", "response 1"},
+		{"", "response 1"},
+		{"?page=2&v=1", "response 2"},
+		{"?v=2&page=2", "response 2"},
+		{"?page=3", "response 3"},
+	}
+
+	for _, tc := range testCases {
+		rep, body := Get(t, client, origin.URL+"/app.js"+tc.query)
+		require.Equal(t, tc.body, body, "Test Case: %q", tc.query)
+		require.Empty(t, rep.Header.Get("X-Httpcache-Url"), "Test Case: %q", tc.query)
+	}
+
+	// The entry records the full URL of the request that stored it.
+	val, ok := cache.Get(origin.URL + "/app.js")
+	require.True(t, ok)
+	meta, err := httpcache.ParseMetadata(val)
+	require.NoError(t, err)
+	require.Equal(t, origin.URL+"/app.js?v=1", meta.URL)
+
+	// The URL is updated when the response is stored again.
+	clock.Advance(2 * time.Hour)
+	_, body := Get(t, client, origin.URL+"/app.js?v=4")
+	require.Equal(t, "response 4", body)
+	val, _ = cache.Get(origin.URL + "/app.js")
+	meta, err = httpcache.ParseMetadata(val)
+	require.NoError(t, err)
+	require.Equal(t, origin.URL+"/app.js?v=4", meta.URL)
+}
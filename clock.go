@@ -0,0 +1,19 @@
+package httpcache
+
+import "time"
+
+// Clock provides the current time to the Transport for all freshness and age
+// calculations, so that tests can advance time deterministically and embedded systems
+// can supply their own source of time.
+type Clock interface {
+	Now() time.Time
+}
+
+// now returns the current time of the Transport's clock or the system time if the
+// Transport does not have a clock.
+func (t *Transport) now() time.Time {
+	if t.Clock != nil {
+		return t.Clock.Now()
+	}
+	return time.Now()
+}
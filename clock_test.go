@@ -0,0 +1,54 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// fakeClock is a Clock that only advances when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestTransportClock(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Clock = clock
+	client := transport.Client()
+
+	Get(t, client, origin.URL)
+
+	clock.Advance(59 * time.Second)
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "59", rep.Header.Get("Age"))
+
+	clock.Advance(2 * time.Second)
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 2", body)
+	require.Equal(t, int64(2), origin.Requests())
+}
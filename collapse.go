@@ -0,0 +1,170 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// flights tracks the cache misses that are being fetched from the origin so that
+// concurrent identical misses wait for a single fetch rather than each making a request.
+type flights struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a fetch from the origin that other callers can wait for. If the response
+// can be shared, its body is streamed to the waiting callers as it is received.
+type flight struct {
+	done   chan struct{}
+	ready  chan struct{}
+	finish func()
+
+	// req, vary, rep and tee are only set once ready is closed.
+	req  *http.Request
+	vary []string
+	rep  *http.Response
+	tee  *tee
+}
+
+// join registers a fetch for the key. If no fetch is in progress the caller is the
+// leader and must call finish once the response has been stored or is known not to be
+// stored; otherwise the caller waits for the returned flight.
+func (f *flights) join(key string) (call *flight, leader bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if call, ok := f.calls[key]; ok {
+		return call, false
+	}
+
+	if f.calls == nil {
+		f.calls = make(map[string]*flight)
+	}
+
+	call = &flight{done: make(chan struct{}), ready: make(chan struct{})}
+	f.calls[key] = call
+
+	var once sync.Once
+	call.finish = func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+			close(call.done)
+		})
+	}
+	return call, true
+}
+
+// stream arranges for finish to be called once the leader's response to the request
+// has been stored. If the response may be shared, its body is teed so that waiting
+// callers whose requests select the same variant receive it as it arrives from the
+// origin rather than after it has been stored. At most limit bytes of the body are
+// buffered for the waiting callers (see tee).
+func (f *flight) stream(req *http.Request, rep *http.Response, share bool, limit int64) {
+	if !share || rep.Body == nil || rep.Body == http.NoBody {
+		rep.Body = leaderBody(rep.Body, f.finish)
+		return
+	}
+
+	snapshot := *rep
+	snapshot.Header = rep.Header.Clone()
+	f.req, f.vary = req, varyHeaders(rep.Header)
+	f.rep, f.tee = &snapshot, newTee(rep.Body, limit, f.finish)
+	rep.Body = f.tee.attach()
+	close(f.ready)
+	go f.tee.pump()
+}
+
+// response returns a copy of the leader's response with a body that is streamed from
+// the tee, or nil if the response varies on request headers whose values differ from
+// those of the leader's request or if the tee no longer streams the whole body.
+func (f *flight) response(req *http.Request) *http.Response {
+	if len(f.vary) > 0 && varyKey("", req, f.vary) != varyKey("", f.req, f.vary) {
+		return nil
+	}
+
+	body := f.tee.attach()
+	if body == nil {
+		return nil
+	}
+
+	rep := new(http.Response)
+	*rep = *f.rep
+	rep.Header = f.rep.Header.Clone()
+	rep.Body = body
+	rep.Request = req
+	return rep
+}
+
+// collapse waits for a concurrent fetch of the same cache miss. If the fetched response
+// may be shared and matches the request, it is streamed to the caller as it arrives;
+// otherwise once the fetch completes the response it stored is looked up, following
+// the vary index, and served if possible. If neither is
+// possible the caller must fetch the response itself. If no fetch is in progress the
+// caller becomes the leader and must call stream on the returned flight with the
+// response or call finish on failure.
+func (t *Transport) collapse(key, primary string, req *http.Request, policy policy, reqcc cacheControl) (rep *http.Response, leader *flight, err error) {
+	call, isLeader := t.flights.join(key)
+	if isLeader {
+		return nil, call, nil
+	}
+
+	select {
+	case <-call.ready:
+		if rep = call.response(req); rep != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return t.annotate(rep, CacheMiss), nil, nil
+		}
+		<-call.done
+	case <-call.done:
+	case <-req.Context().Done():
+		return nil, nil, req.Context().Err()
+	}
+
+	_, cached, freshness := t.lookup(primary, req, policy)
+	if cached == nil {
+		return nil, nil, nil
+	}
+
+	freshness.request(reqcc)
+	if !freshness.fresh() {
+		cached.Body.Close()
+		return nil, nil, nil
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return t.annotate(serve(cached, freshness), CacheHit), nil, nil
+}
+
+// leaderBody wraps the body of the leader's response so that done is called once the
+// body has been completely read or closed, i.e. once the response has been stored.
+func leaderBody(body io.ReadCloser, done func()) io.ReadCloser {
+	if body == nil || body == http.NoBody {
+		done()
+		return body
+	}
+	return &doneReadCloser{ReadCloser: body, done: done}
+}
+
+type doneReadCloser struct {
+	io.ReadCloser
+	done func()
+}
+
+func (r *doneReadCloser) Read(p []byte) (n int, err error) {
+	if n, err = r.ReadCloser.Read(p); err != nil {
+		r.done()
+	}
+	return n, err
+}
+
+func (r *doneReadCloser) Close() error {
+	defer r.done()
+	return r.ReadCloser.Close()
+}
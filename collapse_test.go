@@ -0,0 +1,177 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportCollapseRequests(t *testing.T) {
+	release := make(chan struct{})
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		<-release
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.CollapseRequests = true
+	client := transport.Client()
+
+	fetch := func(path string, n int) []string {
+		var (
+			wg     sync.WaitGroup
+			bodies = make([]string, n)
+		)
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, bodies[i] = Get(t, client, origin.URL+path)
+			}()
+		}
+
+		// Allow the requests to queue behind the first fetch before it completes.
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		return bodies
+	}
+
+	// Concurrent misses are served by a single fetch.
+	for _, body := range fetch("/", 8) {
+		require.Equal(t, "response 1", body)
+	}
+	require.Equal(t, int64(1), origin.Requests())
+
+	// Waiting requests are forwarded to the origin if the response is not stored.
+	release = make(chan struct{})
+	require.Len(t, fetch("/private", 4), 4)
+	require.Equal(t, int64(5), origin.Requests())
+}
+
+func TestTransportCollapseStreaming(t *testing.T) {
+	release := make(chan struct{})
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprint(w, "first ")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "second")
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithRequestCollapsing())
+	client := transport.Client()
+
+	leader, err := client.Get(origin.URL)
+	require.NoError(t, err)
+
+	// The waiting request receives the body as it arrives rather than once it is stored.
+	follower, err := client.Get(origin.URL)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), origin.Requests())
+
+	first := make([]byte, 6)
+	_, err = io.ReadFull(follower.Body, first)
+	require.NoError(t, err)
+	require.Equal(t, "first ", string(first))
+
+	// The body is streamed to the waiting request even if the leader stops reading.
+	require.NoError(t, leader.Body.Close())
+	close(release)
+
+	rest, err := io.ReadAll(follower.Body)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(rest))
+	require.NoError(t, follower.Body.Close())
+
+	// The response is stored before the end of the body is streamed.
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "first second", body)
+	require.Equal(t, int64(1), origin.Requests(), "expected the streamed response to be stored")
+}
+
+func TestTransportCollapseVary(t *testing.T) {
+	release := make(chan struct{})
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		<-release
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept")
+		fmt.Fprintf(w, "%s response %d", r.Header.Get("Accept"), n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithRequestCollapsing())
+	client := transport.Client()
+
+	var (
+		wg      sync.WaitGroup
+		accepts = []string{"json", "xml", "json", "xml"}
+		bodies  = make([]string, len(accepts))
+	)
+	for i, accept := range accepts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, bodies[i] = Get(t, client, origin.URL, "Accept", accept)
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Waiting requests only share the leader's response if they select its variant.
+	for i, accept := range accepts {
+		require.Contains(t, bodies[i], accept+" response", "Test Case: %q", accept)
+	}
+}
+
+func TestTeeLimit(t *testing.T) {
+	const limit = 64 << 10
+	data := strings.Repeat("0123456789abcdef", 32<<10)
+
+	done := make(chan struct{})
+	tee := httpcache.NewTee(io.NopCloser(strings.NewReader(data)), limit, func() { close(done) })
+	first, second := tee.Attach(), tee.Attach()
+	go tee.Pump()
+
+	// Both readers receive the whole body although it is larger than the limit.
+	var wg sync.WaitGroup
+	for _, reader := range []io.ReadCloser{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+
+			var body strings.Builder
+			buf := make([]byte, 4096)
+			for {
+				n, err := reader.Read(buf)
+				body.Write(buf[:n])
+				require.LessOrEqual(t, tee.Buffered(), limit+32<<10, "expected the buffer to be bounded")
+				if err != nil {
+					require.ErrorIs(t, err, io.EOF)
+					break
+				}
+			}
+			require.Equal(t, len(data), body.Len())
+			require.Equal(t, data, body.String())
+		}()
+	}
+	wg.Wait()
+	<-done
+
+	// Readers cannot be attached once the beginning of the body has been discarded.
+	require.Nil(t, tee.Attach())
+}
@@ -0,0 +1,264 @@
+/*
+Package compress provides an implementation of httpcache.Cache that compresses entries
+with Zstandard before storing them in another cache, reducing the memory or disk used by
+the underlying backend.
+
+Caches of API responses typically store many small, similar JSON documents that do not
+compress well individually. The cache can optionally train a Zstandard dictionary from a
+sample of the stored JSON entries and use it to compress subsequent small JSON entries,
+which significantly improves their compression ratio. The trained dictionary can be
+retrieved with Dictionary and persisted so that it is supplied when the cache is
+reopened; entries compressed with a dictionary cannot be read without it.
+
+Entries stored in the underlying cache before compression was enabled are returned as is.
+
+Example Usage:
+
+	cache, err := compress.New(leveldbCache, &compress.Options{TrainSamples: 1000})
+	if err != nil {
+		return err
+	}
+
+	client := httpcache.NewTransport(cache).Client()
+*/
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"go.rtnl.ai/httpcache"
+)
+
+const (
+	defaultMaxDictEntrySize = 16 << 10
+	defaultMaxDictSize      = 64 << 10
+	minTrainSamples         = 8
+)
+
+// frameMagic identifies a Zstandard frame; entries stored without compression never
+// begin with it since serialized responses begin with the HTTP version.
+var frameMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Options configures the compression of entries.
+type Options struct {
+	// TrainSamples is the number of small JSON entries that are sampled to train a
+	// dictionary once enough have been stored. Zero disables dictionary training. At
+	// least 8 samples are required.
+	TrainSamples int
+
+	// Dictionary is a dictionary previously returned by Dictionary that is used to
+	// compress small JSON entries and to read entries compressed with it. If it is set,
+	// no dictionary is trained.
+	Dictionary []byte
+
+	// MaxDictEntrySize is the maximum size of entries that are sampled for training and
+	// compressed with the dictionary (default 16KiB); larger entries compress well
+	// without a dictionary.
+	MaxDictEntrySize int
+
+	// MaxDictSize is the maximum size of a trained dictionary (default 64KiB).
+	MaxDictSize int
+}
+
+// Cache is an implementation of httpcache.Cache that compresses the entries stored in
+// the underlying cache.
+type Cache struct {
+	cache httpcache.Cache
+	plain *zstd.Encoder
+
+	mu      sync.RWMutex
+	opts    Options
+	dict    []byte
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	samples [][]byte
+}
+
+var _ httpcache.Cache = (*Cache)(nil)
+
+// New returns a Cache that compresses entries stored in the underlying cache. If opts
+// is nil, entries are compressed without a dictionary.
+func New(cache httpcache.Cache, opts *Options) (c *Cache, err error) {
+	c = &Cache{cache: cache}
+	if opts != nil {
+		c.opts = *opts
+	}
+
+	if c.opts.TrainSamples > 0 && c.opts.TrainSamples < minTrainSamples {
+		return nil, errors.New("compress: at least 8 samples are required to train a dictionary")
+	}
+
+	if c.opts.MaxDictEntrySize <= 0 {
+		c.opts.MaxDictEntrySize = defaultMaxDictEntrySize
+	}
+
+	if c.opts.MaxDictSize <= 0 {
+		c.opts.MaxDictSize = defaultMaxDictSize
+	}
+
+	if c.plain, err = zstd.NewWriter(nil); err != nil {
+		return nil, err
+	}
+
+	if err = c.useDictionary(c.opts.Dictionary); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the decompressed value stored for the key. Entries that cannot be
+// decompressed, e.g. because they were compressed with a different dictionary, are
+// treated as a miss.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	val, ok := c.cache.Get(key)
+	if !ok || !bytes.HasPrefix(val, frameMagic) {
+		return val, ok
+	}
+
+	c.mu.RLock()
+	decoder := c.decoder
+	c.mu.RUnlock()
+
+	out, err := decoder.DecodeAll(val, nil)
+	if err != nil {
+		httpcache.GetLogger().Warn("could not decompress cache entry", slog.String(httpcache.BackendKey, "compress"), slog.String("key", key), slog.Any("error", err))
+		return nil, false
+	}
+	return out, true
+}
+
+// Put compresses the value and stores it in the underlying cache. Small JSON entries
+// are compressed with the dictionary if one is available, otherwise they are sampled
+// to train the dictionary.
+func (c *Cache) Put(key string, val []byte) {
+	encoder := c.plain
+	if len(val) <= c.opts.MaxDictEntrySize && isJSON(val) {
+		encoder = c.dictEncoder(val)
+	}
+	c.cache.Put(key, encoder.EncodeAll(val, nil))
+}
+
+// Del removes the entry for the key from the underlying cache.
+func (c *Cache) Del(key string) {
+	c.cache.Del(key)
+}
+
+// Flush flushes the underlying cache so that stored entries are visible and persisted.
+func (c *Cache) Flush(ctx context.Context) error {
+	return httpcache.FlushCache(ctx, c.cache)
+}
+
+// Size returns the size of the compressed entries in the underlying cache, or zero if
+// it does not implement httpcache.Sizer. Implements httpcache.Sizer.
+func (c *Cache) Size() (int64, error) {
+	size, _, err := httpcache.CacheSize(c.cache)
+	return size, err
+}
+
+// Dictionary returns the dictionary used to compress small JSON entries, or nil if it
+// has not been trained yet, so that it can be persisted and supplied in the Options
+// when the cache is reopened.
+func (c *Cache) Dictionary() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return bytes.Clone(c.dict)
+}
+
+// dictEncoder returns the encoder that compresses with the dictionary. If there is no
+// dictionary yet, the value is sampled and the dictionary is trained once enough
+// samples have been collected.
+func (c *Cache) dictEncoder(val []byte) *zstd.Encoder {
+	c.mu.RLock()
+	encoder, training := c.encoder, c.opts.TrainSamples > 0
+	c.mu.RUnlock()
+
+	switch {
+	case encoder != nil:
+		return encoder
+	case !training:
+		return c.plain
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.encoder != nil:
+		return c.encoder
+	case c.opts.TrainSamples == 0:
+		return c.plain
+	}
+
+	c.samples = append(c.samples, bytes.Clone(val))
+	if len(c.samples) < c.opts.TrainSamples {
+		return c.plain
+	}
+
+	trained, err := dict.BuildZstdDict(c.samples, dict.Options{MaxDictSize: c.opts.MaxDictSize, HashBytes: 6})
+	c.samples = nil
+	if err != nil {
+		httpcache.GetLogger().Warn("could not train compression dictionary", slog.String(httpcache.BackendKey, "compress"), slog.Any("error", err))
+		c.opts.TrainSamples = 0
+		return c.plain
+	}
+
+	if err = c.setDictionary(trained); err != nil {
+		httpcache.GetLogger().Warn("could not use trained compression dictionary", slog.String(httpcache.BackendKey, "compress"), slog.Any("error", err))
+		c.opts.TrainSamples = 0
+		return c.plain
+	}
+	return c.encoder
+}
+
+// useDictionary configures the decoder and, if the dictionary is not empty, the
+// dictionary encoder.
+func (c *Cache) useDictionary(dictionary []byte) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(dictionary) == 0 {
+		c.decoder, err = zstd.NewReader(nil)
+		return err
+	}
+	return c.setDictionary(dictionary)
+}
+
+// setDictionary creates the encoder and decoder for the dictionary; the lock must be
+// held by the caller.
+func (c *Cache) setDictionary(dictionary []byte) (err error) {
+	var encoder *zstd.Encoder
+	if encoder, err = zstd.NewWriter(nil, zstd.WithEncoderDict(dictionary)); err != nil {
+		return err
+	}
+
+	var decoder *zstd.Decoder
+	if decoder, err = zstd.NewReader(nil, zstd.WithDecoderDicts(dictionary)); err != nil {
+		return err
+	}
+
+	c.dict, c.encoder, c.decoder = dictionary, encoder, decoder
+	return nil
+}
+
+// isJSON returns true if the serialized response has a JSON Content-Type.
+func isJSON(val []byte) bool {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(val)))
+	if _, err := reader.ReadLine(); err != nil {
+		return false
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(http.Header(header).Get("Content-Type")), "json")
+}
@@ -0,0 +1,92 @@
+package compress_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/compress"
+)
+
+func response(contentType, body string) []byte {
+	return fmt.Appendf(nil, "HTTP/1.1 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", contentType, len(body), body)
+}
+
+func user(i int) []byte {
+	return response("application/json", fmt.Sprintf(`{"data":{"type":"users","id":"%d","attributes":{"name":"User %d","email":"user%d@example.com","role":"member","created":"2024-01-%02dT12:00:00Z","active":true,"preferences":{"theme":"dark","language":"en-US","notifications":{"email":true,"push":false}}},"links":{"self":"https://api.example.com/users/%d"}}}`, i, i, i, i%28+1, i))
+}
+
+func TestCache(t *testing.T) {
+	backend := &httpcache.InMemoryCache{}
+	cache, err := compress.New(backend, nil)
+	require.NoError(t, err)
+
+	val := response("text/plain", string(bytes.Repeat([]byte("hello world "), 100)))
+	cache.Put("foo", val)
+
+	stored, ok := backend.Get("foo")
+	require.True(t, ok)
+	require.Less(t, len(stored), len(val))
+
+	out, ok := cache.Get("foo")
+	require.True(t, ok)
+	require.Equal(t, val, out)
+
+	cache.Del("foo")
+	_, ok = cache.Get("foo")
+	require.False(t, ok)
+
+	// Entries stored before compression was enabled are returned as is.
+	backend.Put("legacy", val)
+	out, ok = cache.Get("legacy")
+	require.True(t, ok)
+	require.Equal(t, val, out)
+
+	_, err = compress.New(backend, &compress.Options{TrainSamples: 2})
+	require.Error(t, err)
+}
+
+func TestCacheDictionary(t *testing.T) {
+	backend := &httpcache.InMemoryCache{}
+	cache, err := compress.New(backend, &compress.Options{TrainSamples: 64})
+	require.NoError(t, err)
+
+	for i := range 64 {
+		cache.Put(fmt.Sprintf("user-%d", i), user(i))
+	}
+	require.NotEmpty(t, cache.Dictionary())
+
+	plainBackend := &httpcache.InMemoryCache{}
+	plain, err := compress.New(plainBackend, nil)
+	require.NoError(t, err)
+
+	// Entries compressed with the dictionary are smaller than without it.
+	cache.Put("user-100", user(100))
+	plain.Put("user-100", user(100))
+	withDict, _ := backend.Get("user-100")
+	withoutDict, _ := plainBackend.Get("user-100")
+	require.Less(t, len(withDict), len(withoutDict))
+
+	out, ok := cache.Get("user-100")
+	require.True(t, ok)
+	require.Equal(t, user(100), out)
+
+	// Entries sampled for training remain readable.
+	out, ok = cache.Get("user-1")
+	require.True(t, ok)
+	require.Equal(t, user(1), out)
+
+	// Reopening the cache requires the dictionary.
+	reopened, err := compress.New(backend, &compress.Options{Dictionary: cache.Dictionary()})
+	require.NoError(t, err)
+	out, ok = reopened.Get("user-100")
+	require.True(t, ok)
+	require.Equal(t, user(100), out)
+
+	missing, err := compress.New(backend, nil)
+	require.NoError(t, err)
+	_, ok = missing.Get("user-100")
+	require.False(t, ok)
+}
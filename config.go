@@ -0,0 +1,69 @@
+package httpcache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Config contains the caching policy options of a Transport that can be replaced
+// atomically at runtime with UpdateConfig, e.g. when a configuration file is changed, so
+// that changes to the caching policy do not require the process to be restarted. Once
+// a Config has been applied, it is used instead of the corresponding fields of the
+// Transport; the fields are documented on the Transport.
+type Config struct {
+	StatusCodes         []int
+	NegativeTTL         time.Duration
+	NegativeStatusCodes []int
+	Profiles            map[string]*Profile
+	Rules               []Rule
+}
+
+// UpdateConfig atomically replaces the caching policy of the Transport. Requests that
+// are in progress complete with the previous policy. The Config, its profiles and its
+// rules must not be modified after they have been applied. Stored responses are not
+// affected, although a change to the key headers of a profile means that responses
+// stored with the previous keys are no longer found. If the Transport was created with
+// NewStrictTransport, configs that do not pass Strict are logged and not applied.
+func (t *Transport) UpdateConfig(config Config) {
+	if t.strict {
+		if err := t.strictConfig(&config); err != nil {
+			GetLogger().Error("refusing to apply insecure cache config", slog.Any("error", err))
+			return
+		}
+	}
+	t.config.Store(&config)
+}
+
+// WatchConfig applies each Config received from the updates channel with UpdateConfig
+// until the channel is closed or the context is canceled, e.g. to apply the changes of
+// a watched configuration source.
+func (t *Transport) WatchConfig(ctx context.Context, updates <-chan Config) error {
+	for {
+		select {
+		case config, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			t.UpdateConfig(config)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// currentConfig returns the Config applied by UpdateConfig or, if none has been
+// applied, the Config of the fields of the Transport.
+func (t *Transport) currentConfig() *Config {
+	if config := t.config.Load(); config != nil {
+		return config
+	}
+
+	return &Config{
+		StatusCodes:         t.StatusCodes,
+		NegativeTTL:         t.NegativeTTL,
+		NegativeStatusCodes: t.NegativeStatusCodes,
+		Profiles:            t.Profiles,
+		Rules:               t.Rules,
+	}
+}
@@ -0,0 +1,79 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportUpdateConfig(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if strings.HasPrefix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}}
+	client := transport.Client()
+
+	requests := func(path string) int64 {
+		before := origin.Requests()
+		Get(t, client, origin.URL+path)
+		Get(t, client, origin.URL+path)
+		return origin.Requests() - before
+	}
+
+	require.Equal(t, int64(2), requests("/ttl"), "expected no caching without a profile")
+	require.Equal(t, int64(2), requests("/missing"), "expected no negative caching")
+
+	transport.UpdateConfig(httpcache.Config{
+		NegativeTTL: time.Hour,
+		Profiles: map[string]*httpcache.Profile{
+			u.Hostname(): {TTL: time.Hour},
+		},
+	})
+	require.Equal(t, int64(1), requests("/profile"), "expected the new profile to be applied")
+	require.Equal(t, int64(1), requests("/missing/other"), "expected the new negative TTL to be applied")
+
+	transport.UpdateConfig(httpcache.Config{})
+	require.Equal(t, int64(2), requests("/other"), "expected the profile to be removed")
+}
+
+func TestTransportWatchConfig(t *testing.T) {
+	transport := &httpcache.Transport{
+		Profiles: map[string]*httpcache.Profile{"example.com": {KeyHeaders: []string{"X-Tenant"}}},
+	}
+	require.NoError(t, transport.Strict())
+
+	updates := make(chan httpcache.Config)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- transport.WatchConfig(ctx, updates) }()
+
+	// The unbuffered send only completes once the watcher received the previous update.
+	updates <- httpcache.Config{Profiles: map[string]*httpcache.Profile{"example.com": {KeyHeaders: []string{"Cookie"}}}}
+	updates <- httpcache.Config{Profiles: map[string]*httpcache.Profile{"example.com": {KeyHeaders: []string{"Authorization"}}}}
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+
+	err := transport.Strict()
+	require.ErrorIs(t, err, httpcache.ErrInsecureConfig, "expected the watched config to be checked")
+	require.Contains(t, err.Error(), "Authorization")
+
+	updates = make(chan httpcache.Config)
+	go func() { errc <- transport.WatchConfig(context.Background(), updates) }()
+	close(updates)
+	require.False(t, errors.Is(<-errc, context.Canceled), "expected nil when the updates are closed")
+}
@@ -0,0 +1,392 @@
+/*
+Package conformance is an optional test harness that runs the Transport against HTTP
+caching conformance test vectors in the format of the cache-tests.fyi suite and reports
+whether each behavior passes, so that claims of RFC 9111 compliance can be checked.
+
+Each test is a sequence of requests sent through the Transport to an origin server that
+responds as the test describes. The outcome of each request is determined from what
+the origin observed: a request that did not reach the origin was served from the cache,
+and a request that reached it with If-None-Match or If-Modified-Since revalidated a
+stored response. Time is simulated with a clock that only advances when a test pauses,
+so the tests are fast and deterministic.
+
+Header values in the vectors may be numbers, which are converted to HTTP dates that
+number of seconds from the current time, e.g. ["Expires", 30] or ["Date", 0].
+
+Example Usage:
+
+	tests, err := conformance.Load(file)
+	if err != nil {
+		return err
+	}
+
+	results := conformance.Run(tests, func() *httpcache.Transport {
+		return httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithSharedMode())
+	})
+	conformance.Report(os.Stdout, results)
+*/
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.rtnl.ai/httpcache"
+)
+
+// Kinds of tests; only failures of required tests indicate non-conformance.
+const (
+	KindRequired = "required"
+	KindOptimal  = "optimal"
+	KindCheck    = "check"
+)
+
+// Expected types of the outcome of a request.
+const (
+	ExpectCached        = "cached"
+	ExpectNotCached     = "not_cached"
+	ExpectETagValidated = "etag_validated"
+	ExpectLMValidated   = "lm_validated"
+)
+
+// Pause is the time that the clock is advanced after a request with PauseAfter.
+const Pause = 3 * time.Second
+
+// requestHeader identifies the request of a test that the origin should respond to.
+const requestHeader = "X-Conformance-Request"
+
+// Test is a conformance test vector.
+type Test struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind,omitempty"`
+	Requests []Request `json:"requests"`
+}
+
+// Request is a request of a test, the response that the origin sends to it, and the
+// expected outcome.
+type Request struct {
+	Method  string `json:"request_method,omitempty"`
+	Headers Header `json:"request_headers,omitempty"`
+
+	ResponseStatus  Status `json:"response_status,omitempty"`
+	ResponseHeaders Header `json:"response_headers,omitempty"`
+	ResponseBody    string `json:"response_body,omitempty"`
+
+	ExpectedType            string `json:"expected_type,omitempty"`
+	ExpectedStatus          int    `json:"expected_status,omitempty"`
+	ExpectedResponseHeaders Header `json:"expected_response_headers,omitempty"`
+
+	// Setup requests prepare the cache; their failures are reported as setup errors.
+	Setup bool `json:"setup,omitempty"`
+
+	// PauseAfter advances the clock by Pause once the request has completed.
+	PauseAfter bool `json:"pause_after,omitempty"`
+}
+
+// Header is a list of header fields as name and value pairs. Values that are numbers
+// are converted to HTTP dates relative to the current time.
+type Header [][2]any
+
+// Status is a status code and reason phrase, e.g. [200, "OK"].
+type Status struct {
+	Code int
+	Text string
+}
+
+// UnmarshalJSON parses the status from a [code, text] array.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var raw []any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) == 0 {
+		return errors.New("conformance: empty response status")
+	}
+
+	code, ok := raw[0].(float64)
+	if !ok {
+		return fmt.Errorf("conformance: invalid status code %v", raw[0])
+	}
+	s.Code = int(code)
+
+	if len(raw) > 1 {
+		s.Text, _ = raw[1].(string)
+	}
+	return nil
+}
+
+// Result is the outcome of a test.
+type Result struct {
+	Test   *Test
+	Passed bool
+
+	// Setup is true if the test failed because a setup request failed.
+	Setup bool
+
+	// Err describes why the test failed.
+	Err error
+}
+
+// Load reads a JSON array of tests.
+func Load(r io.Reader) (tests []Test, err error) {
+	if err = json.NewDecoder(r).Decode(&tests); err != nil {
+		return nil, fmt.Errorf("conformance: could not parse tests: %w", err)
+	}
+	return tests, nil
+}
+
+// Run runs each of the tests with a new Transport returned by newTransport, whose clock
+// is replaced with the simulated clock of the test, and returns their results.
+func Run(tests []Test, newTransport func() *httpcache.Transport) []Result {
+	results := make([]Result, 0, len(tests))
+	for i := range tests {
+		results = append(results, run(&tests[i], newTransport()))
+	}
+	return results
+}
+
+// Report writes a line for each result and a summary of the results to the writer.
+func Report(w io.Writer, results []Result) {
+	var passed, failed, optional int
+	for _, result := range results {
+		status := "PASS"
+		switch {
+		case result.Passed:
+			passed++
+		case result.Setup:
+			status = "SETUP"
+			failed++
+		case result.Test.Kind != "" && result.Test.Kind != KindRequired:
+			status = "WARN"
+			optional++
+		default:
+			status = "FAIL"
+			failed++
+		}
+
+		fmt.Fprintf(w, "%-5s %s: %s", status, result.Test.ID, result.Test.Name)
+		if result.Err != nil {
+			fmt.Fprintf(w, " (%s)", result.Err)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed, %d optional failures\n", passed, failed, optional)
+}
+
+// clock is the simulated time of a test.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// observed is a request received by the origin.
+type observed struct {
+	header http.Header
+}
+
+// origin responds to the requests of a test and records the requests it receives.
+type origin struct {
+	test  *Test
+	clock *clock
+
+	mu       sync.Mutex
+	received []observed
+}
+
+func (o *origin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.Header.Get(requestHeader))
+	if err != nil || index < 0 || index >= len(o.test.Requests) {
+		http.Error(w, "unknown conformance request", http.StatusBadRequest)
+		return
+	}
+
+	o.mu.Lock()
+	o.received = append(o.received, observed{header: r.Header.Clone()})
+	o.mu.Unlock()
+
+	spec := &o.test.Requests[index]
+	header, _ := spec.ResponseHeaders.http(o.clock.Now())
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+
+	if w.Header().Get("Date") == "" {
+		w.Header().Set("Date", o.clock.Now().UTC().Format(http.TimeFormat))
+	}
+
+	status := spec.ResponseStatus.Code
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	body := spec.ResponseBody
+	if body == "" {
+		body = fmt.Sprintf("%s response %d", o.test.ID, index)
+	}
+	io.WriteString(w, body)
+}
+
+// observedSince returns the requests received by the origin after the first n.
+func (o *origin) observedSince(n int) []observed {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]observed(nil), o.received[n:]...)
+}
+
+func (o *origin) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.received)
+}
+
+// run runs the test with the Transport.
+func run(test *Test, transport *httpcache.Transport) Result {
+	clock := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	transport.Clock = clock
+
+	origin := &origin{test: test, clock: clock}
+	srv := httptest.NewServer(origin)
+	defer srv.Close()
+
+	client := &http.Client{Transport: transport}
+	target := srv.URL + "/" + test.ID
+
+	for i := range test.Requests {
+		if err := check(client, origin, target, i); err != nil {
+			spec := &test.Requests[i]
+			return Result{Test: test, Setup: spec.Setup, Err: fmt.Errorf("request %d: %w", i, err)}
+		}
+
+		if test.Requests[i].PauseAfter {
+			clock.advance(Pause)
+		}
+	}
+	return Result{Test: test, Passed: true}
+}
+
+// check sends the request of the test and verifies the expected outcome.
+func check(client *http.Client, origin *origin, target string, index int) (err error) {
+	spec := &origin.test.Requests[index]
+	method := spec.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest(method, target, nil); err != nil {
+		return err
+	}
+
+	var header http.Header
+	if header, err = spec.Headers.http(origin.clock.Now()); err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set(requestHeader, strconv.Itoa(index))
+
+	before := origin.count()
+	var rep *http.Response
+	if rep, err = client.Do(req); err != nil {
+		return err
+	}
+	io.Copy(io.Discard, rep.Body)
+	rep.Body.Close()
+
+	if spec.ExpectedStatus != 0 && rep.StatusCode != spec.ExpectedStatus {
+		return fmt.Errorf("expected status %d, got %d", spec.ExpectedStatus, rep.StatusCode)
+	}
+
+	if err = checkType(spec.ExpectedType, origin.observedSince(before)); err != nil {
+		return err
+	}
+
+	var expected http.Header
+	if expected, err = spec.ExpectedResponseHeaders.http(origin.clock.Now()); err != nil {
+		return err
+	}
+	for name := range expected {
+		if got, want := rep.Header.Get(name), expected.Get(name); got != want {
+			return fmt.Errorf("expected %s header %q, got %q", name, want, got)
+		}
+	}
+	return nil
+}
+
+// checkType verifies the expected outcome of a request from the requests the origin
+// received while it was handled.
+func checkType(expected string, received []observed) error {
+	switch expected {
+	case "":
+		return nil
+	case ExpectCached:
+		if len(received) > 0 {
+			return errors.New("expected response to be served from the cache, but it was fetched from the origin")
+		}
+	case ExpectNotCached:
+		if len(received) == 0 {
+			return errors.New("expected response to be fetched from the origin, but it was served from the cache")
+		}
+	case ExpectETagValidated, ExpectLMValidated:
+		name := "If-None-Match"
+		if expected == ExpectLMValidated {
+			name = "If-Modified-Since"
+		}
+
+		if len(received) == 0 {
+			return fmt.Errorf("expected response to be validated with %s, but it was served from the cache", name)
+		}
+
+		if received[len(received)-1].header.Get(name) == "" {
+			return fmt.Errorf("expected response to be validated with %s, but the request was not conditional", name)
+		}
+	default:
+		return fmt.Errorf("unknown expected type %q", expected)
+	}
+	return nil
+}
+
+// http converts the header to an http.Header, converting numeric values to HTTP dates
+// relative to now.
+func (h Header) http(now time.Time) (http.Header, error) {
+	header := make(http.Header, len(h))
+	for _, field := range h {
+		name, ok := field[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("conformance: invalid header name %v", field[0])
+		}
+
+		switch value := field[1].(type) {
+		case string:
+			header.Add(name, value)
+		case float64:
+			header.Add(name, now.Add(time.Duration(value)*time.Second).UTC().Format(http.TimeFormat))
+		default:
+			return nil, fmt.Errorf("conformance: invalid value for header %s", name)
+		}
+	}
+	return header, nil
+}
@@ -0,0 +1,60 @@
+package conformance_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/conformance"
+)
+
+func loadTests(t *testing.T) []conformance.Test {
+	f, err := os.Open("testdata/cache-tests.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	tests, err := conformance.Load(f)
+	require.NoError(t, err)
+	return tests
+}
+
+func TestConformance(t *testing.T) {
+	results := conformance.Run(loadTests(t), func() *httpcache.Transport {
+		return httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithSharedMode())
+	})
+
+	for _, result := range results {
+		t.Run(result.Test.ID, func(t *testing.T) {
+			require.True(t, result.Passed, "%s: %v", result.Test.Name, result.Err)
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	// A transport without a cache fails every test that expects a cached response.
+	results := conformance.Run(loadTests(t), func() *httpcache.Transport {
+		return &httpcache.Transport{}
+	})
+
+	buf := &bytes.Buffer{}
+	conformance.Report(buf, results)
+	report := buf.String()
+
+	require.Contains(t, report, "PASS  freshness-max-age-0: ")
+	require.Contains(t, report, "WARN  freshness-max-age: ")
+	require.Contains(t, report, "expected response to be served from the cache")
+	require.Contains(t, report, "FAIL  cc-resp-no-cache-revalidate: ")
+	require.True(t, strings.HasSuffix(report, "passed, 2 failed, 10 optional failures\n"), report)
+}
+
+func TestLoad(t *testing.T) {
+	_, err := conformance.Load(strings.NewReader(`[{"id": "x", "requests": [{"response_status": ["OK"]}]}]`))
+	require.Error(t, err)
+
+	tests, err := conformance.Load(strings.NewReader(`[{"id": "x", "requests": [{"response_status": [404, "Not Found"]}]}]`))
+	require.NoError(t, err)
+	require.Equal(t, conformance.Status{Code: 404, Text: "Not Found"}, tests[0].Requests[0].ResponseStatus)
+}
@@ -0,0 +1,117 @@
+package httpcache
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDeltaSeconds is used for delta-seconds values that overflow (RFC 9111 §1.2.2).
+const maxDeltaSeconds = math.MaxInt32 + 1
+
+// cacheControl contains the parsed Cache-Control directives (RFC 9111 §5.2) of a
+// request or response keyed by the lowercase directive name. Directives without an
+// argument map to an empty string. If a directive is repeated the first value is used.
+type cacheControl map[string]string
+
+// parseCacheControl parses all Cache-Control header lines in the header.
+func parseCacheControl(header http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, line := range header.Values("Cache-Control") {
+		for _, directive := range splitDirectives(line) {
+			name, value, _ := strings.Cut(directive, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			if _, ok := cc[name]; ok {
+				continue
+			}
+
+			value = strings.TrimSpace(value)
+			if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			cc[name] = value
+		}
+	}
+	return cc
+}
+
+// requestCacheControl parses the Cache-Control directives of a request. If the request
+// has no Cache-Control header, Pragma: no-cache is treated as no-cache for HTTP/1.0
+// compatibility (RFC 9111 §5.4).
+func requestCacheControl(header http.Header) cacheControl {
+	cc := parseCacheControl(header)
+	if len(header.Values("Cache-Control")) > 0 {
+		return cc
+	}
+
+	for _, value := range header.Values("Pragma") {
+		for _, directive := range splitDirectives(value) {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				cc["no-cache"] = ""
+			}
+		}
+	}
+	return cc
+}
+
+// splitDirectives splits a header value on commas that are not within quotes.
+func splitDirectives(value string) (directives []string) {
+	var (
+		start  int
+		quoted bool
+	)
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				directives = append(directives, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(directives, value[start:])
+}
+
+// has returns true if the directive is present.
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns the delta-seconds argument of the directive as a duration and true
+// if the directive is present with a valid argument.
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	return deltaSeconds(value)
+}
+
+// deltaSeconds parses a non-negative integer number of seconds. Values that are too
+// large to be represented are capped at 2^31 seconds.
+func deltaSeconds(value string) (time.Duration, bool) {
+	if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+		return 0, false
+	}
+
+	secs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || secs > maxDeltaSeconds {
+		secs = maxDeltaSeconds
+	}
+	return time.Duration(secs) * time.Second, true
+}
@@ -0,0 +1,49 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected map[string]string
+	}{
+		{"Empty", nil, map[string]string{}},
+		{"Single", []string{"no-cache"}, map[string]string{"no-cache": ""}},
+		{"Arguments", []string{"max-age=60, s-maxage=120"}, map[string]string{"max-age": "60", "s-maxage": "120"}},
+		{"Case Insensitive", []string{"Max-Age=60,PUBLIC"}, map[string]string{"max-age": "60", "public": ""}},
+		{"Quoted", []string{`private="Set-Cookie, Authorization", max-age="30"`}, map[string]string{"private": "Set-Cookie, Authorization", "max-age": "30"}},
+		{"Multiple Lines", []string{"public", "max-age=10"}, map[string]string{"public": "", "max-age": "10"}},
+		{"First Wins", []string{"max-age=10, max-age=20"}, map[string]string{"max-age": "10"}},
+		{"Empty Directives", []string{" , ,max-age=5,"}, map[string]string{"max-age": "5"}},
+	}
+
+	for _, test := range tests {
+		header := http.Header{"Cache-Control": test.values}
+		require.Equal(t, test.expected, httpcache.ParseCacheControl(header), "Test Case: %q", test.name)
+	}
+}
+
+func TestRequestCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected map[string]string
+	}{
+		{"Empty", http.Header{}, map[string]string{}},
+		{"Pragma", http.Header{"Pragma": {"No-Cache"}}, map[string]string{"no-cache": ""}},
+		{"Pragma Extension", http.Header{"Pragma": {"x-debug, no-cache"}}, map[string]string{"no-cache": ""}},
+		{"Other Pragma", http.Header{"Pragma": {"x-debug"}}, map[string]string{}},
+		{"Cache-Control Precedence", http.Header{"Pragma": {"no-cache"}, "Cache-Control": {"max-age=60"}}, map[string]string{"max-age": "60"}},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, httpcache.RequestCacheControl(test.header), "Test Case: %q", test.name)
+	}
+}
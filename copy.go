@@ -0,0 +1,54 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+)
+
+// CopyValues returns a cache that copies values on Put and Get so that neither the
+// caller nor the underlying cache can observe modifications the other makes to a value,
+// e.g. a caller that reuses the buffer it stored or a backend that reuses the buffers it
+// returns. Copying costs an allocation per call, so it is only necessary when the
+// ownership rules of Cache cannot be guaranteed. If the underlying cache implements
+// KeyLister, so does the returned cache.
+func CopyValues(cache Cache) Cache {
+	copying := copyingCache{cache: cache}
+	if lister, ok := cache.(KeyLister); ok {
+		return &copyingLister{copyingCache: copying, KeyLister: lister}
+	}
+	return &copying
+}
+
+type copyingCache struct {
+	cache Cache
+}
+
+func (c *copyingCache) Get(key string) ([]byte, bool) {
+	val, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(val), true
+}
+
+func (c *copyingCache) Put(key string, val []byte) {
+	c.cache.Put(key, bytes.Clone(val))
+}
+
+func (c *copyingCache) Del(key string) {
+	c.cache.Del(key)
+}
+
+func (c *copyingCache) Flush(ctx context.Context) error {
+	return FlushCache(ctx, c.cache)
+}
+
+func (c *copyingCache) Size() (int64, error) {
+	size, _, err := CacheSize(c.cache)
+	return size, err
+}
+
+type copyingLister struct {
+	copyingCache
+	KeyLister
+}
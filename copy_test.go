@@ -0,0 +1,42 @@
+package httpcache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestCopyValues(t *testing.T) {
+	inner := &httpcache.InMemoryCache{}
+	cache := httpcache.CopyValues(inner)
+
+	// Modifying the buffer after Put does not change the stored value.
+	buf := []byte("stored")
+	cache.Put("key", buf)
+	copy(buf, "XXXXXX")
+
+	val, ok := cache.Get("key")
+	require.True(t, ok)
+	require.Equal(t, "stored", string(val))
+
+	// Modifying a returned value does not change the stored value.
+	copy(val, "YYYYYY")
+	val, ok = cache.Get("key")
+	require.True(t, ok)
+	require.Equal(t, "stored", string(val))
+
+	stored, _ := inner.Get("key")
+	require.Equal(t, "stored", string(stored))
+
+	lister, ok := cache.(httpcache.KeyLister)
+	require.True(t, ok, "expected the cache to list keys")
+	require.Equal(t, []string{"key"}, lister.Keys())
+
+	cache.Del("key")
+	_, ok = cache.Get("key")
+	require.False(t, ok)
+
+	_, ok = httpcache.CopyValues(nopCache{}).(httpcache.KeyLister)
+	require.False(t, ok, "expected a cache without key listing not to list keys")
+}
@@ -0,0 +1,151 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// submissions tracks the unsafe requests that are in flight or were recently completed
+// so that identical duplicate submissions receive the same response.
+type submissions struct {
+	mu    sync.Mutex
+	calls map[string]*submission
+}
+
+// submission is the shared result of an unsafe request; the response and its body are
+// only set once done is closed.
+type submission struct {
+	done chan struct{}
+	rep  *http.Response
+	body []byte
+	err  error
+}
+
+// join returns the submission for the key and true if the caller is the leader that
+// must send the request and call complete.
+func (s *submissions) join(key string) (*submission, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if call, ok := s.calls[key]; ok {
+		return call, false
+	}
+
+	if s.calls == nil {
+		s.calls = make(map[string]*submission)
+	}
+
+	call := &submission{done: make(chan struct{})}
+	s.calls[key] = call
+	return call, true
+}
+
+// complete shares the result of the leader's request with the duplicates and forgets
+// the submission once the window has passed. Failed requests are forgotten immediately.
+func (s *submissions) complete(key string, call *submission, window time.Duration) {
+	close(call.done)
+	if call.err != nil {
+		s.forget(key, call)
+		return
+	}
+	time.AfterFunc(window, func() { s.forget(key, call) })
+}
+
+func (s *submissions) forget(key string, call *submission) {
+	s.mu.Lock()
+	if s.calls[key] == call {
+		delete(s.calls, key)
+	}
+	s.mu.Unlock()
+}
+
+// response returns a copy of the shared response for the request.
+func (c *submission) response(req *http.Request) *http.Response {
+	rep := new(http.Response)
+	*rep = *c.rep
+	rep.Header = c.rep.Header.Clone()
+	rep.Body = io.NopCloser(bytes.NewReader(c.body))
+	rep.Request = req
+	return rep
+}
+
+// submissionKey identifies duplicate submissions by the method, URL, credentials and a
+// hash of the body of the request. Credentials are included so that responses are never
+// shared between clients.
+func submissionKey(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.String()+"\n")
+	for _, name := range credentialHeaders {
+		for _, value := range req.Header.Values(name) {
+			io.WriteString(hash, name+": "+value+"\n")
+		}
+	}
+	hash.Write([]byte{'\n'})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// roundTripUnsafe forwards an unsafe request to the origin and invalidates the stored
+// responses that it may have changed.
+func (t *Transport) roundTripUnsafe(req *http.Request) (rep *http.Response, err error) {
+	if rep, err = t.forward(req); err != nil {
+		return nil, err
+	}
+
+	if t.selectCache(req) != nil {
+		t.invalidate(req, rep)
+	}
+	return rep, nil
+}
+
+// roundTripDeduplicated sends only the first of identical concurrent unsafe requests to
+// the origin; the duplicates that arrive while it is in flight or within the
+// DeduplicateWindow after it completes receive a copy of its response. The response
+// body is buffered so that it can be shared. If the request fails, the duplicates are
+// sent to the origin separately.
+func (t *Transport) roundTripDeduplicated(req *http.Request) (rep *http.Response, err error) {
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	key := submissionKey(req, body)
+	call, leader := t.submissions.join(key)
+	if !leader {
+		select {
+		case <-call.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if call.err != nil {
+			return t.roundTripUnsafe(req)
+		}
+		return call.response(req), nil
+	}
+
+	defer func() { t.submissions.complete(key, call, t.DeduplicateWindow) }()
+	if call.rep, call.err = t.roundTripUnsafe(req); call.err != nil {
+		return nil, call.err
+	}
+
+	call.body, call.err = io.ReadAll(call.rep.Body)
+	call.rep.Body.Close()
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.response(req), nil
+}
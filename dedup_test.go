@@ -0,0 +1,83 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportDeduplicate(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Body", string(body))
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := &httpcache.Transport{DeduplicateWindow: time.Hour}
+	client := transport.Client()
+
+	post := func(body string, headers ...string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, origin.URL+"/hooks", strings.NewReader(body))
+		require.NoError(t, err)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		rep, err := client.Do(req)
+		require.NoError(t, err)
+		defer rep.Body.Close()
+
+		data, err := io.ReadAll(rep.Body)
+		require.NoError(t, err)
+		return rep, string(data)
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		bodies := make([]string, 8)
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rep, body := post("event")
+				require.Equal(t, http.StatusAccepted, rep.StatusCode)
+				require.Equal(t, "event", rep.Header.Get("X-Body"))
+				bodies[i] = body
+			}(i)
+		}
+		wg.Wait()
+
+		require.Equal(t, int64(1), origin.Requests(), "expected duplicates to be deduplicated")
+		for _, body := range bodies {
+			require.Equal(t, "response 1", body)
+		}
+	})
+
+	t.Run("Distinct", func(t *testing.T) {
+		before := origin.Requests()
+		_, body := post("other")
+		require.Equal(t, before+1, origin.Requests(), "expected a different body to be sent")
+		require.NotEqual(t, "response 1", body)
+
+		post("event", "Authorization", "Bearer other")
+		require.Equal(t, before+2, origin.Requests(), "expected different credentials to be sent")
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		transport.DeduplicateWindow = time.Millisecond
+		post("window")
+		time.Sleep(20 * time.Millisecond)
+
+		before := origin.Requests()
+		post("window")
+		require.Equal(t, before+1, origin.Requests(), "expected a request after the window to be sent")
+	})
+}
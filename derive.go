@@ -0,0 +1,139 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Derivation generates a derived variant of a response, e.g. a thumbnail of an image
+// or a minified view of a JSON document. Derived variants are computed once when the
+// original response is stored and are served to matching requests until the original
+// response is refreshed, so that the derivation is not repeated for every request.
+type Derivation struct {
+	// Name identifies the derived variant; it must be unique for the Transport.
+	Name string
+
+	// Match returns true if the request should be served the derived variant rather
+	// than the original response.
+	Match func(req *http.Request) bool
+
+	// Derive returns the body of the derived variant computed from the body of the
+	// original response. The header is a copy of the original response header that may
+	// be modified, e.g. to change the Content-Type of the derived variant.
+	Derive func(header http.Header, body []byte) ([]byte, error)
+
+	// Applies returns true if the variant can be derived from the response, e.g. based
+	// on its Content-Type, so that the derivation is not attempted for responses it
+	// cannot handle. The response must not be modified and its body must not be read.
+	// If nil, the variant is derived from every successful response.
+	Applies func(rep *http.Response) bool
+}
+
+// applies returns true if the variant should be derived from the response.
+func (d *Derivation) applies(rep *http.Response) bool {
+	return d.Applies == nil || d.Applies(rep)
+}
+
+// derivedKey returns the primary key of a derived variant of a response.
+func derivedKey(primary, name string) string {
+	return primary + "|derived:" + name
+}
+
+// derivation returns the first derivation that matches the request, if any.
+func (t *Transport) derivation(req *http.Request) *Derivation {
+	for i := range t.Derivations {
+		if d := &t.Derivations[i]; d.Match != nil && d.Match(req) {
+			return d
+		}
+	}
+	return nil
+}
+
+// roundTripDerived serves the derived variant for the request from the cache if it is
+// fresh. Otherwise the original response is fetched, storing it and its derived
+// variants, and the stored variant is served. If the original response was not stored,
+// the variant is derived from it for this request.
+func (t *Transport) roundTripDerived(req *http.Request, d *Derivation, policy policy, primary string, reqcc cacheControl) (rep *http.Response, err error) {
+	_, cached, freshness := t.lookup(derivedKey(primary, d.Name), req, policy)
+	freshness.request(reqcc)
+	if cached != nil {
+		if freshness.fresh() {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return t.annotate(serve(cached, freshness), CacheHit), nil
+		}
+		cached.Body.Close()
+	}
+
+	if rep, err = t.roundTrip(req, policy, primary, reqcc); err != nil {
+		return nil, err
+	}
+
+	// Reading the complete body stores the original response and its derived variants.
+	var body []byte
+	body, err = io.ReadAll(rep.Body)
+	rep.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if rep.StatusCode != http.StatusOK {
+		rep.Body = io.NopCloser(bytes.NewReader(body))
+		return rep, nil
+	}
+
+	if !d.applies(rep) {
+		rep.Body = io.NopCloser(bytes.NewReader(body))
+		return rep, nil
+	}
+
+	// Serve the variant derived when the original response was stored if possible. The
+	// request was already annotated and observed by the hooks when it was fetched.
+	if _, cached, freshness = t.lookup(derivedKey(primary, d.Name), req, policy); cached != nil {
+		if freshness.fresh() {
+			variant := serve(cached, freshness)
+			if t.StatusHeader != "" {
+				variant.Header.Set(t.StatusHeader, rep.Header.Get(t.StatusHeader))
+			}
+			return variant, nil
+		}
+		cached.Body.Close()
+	}
+
+	header := rep.Header.Clone()
+	if body, err = d.Derive(header, body); err != nil {
+		return nil, fmt.Errorf("could not derive %s variant: %w", d.Name, err)
+	}
+
+	header.Del("Content-Length")
+	rep.Header = header
+	rep.Body = io.NopCloser(bytes.NewReader(body))
+	rep.ContentLength = int64(len(body))
+	return rep, nil
+}
+
+// storeDerived computes the derived variants of the response that apply to it and puts
+// them into the cache. Variants that cannot be derived are not stored.
+func (t *Transport) storeDerived(primary string, req *http.Request, rep *http.Response, body []byte, policy policy, requestTime, responseTime time.Time) {
+	for _, d := range t.Derivations {
+		if !d.applies(rep) {
+			continue
+		}
+
+		header := rep.Header.Clone()
+		derived, err := d.Derive(header, body)
+		if err != nil {
+			GetLogger().Warn("could not derive variant", slog.String("key", primary), slog.String("variant", d.Name), slog.Any("error", err))
+			continue
+		}
+
+		variant := *rep
+		variant.Header = header
+		t.storeEntry(derivedKey(primary, d.Name), req, &variant, derived, policy, requestTime, responseTime)
+	}
+}
@@ -0,0 +1,128 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportDerivations(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/missing" {
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var derived int
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Derivations = []httpcache.Derivation{
+		{
+			Name:  "upper",
+			Match: func(r *http.Request) bool { return r.Header.Get("X-View") == "upper" },
+			Derive: func(header http.Header, body []byte) ([]byte, error) {
+				derived++
+				header.Set("Content-Type", "text/x-upper")
+				return bytes.ToUpper(body), nil
+			},
+		},
+		{
+			Name:  "broken",
+			Match: func(r *http.Request) bool { return r.Header.Get("X-View") == "broken" },
+			Derive: func(http.Header, []byte) ([]byte, error) {
+				return nil, errors.New("cannot derive")
+			},
+		},
+	}
+	client := transport.Client()
+
+	rep, body := Get(t, client, origin.URL, "X-View", "upper")
+	require.Equal(t, "RESPONSE 1", body)
+	require.Equal(t, "text/x-upper", rep.Header.Get("Content-Type"))
+	require.Equal(t, 1, derived, "expected the variant to be derived once on store")
+
+	// Both the original response and the derived variant are served from the cache.
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "text/plain", rep.Header.Get("Content-Type"))
+
+	_, body = Get(t, client, origin.URL, "X-View", "upper")
+	require.Equal(t, "RESPONSE 1", body)
+	require.Equal(t, int64(1), origin.Requests())
+	require.Equal(t, 1, derived)
+
+	// Failures to derive a variant are returned to the caller.
+	req, err := http.NewRequest(http.MethodGet, origin.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-View", "broken")
+	_, err = client.Do(req)
+	require.ErrorContains(t, err, "could not derive broken variant: cannot derive")
+
+	// Unsuccessful responses are not derived.
+	rep, body = Get(t, client, origin.URL+"/missing", "X-View", "upper")
+	require.Equal(t, http.StatusNotFound, rep.StatusCode)
+	require.Equal(t, "response 2", body)
+}
+
+func TestTransportDerivationApplies(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var derived, misses, hits int
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithStatusHeader(httpcache.DefaultStatusHeader),
+		httpcache.WithHooks(httpcache.Hooks{
+			OnMiss: func(*http.Request) { misses++ },
+			OnHit:  func(*http.Request, bool) { hits++ },
+		}),
+		httpcache.WithDerivations(httpcache.Derivation{
+			Name:    "upper",
+			Match:   func(r *http.Request) bool { return r.Header.Get("X-View") == "upper" },
+			Applies: func(rep *http.Response) bool { return rep.Header.Get("Content-Type") == "text/plain" },
+			Derive: func(header http.Header, body []byte) ([]byte, error) {
+				derived++
+				return bytes.ToUpper(body), nil
+			},
+		}),
+	)
+	client := transport.Client()
+
+	// The derived variant of a miss is served with the status of the original request,
+	// which is observed by the hooks once.
+	rep, body := Get(t, client, origin.URL, "X-View", "upper")
+	require.Equal(t, "RESPONSE 1", body)
+	require.Equal(t, string(httpcache.CacheMiss), rep.Header.Get(httpcache.DefaultStatusHeader))
+	require.Equal(t, 1, misses)
+	require.Zero(t, hits)
+
+	rep, body = Get(t, client, origin.URL, "X-View", "upper")
+	require.Equal(t, "RESPONSE 1", body)
+	require.Equal(t, string(httpcache.CacheHit), rep.Header.Get(httpcache.DefaultStatusHeader))
+	require.Equal(t, 1, misses)
+	require.Equal(t, 1, hits)
+
+	// Responses that the derivation does not apply to are served unchanged.
+	rep, body = Get(t, client, origin.URL+"/image", "X-View", "upper")
+	require.Equal(t, "response 2", body)
+	require.Equal(t, string(httpcache.CacheMiss), rep.Header.Get(httpcache.DefaultStatusHeader))
+	require.Equal(t, 1, derived)
+
+	_, body = Get(t, client, origin.URL+"/image", "X-View", "upper")
+	require.Equal(t, "response 2", body)
+	require.Equal(t, 1, derived)
+	require.Equal(t, int64(2), origin.Requests())
+}
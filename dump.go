@@ -0,0 +1,109 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DumpBodyBytes is the maximum number of body bytes written by DumpEntry.
+const DumpBodyBytes = 512
+
+// DumpEntry writes a human readable description of the cached entry stored at key to
+// the writer, including its status, freshness, headers, and the first DumpBodyBytes of
+// the body. Binary bodies are written as a hex dump. It is intended for debugging.
+// Freshness is evaluated by a private cache at the current time; use
+// Transport.DumpEntry to evaluate it with the clock and mode of a Transport.
+func DumpEntry(cache Cache, key string, w io.Writer) error {
+	return NewTransport(cache).DumpEntry(key, w)
+}
+
+// DumpEntry writes a human readable description of the entry stored at key in the
+// cache of the Transport to the writer, see the DumpEntry function. The freshness of
+// the response is evaluated with the Clock of the Transport and as a shared cache if
+// Shared is set. If the key holds the vary index of responses that vary, the header
+// fields they vary on and the keys of the variants are written instead.
+func (t *Transport) DumpEntry(key string, w io.Writer) (err error) {
+	val, ok := t.Cache.Get(key)
+	if !ok {
+		return fmt.Errorf("no cached entry for key %q", key)
+	}
+
+	if vary, keys, isIndex := parseVaryIndex(val); isIndex {
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "Key:     %s\n", key)
+		fmt.Fprintf(buf, "Vary:    %s\n", strings.Join(vary, ", "))
+		fmt.Fprintf(buf, "Variants (%d):\n", len(keys))
+		for _, variant := range keys {
+			fmt.Fprintf(buf, "  %s\n", variant)
+		}
+		_, err = buf.WriteTo(w)
+		return err
+	}
+
+	var rep *http.Response
+	if rep, err = http.ReadResponse(bufio.NewReader(bytes.NewReader(val)), nil); err != nil {
+		return fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+	defer rep.Body.Close()
+
+	var body []byte
+	if body, err = io.ReadAll(rep.Body); err != nil {
+		return fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Key:     %s\n", key)
+	fmt.Fprintf(buf, "Size:    %d bytes\n", len(val))
+	fmt.Fprintf(buf, "Status:  %s %s\n", rep.Proto, rep.Status)
+
+	freshness := evaluateFreshness(rep, t.Shared, t.now())
+	state := "stale"
+	if freshness.fresh() {
+		state = "fresh"
+	}
+	if freshness.heuristic {
+		state += " (heuristic)"
+	}
+	fmt.Fprintf(buf, "State:   %s, age %s of %s lifetime\n", state, freshness.age.Truncate(time.Second), freshness.lifetime)
+
+	fmt.Fprintln(buf, "Headers:")
+	names := make([]string, 0, len(rep.Header))
+	for name := range rep.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range rep.Header[name] {
+			fmt.Fprintf(buf, "  %s: %s\n", name, value)
+		}
+	}
+
+	// Text is truncated at the start of a rune so that a multi-byte character cut by
+	// the limit does not cause the body to be dumped as binary.
+	n := min(len(body), DumpBodyBytes)
+	text := n
+	for i := 0; i < utf8.UTFMax && text > 0 && text < len(body) && !utf8.RuneStart(body[text]); i++ {
+		text--
+	}
+
+	if utf8.Valid(body[:text]) {
+		fmt.Fprintf(buf, "Body (%d of %d bytes):\n", text, len(body))
+		buf.Write(body[:text])
+		buf.WriteByte('\n')
+	} else {
+		fmt.Fprintf(buf, "Body (%d of %d bytes):\n", n, len(body))
+		buf.WriteString(hex.Dump(body[:n]))
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
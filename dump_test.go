@@ -0,0 +1,112 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestDumpEntry(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	body := strings.Repeat("a", httpcache.DumpBodyBytes+10)
+	cache.Put("text", []byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nCache-Control: max-age=60\r\nContent-Length: 522\r\n\r\n"+body))
+	cache.Put("binary", []byte("HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\n\xff\x00\xfe"))
+	cache.Put("corrupt", []byte("garbage"))
+
+	t.Run("Text", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.NoError(t, httpcache.DumpEntry(cache, "text", out))
+
+		// The state line depends on the current time so it is checked separately.
+		lines := strings.SplitN(out.String(), "\n", 5)
+		require.Equal(t, "State:   stale, age", lines[3][:19])
+		actual := strings.Join(append(lines[:3:3], lines[4]), "\n")
+
+		expected := "Key:     text\n" +
+			"Size:    615 bytes\n" +
+			"Status:  HTTP/1.1 200 OK\n" +
+			"Headers:\n" +
+			"  Cache-Control: max-age=60\n" +
+			"  Content-Length: 522\n" +
+			"  Content-Type: text/plain\n" +
+			"Body (512 of 522 bytes):\n" +
+			body[:httpcache.DumpBodyBytes] + "\n"
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.NoError(t, httpcache.DumpEntry(cache, "binary", out))
+		require.Contains(t, out.String(), "00000000  ff 00 fe")
+	})
+
+	t.Run("Rune", func(t *testing.T) {
+		// The limit falls within the two bytes of the last character.
+		body := strings.Repeat("a", httpcache.DumpBodyBytes-1) + "é"
+		cache.Put("rune", []byte("HTTP/1.1 200 OK\r\nContent-Length: 513\r\n\r\n"+body))
+
+		out := &bytes.Buffer{}
+		require.NoError(t, httpcache.DumpEntry(cache, "rune", out))
+		require.Contains(t, out.String(), "Body (511 of 513 bytes):\n"+body[:httpcache.DumpBodyBytes-1]+"\n")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		require.EqualError(t, httpcache.DumpEntry(cache, "missing", &bytes.Buffer{}), `no cached entry for key "missing"`)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		require.ErrorIs(t, httpcache.DumpEntry(cache, "corrupt", &bytes.Buffer{}), httpcache.ErrEntryCorrupt)
+	})
+}
+
+func TestTransportDumpEntry(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=10, s-maxage=120")
+		if r.URL.Path == "/vary" {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	clock := &fakeClock{now: time.Now()}
+	cache := &httpcache.InMemoryCache{}
+	transport := httpcache.NewTransport(cache, httpcache.WithClock(clock), httpcache.WithSharedMode())
+	client := transport.Client()
+	Get(t, client, origin.URL+"/vary", "Accept-Language", "en")
+	Get(t, client, origin.URL+"/vary", "Accept-Language", "fr")
+	Get(t, client, origin.URL+"/shared")
+
+	t.Run("Vary", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.NoError(t, transport.DumpEntry(origin.URL+"/vary", out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 5)
+		require.Equal(t, "Vary:    Accept-Language", lines[1])
+		require.Equal(t, "Variants (2):", lines[2])
+
+		// Every variant can be dumped with its key.
+		for _, line := range lines[3:] {
+			require.NoError(t, transport.DumpEntry(strings.TrimSpace(line), &bytes.Buffer{}))
+		}
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		// The response is fresh for a shared cache at the time of the Transport clock.
+		clock.Advance(time.Minute)
+		out := &bytes.Buffer{}
+		require.NoError(t, transport.DumpEntry(origin.URL+"/shared", out))
+		require.Contains(t, out.String(), "State:   fresh, age 1m0s of 2m0s lifetime")
+
+		out.Reset()
+		transport.Shared = false
+		require.NoError(t, transport.DumpEntry(origin.URL+"/shared", out))
+		require.Contains(t, out.String(), "State:   stale, age 1m0s of 10s lifetime")
+	})
+}
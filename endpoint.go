@@ -0,0 +1,136 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultEndpointRefresh = 5 * time.Minute
+	defaultEndpointJitter  = 0.1
+)
+
+// Endpoint keeps the body of a small, frequently polled resource such as a JSON Web
+// Key Set, an OpenID Connect discovery document, or a DNS-over-HTTPS answer available
+// to the application. The last successful response is pinned in memory and served
+// immediately while it is refreshed in the background, so callers are never delayed by
+// the origin once the first response has been fetched and a failing origin does not
+// cause the resource to be lost. Requests are made using the Client, which should use
+// a Transport so that refreshes are served from the cache or revalidated.
+type Endpoint struct {
+	// URL of the resource.
+	URL string
+
+	// Client used to fetch the resource; if nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Refresh is the interval after which the pinned response is refreshed (default
+	// 5 minutes).
+	Refresh time.Duration
+
+	// Jitter is the fraction of the refresh interval by which refreshes are randomly
+	// spread when the endpoint is run so that many clients do not poll in lockstep
+	// (default 0.1).
+	Jitter float64
+
+	mu         sync.RWMutex
+	body       []byte
+	fetched    time.Time
+	refreshing atomic.Bool
+}
+
+// Get returns the body of the pinned response, starting a background refresh if it is
+// older than the refresh interval. If no response has been pinned, the resource is
+// fetched before returning.
+func (e *Endpoint) Get(ctx context.Context) ([]byte, error) {
+	e.mu.RLock()
+	body, fetched := e.body, e.fetched
+	e.mu.RUnlock()
+
+	if body == nil {
+		return e.fetch(ctx)
+	}
+
+	if time.Since(fetched) >= e.refresh() && e.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer e.refreshing.Store(false)
+			if _, err := e.fetch(context.WithoutCancel(ctx)); err != nil {
+				GetLogger().Warn("could not refresh endpoint", slog.String("url", e.URL), slog.Any("error", err))
+			}
+		}()
+	}
+	return body, nil
+}
+
+// Run refreshes the pinned response at jittered intervals until the context is
+// canceled. Refresh failures are logged and the previous response remains pinned.
+func (e *Endpoint) Run(ctx context.Context) error {
+	for {
+		if _, err := e.fetch(ctx); err != nil && ctx.Err() == nil {
+			GetLogger().Warn("could not refresh endpoint", slog.String("url", e.URL), slog.Any("error", err))
+		}
+
+		select {
+		case <-time.After(e.interval()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fetch requests the resource and pins the body of a successful response.
+func (e *Endpoint) fetch(ctx context.Context) (body []byte, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, e.URL, nil); err != nil {
+		return nil, err
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var rep *http.Response
+	if rep, err = client.Do(req); err != nil {
+		return nil, err
+	}
+	defer rep.Body.Close()
+
+	if rep.StatusCode < 200 || rep.StatusCode > 299 {
+		return nil, fmt.Errorf("could not fetch %s: %s", e.URL, rep.Status)
+	}
+
+	if body, err = io.ReadAll(rep.Body); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.body, e.fetched = body, time.Now()
+	e.mu.Unlock()
+	return body, nil
+}
+
+func (e *Endpoint) refresh() time.Duration {
+	if e.Refresh > 0 {
+		return e.Refresh
+	}
+	return defaultEndpointRefresh
+}
+
+// interval returns the refresh interval randomly adjusted by up to the jitter fraction.
+func (e *Endpoint) interval() time.Duration {
+	jitter := e.Jitter
+	if jitter <= 0 {
+		jitter = defaultEndpointJitter
+	}
+
+	refresh := float64(e.refresh())
+	return time.Duration(refresh + refresh*jitter*(2*rand.Float64()-1))
+}
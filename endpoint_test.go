@@ -0,0 +1,86 @@
+package httpcache_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestEndpoint(t *testing.T) {
+	var failing atomic.Bool
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"keys":%d}`, n)
+	})
+
+	endpoint := &httpcache.Endpoint{
+		URL:     origin.URL,
+		Client:  httpcache.NewTransport(&httpcache.InMemoryCache{}).Client(),
+		Refresh: 10 * time.Millisecond,
+	}
+
+	body, err := endpoint.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, `{"keys":1}`, string(body))
+
+	// The pinned body is served while it is refreshed in the background.
+	time.Sleep(20 * time.Millisecond)
+	body, err = endpoint.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, `{"keys":1}`, string(body))
+
+	require.Eventually(t, func() bool {
+		body, _ := endpoint.Get(context.Background())
+		return string(body) == `{"keys":2}`
+	}, time.Second, time.Millisecond)
+
+	// A failing origin does not replace the pinned body.
+	failing.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	endpoint.Get(context.Background())
+	require.Eventually(t, func() bool { return origin.Requests() >= 3 }, time.Second, time.Millisecond)
+
+	body, err = endpoint.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, `{"keys":2}`, string(body))
+}
+
+func TestEndpointError(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		http.NotFound(w, r)
+	})
+
+	endpoint := &httpcache.Endpoint{URL: origin.URL}
+	_, err := endpoint.Get(context.Background())
+	require.EqualError(t, err, "could not fetch "+origin.URL+": 404 Not Found")
+}
+
+func TestEndpointRun(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	endpoint := &httpcache.Endpoint{URL: origin.URL, Refresh: 5 * time.Millisecond, Jitter: 0.5}
+	done := make(chan error, 1)
+	go func() { done <- endpoint.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return origin.Requests() >= 3 }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	body, err := endpoint.Get(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, string(body), "response")
+}
@@ -0,0 +1,36 @@
+package httpcache
+
+import "errors"
+
+var (
+	// ErrEntryCorrupt is returned when a cached value cannot be parsed back into an
+	// http.Response, e.g. because it was truncated or written by an incompatible version.
+	// The Transport removes such entries and passes the error to Hooks.OnError.
+	ErrEntryCorrupt = errors.New("cached entry is corrupt")
+
+	// ErrBackendUnavailable is returned by the error-aware methods of cache backends,
+	// e.g. Sizer.Size, when the underlying storage has been closed.
+	ErrBackendUnavailable = errors.New("cache backend is unavailable")
+
+	// ErrOnlyIfCachedMiss is passed to Hooks.OnError when a request specifies
+	// only-if-cached but no suitable stored response is available. The request itself is
+	// answered with 504 Gateway Timeout as RFC 9111 requires.
+	ErrOnlyIfCachedMiss = errors.New("no cached response available for only-if-cached request")
+
+	// ErrTooLarge is passed to Hooks.OnError when a response is not stored because its
+	// body exceeds the maximum body size. The response is still served to the caller.
+	ErrTooLarge = errors.New("response is too large to be cached")
+
+	// ErrInsecureConfig is returned by Transport.Strict and NewStrictTransport when the
+	// Transport is configured in a way that may serve one client's responses or
+	// credentials to another.
+	ErrInsecureConfig = errors.New("insecure cache configuration")
+
+	// ErrKeyMismatch is passed to Hooks.OnError when VerifyKeys is set and a stored
+	// response was found at the key of a request that it was not stored for.
+	ErrKeyMismatch = errors.New("cached entry does not match the request")
+
+	// ErrKeysUnsupported is returned when an operation must enumerate the entries of a
+	// cache that does not implement KeyLister.
+	ErrKeysUnsupported = errors.New("cache does not support listing keys")
+)
@@ -0,0 +1,214 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// MaxEscapedKeyLength is the maximum length of a key returned by EscapeKey; it is the
+// limit of memcached keys and below the 255 byte limit of file names.
+const MaxEscapedKeyLength = 250
+
+const (
+	escapeMark = '_'
+	hashedMark = 'h'
+	lowerHex   = "0123456789abcdef"
+)
+
+// hashedKeyLength is the length of the marker and hash that replace the end of keys
+// that are too long.
+const hashedKeyLength = 2 + 16
+
+// reservedNames are device names that cannot be used as file names on Windows, even
+// with an extension.
+var reservedNames = map[string]struct{}{
+	"con": {}, "prn": {}, "aux": {}, "nul": {},
+	"com1": {}, "com2": {}, "com3": {}, "com4": {}, "com5": {}, "com6": {}, "com7": {}, "com8": {}, "com9": {},
+	"lpt1": {}, "lpt2": {}, "lpt3": {}, "lpt4": {}, "lpt5": {}, "lpt6": {}, "lpt7": {}, "lpt8": {}, "lpt9": {},
+}
+
+// EscapeKey returns a form of the key that is safe to use with any backend: as a file
+// name on case-insensitive file systems, as a memcached key and as an S3 object name.
+// Escaped keys only contain lowercase ASCII letters, digits, '-', '.' and '_', do not
+// begin or end with '.', are never reserved device names and are at most
+// MaxEscapedKeyLength bytes long. Every other byte, including uppercase letters, is
+// escaped as '_' followed by two lowercase hex digits. Keys that are too long are
+// truncated and suffixed with "_h" and the hash of the key, which cannot be reversed by
+// UnescapeKey; distinct keys always produce distinct escaped keys unless their hashes
+// collide.
+func EscapeKey(key string) string {
+	if key == "" {
+		return string(escapeMark)
+	}
+
+	var b strings.Builder
+	b.Grow(len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if safeKeyByte(c) && !(c == '.' && (i == 0 || i == len(key)-1)) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte(escapeMark)
+		b.WriteByte(lowerHex[c>>4])
+		b.WriteByte(lowerHex[c&0x0f])
+	}
+
+	escaped := b.String()
+	name, _, _ := strings.Cut(escaped, ".")
+	if _, ok := reservedNames[name]; ok {
+		escaped = string([]byte{escapeMark, lowerHex[escaped[0]>>4], lowerHex[escaped[0]&0x0f]}) + escaped[1:]
+	}
+
+	if len(escaped) <= MaxEscapedKeyLength {
+		return escaped
+	}
+
+	// Truncate the key without splitting an escape sequence.
+	cut := MaxEscapedKeyLength - hashedKeyLength
+	if i := strings.LastIndexByte(escaped[cut-2:cut], escapeMark); i >= 0 {
+		cut = cut - 2 + i
+	}
+
+	hash := strconv.FormatUint(HashKey(key), 16)
+	return escaped[:cut] + string([]byte{escapeMark, hashedMark}) + strings.Repeat("0", 16-len(hash)) + hash
+}
+
+// UnescapeKey returns the key that was escaped by EscapeKey. It returns false if the
+// escaped key is malformed or was truncated because the key was too long.
+func UnescapeKey(escaped string) (string, bool) {
+	if escaped == string(escapeMark) {
+		return "", true
+	}
+
+	var b strings.Builder
+	b.Grow(len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != escapeMark {
+			if !safeKeyByte(c) {
+				return "", false
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		if i+2 >= len(escaped) {
+			return "", false
+		}
+
+		hi, lo := strings.IndexByte(lowerHex, escaped[i+1]), strings.IndexByte(lowerHex, escaped[i+2])
+		if hi < 0 || lo < 0 {
+			return "", false
+		}
+		b.WriteByte(byte(hi<<4 | lo))
+		i += 2
+	}
+	return b.String(), true
+}
+
+// safeKeyByte returns true for the bytes that are not escaped.
+func safeKeyByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.'
+}
+
+// EscapeKeys returns a cache that escapes keys with EscapeKey before they are passed to
+// the underlying cache, so that arbitrary URLs and header values can be used as keys
+// with backends that restrict the bytes or length of keys. Entries with truncated keys
+// store the original key with the value so that hash collisions are detected and the
+// keys can be listed. If the underlying cache implements KeyLister, so does the
+// returned cache.
+func EscapeKeys(cache Cache) Cache {
+	escaped := escapedCache{cache: cache}
+	if lister, ok := cache.(KeyLister); ok {
+		return &escapedLister{escapedCache: escaped, lister: lister}
+	}
+	return &escaped
+}
+
+type escapedCache struct {
+	cache Cache
+}
+
+func (c *escapedCache) Get(key string) ([]byte, bool) {
+	escaped := EscapeKey(key)
+	val, ok := c.cache.Get(escaped)
+	if !ok || !truncatedKey(escaped) {
+		return val, ok
+	}
+
+	stored, val, ok := splitKeyed(val)
+	if !ok || stored != key {
+		return nil, false
+	}
+	return val, true
+}
+
+func (c *escapedCache) Put(key string, val []byte) {
+	escaped := EscapeKey(key)
+	if truncatedKey(escaped) {
+		keyed := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(key)+len(val)), uint64(len(key)))
+		keyed = append(append(keyed, key...), val...)
+		val = keyed
+	}
+	c.cache.Put(escaped, val)
+}
+
+func (c *escapedCache) Del(key string) {
+	c.cache.Del(EscapeKey(key))
+}
+
+func (c *escapedCache) Flush(ctx context.Context) error {
+	return FlushCache(ctx, c.cache)
+}
+
+func (c *escapedCache) Size() (int64, error) {
+	size, _, err := CacheSize(c.cache)
+	return size, err
+}
+
+type escapedLister struct {
+	escapedCache
+	lister KeyLister
+}
+
+// Keys returns the unescaped keys of the underlying cache; keys that were not escaped
+// by the cache are skipped.
+func (c *escapedLister) Keys() []string {
+	escaped := c.lister.Keys()
+	keys := make([]string, 0, len(escaped))
+	for _, escaped := range escaped {
+		if key, ok := UnescapeKey(escaped); ok {
+			keys = append(keys, key)
+			continue
+		}
+
+		if !truncatedKey(escaped) {
+			continue
+		}
+
+		if val, ok := c.cache.Get(escaped); ok {
+			if key, _, ok := splitKeyed(val); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// truncatedKey returns true if the escaped key was truncated and ends with a hash.
+func truncatedKey(escaped string) bool {
+	n := len(escaped) - hashedKeyLength
+	return n >= 0 && escaped[n] == escapeMark && escaped[n+1] == hashedMark
+}
+
+// splitKeyed splits a value stored for a truncated key into the key and the value.
+func splitKeyed(val []byte) (string, []byte, bool) {
+	n, size := binary.Uvarint(val)
+	if size <= 0 || uint64(len(val)-size) < n {
+		return "", nil, false
+	}
+	return string(val[size : size+int(n)]), val[size+int(n):], true
+}
@@ -0,0 +1,145 @@
+package httpcache_test
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// safeKey matches the keys that are safe for every backend.
+var safeKey = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+func requireSafeKey(t *testing.T, key, escaped string) {
+	require.Regexp(t, safeKey, escaped, "Test Case: %q", key)
+	require.LessOrEqual(t, len(escaped), httpcache.MaxEscapedKeyLength, "Test Case: %q", key)
+	require.False(t, strings.HasPrefix(escaped, "."), "Test Case: %q", key)
+	require.False(t, strings.HasSuffix(escaped, "."), "Test Case: %q", key)
+}
+
+func TestEscapeKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"", "_"},
+		{"abc-123.json", "abc-123.json"},
+		{"http://example.com/a?b=c", "http_3a_2f_2fexample.com_2fa_3fb_3dc"},
+		{"GET /Path", "_47_45_54_20_2f_50ath"},
+		{"_", "_5f"},
+		{".", "_2e"},
+		{"..", "_2e_2e"},
+		{".hidden", "_2ehidden"},
+		{"trailing.", "trailing_2e"},
+		{"con", "_63on"},
+		{"nul.txt", "_6eul.txt"},
+		{"console", "console"},
+		{"\x00\xff", "_00_ff"},
+		{"ü", "_c3_bc"},
+	}
+
+	for _, test := range tests {
+		escaped := httpcache.EscapeKey(test.key)
+		require.Equal(t, test.expected, escaped, "Test Case: %q", test.key)
+		requireSafeKey(t, test.key, escaped)
+
+		key, ok := httpcache.UnescapeKey(escaped)
+		require.True(t, ok, "Test Case: %q", test.key)
+		require.Equal(t, test.key, key, "Test Case: %q", test.key)
+	}
+}
+
+func TestEscapeKeyBytes(t *testing.T) {
+	// Every byte value is escaped safely at the start, middle and end of a key.
+	for c := 0; c < 256; c++ {
+		for _, key := range []string{string([]byte{byte(c)}), "a" + string([]byte{byte(c)}) + "b", "ab" + string([]byte{byte(c)})} {
+			escaped := httpcache.EscapeKey(key)
+			requireSafeKey(t, key, escaped)
+
+			unescaped, ok := httpcache.UnescapeKey(escaped)
+			require.True(t, ok, "Test Case: %q", key)
+			require.Equal(t, key, unescaped, "Test Case: %q", key)
+		}
+	}
+}
+
+func TestEscapeKeyLength(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	seen := make(map[string]string)
+	for n := 0; n < 400; n++ {
+		buf := make([]byte, n)
+		rng.Read(buf)
+		for _, key := range []string{string(buf), strings.Repeat("a", n), strings.Repeat("/", n)} {
+			escaped := httpcache.EscapeKey(key)
+			requireSafeKey(t, key, escaped)
+
+			if other, ok := seen[escaped]; ok {
+				require.Equal(t, other, key, "expected distinct keys to be escaped distinctly")
+			}
+			seen[escaped] = key
+
+			// Only keys that were truncated and hashed cannot be unescaped.
+			unescaped, ok := httpcache.UnescapeKey(escaped)
+			require.Equal(t, !strings.Contains(escaped, "_h"), ok, "Test Case: %q", key)
+			if ok {
+				require.Equal(t, key, unescaped, "Test Case: %q", key)
+			}
+		}
+	}
+
+	long := strings.Repeat("x", 1000)
+	escaped := httpcache.EscapeKey(long)
+	require.Len(t, escaped, httpcache.MaxEscapedKeyLength)
+	_, ok := httpcache.UnescapeKey(escaped)
+	require.False(t, ok, "expected truncated keys not to be reversible")
+}
+
+func TestUnescapeKeyMalformed(t *testing.T) {
+	for _, escaped := range []string{"A", "a b", "_4", "_4g", "_4F", "abc_", "/"} {
+		_, ok := httpcache.UnescapeKey(escaped)
+		require.False(t, ok, "Test Case: %q", escaped)
+	}
+}
+
+func TestEscapeKeys(t *testing.T) {
+	inner := &httpcache.InMemoryCache{}
+	cache := httpcache.EscapeKeys(inner)
+
+	short := "http://example.com/a b"
+	long := "http://example.com/" + strings.Repeat("Ü", 200)
+	cache.Put(short, []byte("short"))
+	cache.Put(long, []byte("long"))
+
+	for key, expected := range map[string]string{short: "short", long: "long"} {
+		val, ok := cache.Get(key)
+		require.True(t, ok, "Test Case: %q", key)
+		require.Equal(t, expected, string(val), "Test Case: %q", key)
+	}
+
+	for _, key := range inner.Keys() {
+		require.Regexp(t, safeKey, key)
+	}
+
+	lister, ok := cache.(httpcache.KeyLister)
+	require.True(t, ok, "expected the cache to list keys")
+	require.ElementsMatch(t, []string{short, long}, lister.Keys())
+
+	// An entry stored for a different key under the same truncated key is a miss.
+	other := long + "x"
+	cache.Put(other, []byte("other"))
+	val, ok := inner.Get(httpcache.EscapeKey(other))
+	require.True(t, ok)
+	inner.Put(httpcache.EscapeKey(long), val)
+	_, ok = cache.Get(long)
+	require.False(t, ok, "expected a hash collision to be detected")
+
+	cache.Del(other)
+	_, ok = cache.Get(other)
+	require.False(t, ok)
+
+	_, ok = httpcache.EscapeKeys(nopCache{}).(httpcache.KeyLister)
+	require.False(t, ok, "expected a cache without key listing not to list keys")
+}
@@ -1,9 +1,80 @@
 package httpcache
 
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
 var (
 	CacheKey              = cacheKey
 	CacheKeyWithHeaders   = cacheKeyWithHeaders
 	CacheKeyWithVary      = cacheKeyWithVary
 	Normalize             = normalize
 	CachedResponseWithKey = cachedResponse
+	SetStoredTimes        = setStoredTimes
+	NegotiateLanguage     = negotiateLanguage
+	MatchGlob             = matchGlob
+)
+
+const (
+	HeaderRequestTime  = headerRequestTime
+	HeaderResponseTime = headerResponseTime
 )
+
+// Key returns the primary cache key for the request.
+func (t *Transport) Key(req *http.Request) string {
+	return t.policy(req).key(req)
+}
+
+// TrackedKeys returns the number of keys whose accesses are tracked.
+func (t *Transport) TrackedKeys() int {
+	t.accesses.mu.Lock()
+	defer t.accesses.mu.Unlock()
+	return len(t.accesses.last)
+}
+
+func (w *MemoryWatcher) Check(heap uint64) int {
+	return w.check(heap)
+}
+
+func ParseCacheControl(header http.Header) map[string]string {
+	return parseCacheControl(header)
+}
+
+func RequestCacheControl(header http.Header) map[string]string {
+	return requestCacheControl(header)
+}
+
+func EvaluateFreshness(rep *http.Response, shared bool, now time.Time) (lifetime, age time.Duration, heuristic bool) {
+	f := evaluateFreshness(rep, shared, now)
+	return f.lifetime, f.age, f.heuristic
+}
+
+func ParseRange(header string, size int64) (start, end int64, satisfiable, ok bool) {
+	r, satisfiable, ok := parseRange(header, size)
+	return r.start, r.end, satisfiable, ok
+}
+
+// Tee is a tee of a body whose buffered bytes can be inspected.
+type Tee struct {
+	tee *tee
+}
+
+func NewTee(src io.ReadCloser, limit int64, done func()) *Tee {
+	return &Tee{tee: newTee(src, limit, done)}
+}
+
+func (t *Tee) Attach() io.ReadCloser {
+	return t.tee.attach()
+}
+
+func (t *Tee) Pump() {
+	t.tee.pump()
+}
+
+func (t *Tee) Buffered() int {
+	t.tee.mu.Lock()
+	defer t.tee.mu.Unlock()
+	return len(t.tee.buf)
+}
@@ -0,0 +1,116 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+)
+
+// Flusher is implemented by caches that apply or persist writes asynchronously, e.g.
+// caches that buffer writes or write behind to a slower store, and by wrappers of other
+// caches. Caches whose writes are visible to Get once Put or Del return do not need to
+// implement it.
+type Flusher interface {
+	// Flush blocks until all writes made before it was called are visible to Get and
+	// persisted by the backend, or until the context is done.
+	Flush(ctx context.Context) error
+}
+
+// Waiter is implemented by caches that buffer writes, such as the ristretto backend,
+// whose Wait method blocks until the buffered writes have been applied.
+type Waiter interface {
+	Wait()
+}
+
+// FlushCache flushes the cache if it implements Flusher or waits for its buffered
+// writes to be applied if it implements Waiter. Otherwise the writes of the cache are
+// already visible and FlushCache returns immediately. Wrappers of other caches should
+// implement Flusher by calling FlushCache with the wrapped cache.
+func FlushCache(ctx context.Context, cache Cache) error {
+	switch c := cache.(type) {
+	case Flusher:
+		return c.Flush(ctx)
+	case Waiter:
+		done := make(chan struct{})
+		go func() {
+			c.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	default:
+		return nil
+	}
+}
+
+// Flush sends pending batch revalidations, waits for the background tasks of the
+// Transport that write to the cache, such as background and batched revalidations, and
+// then flushes the cache with FlushCache, so that tests and shutdown paths can ensure
+// that all writes are visible and persisted. It returns early with the context's error
+// if the context is done first. Responses whose bodies have not been completely read
+// are not stored yet and are not waited for.
+func (t *Transport) Flush(ctx context.Context) (err error) {
+	t.batches.mu.Lock()
+	t.sendBatch()
+	t.batches.mu.Unlock()
+
+	if err = t.tasks.wait(ctx); err != nil {
+		return err
+	}
+
+	if t.Cache == nil {
+		return nil
+	}
+	return FlushCache(ctx, t.Cache)
+}
+
+// tasks counts the background tasks of the Transport that write to the cache so that
+// Flush can wait for them to complete.
+type tasks struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+// start records a task that has started; done must be called when it completes.
+func (t *tasks) start() {
+	t.mu.Lock()
+	t.n++
+	t.mu.Unlock()
+}
+
+func (t *tasks) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.n--; t.n == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// wait blocks until no tasks are running or the context is done.
+func (t *tasks) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.n == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,129 @@
+package httpcache_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// flushingCache counts the calls to Flush.
+type flushingCache struct {
+	httpcache.InMemoryCache
+	flushes atomic.Int64
+}
+
+func (c *flushingCache) Flush(ctx context.Context) error {
+	c.flushes.Add(1)
+	return nil
+}
+
+// waitingCache blocks in Wait until it is released.
+type waitingCache struct {
+	httpcache.InMemoryCache
+	release chan struct{}
+}
+
+func (c *waitingCache) Wait() {
+	<-c.release
+}
+
+func TestFlushCache(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, httpcache.FlushCache(ctx, &httpcache.InMemoryCache{}))
+
+	flushing := &flushingCache{}
+	require.NoError(t, httpcache.FlushCache(ctx, flushing))
+	require.Equal(t, int64(1), flushing.flushes.Load())
+
+	// Wrappers flush the caches they wrap.
+	wrapped := httpcache.EscapeKeys(httpcache.CopyValues(flushing))
+	require.Implements(t, (*httpcache.Flusher)(nil), wrapped)
+	require.NoError(t, httpcache.FlushCache(ctx, wrapped))
+	require.Equal(t, int64(2), flushing.flushes.Load())
+
+	waiting := &waitingCache{release: make(chan struct{})}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, httpcache.FlushCache(timeout, waiting), context.DeadlineExceeded)
+
+	close(waiting.release)
+	require.NoError(t, httpcache.FlushCache(ctx, waiting))
+}
+
+func TestTransportFlush(t *testing.T) {
+	release := make(chan struct{})
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if n > 1 {
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &flushingCache{}
+	transport := httpcache.NewTransport(cache)
+	client := transport.Client()
+
+	Get(t, client, origin.URL)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	// The stale response is revalidated in the background until the origin responds.
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, transport.Flush(timeout), context.DeadlineExceeded)
+	require.Zero(t, cache.flushes.Load())
+
+	close(release)
+	require.NoError(t, transport.Flush(context.Background()))
+	require.Equal(t, int64(1), cache.flushes.Load())
+
+	rep, err := httpcache.CachedResponse(cache, mustRequest(t, origin.URL))
+	require.NoError(t, err)
+	defer rep.Body.Close()
+
+	stored, err := io.ReadAll(rep.Body)
+	require.NoError(t, err)
+	require.Equal(t, "response 2", string(stored))
+}
+
+func TestTransportFlushBatches(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=3600")
+		w.Header().Set("ETag", `"current"`)
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	revalidator := &batchRevalidator{current: `"current"`}
+	transport := &httpcache.Transport{
+		Cache:            &httpcache.InMemoryCache{},
+		Clock:            clock,
+		BatchRevalidator: revalidator,
+		BatchDelay:       time.Hour,
+	}
+	client := transport.Client()
+
+	Get(t, client, origin.URL)
+	clock.Advance(2 * time.Second)
+	Get(t, client, origin.URL)
+	require.Empty(t, revalidator.Batches())
+
+	// Flush sends the pending batch without waiting for the delay.
+	require.NoError(t, transport.Flush(context.Background()))
+	require.Len(t, revalidator.Batches(), 1)
+}
+
+func mustRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return req
+}
@@ -0,0 +1,96 @@
+package httpcache
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"time"
+)
+
+// Forecast is a histogram of the remaining freshness lifetimes of the entries stored in
+// the cache, computed from a sample of their metadata. It allows operators to anticipate
+// spikes of requests to the origin when large cohorts of entries expire together.
+type Forecast struct {
+	// Interval is the width of each bucket of the histogram.
+	Interval time.Duration
+
+	// Entries is the number of keys in the cache and Sampled is the number of keys whose
+	// entries were inspected. Keys that do not store a response (e.g. vary indexes or
+	// corrupt entries) are sampled but are not counted in any bucket.
+	Entries int
+	Sampled int
+
+	// Stale is the number of sampled entries that are already stale.
+	Stale int
+
+	// Expiring is the number of sampled entries that become stale in each upcoming
+	// interval, i.e. Expiring[i] entries expire between i and i+1 intervals from now.
+	Expiring []int
+
+	// Later is the number of sampled entries that remain fresh beyond the last interval.
+	Later int
+}
+
+// Estimate returns the estimated number of entries in the whole cache that become stale
+// in the ith upcoming interval, scaling the sampled count by the sampling ratio.
+func (f *Forecast) Estimate(i int) int {
+	if i < 0 || i >= len(f.Expiring) || f.Sampled == 0 {
+		return 0
+	}
+	return f.Expiring[i] * f.Entries / f.Sampled
+}
+
+// Forecast samples the metadata of up to sample randomly chosen entries (all entries if
+// sample is not positive) and returns a histogram of when they expire over the specified
+// number of upcoming intervals. If the interval is not positive, all fresh entries are
+// counted as Later. The cache must implement KeyLister otherwise
+// ErrKeysUnsupported is returned.
+func (t *Transport) Forecast(interval time.Duration, intervals, sample int) (*Forecast, error) {
+	lister, ok := t.Cache.(KeyLister)
+	if !ok {
+		return nil, ErrKeysUnsupported
+	}
+
+	keys := lister.Keys()
+	entries := len(keys)
+	if sample > 0 && sample < len(keys) {
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		keys = keys[:sample]
+	}
+
+	if interval <= 0 {
+		intervals = 0
+	}
+
+	forecast := &Forecast{
+		Interval: interval,
+		Entries:  entries,
+		Expiring: make([]int, max(intervals, 0)),
+	}
+
+	for _, key := range keys {
+		val, ok := t.Cache.Get(key)
+		if !ok {
+			continue
+		}
+		forecast.Sampled++
+
+		if bytes.HasPrefix(val, []byte(varyIndexPrefix)) {
+			continue
+		}
+
+		meta, err := t.entryMetadata(val, t.now())
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case !meta.Fresh():
+			forecast.Stale++
+		case len(forecast.Expiring) > 0 && int((meta.Lifetime-meta.Age)/interval) < len(forecast.Expiring):
+			forecast.Expiring[(meta.Lifetime-meta.Age)/interval]++
+		default:
+			forecast.Later++
+		}
+	}
+	return forecast, nil
+}
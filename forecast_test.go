@@ -0,0 +1,86 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestForecast(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/minute":
+			w.Header().Set("Cache-Control", "max-age=90")
+		case "/hour":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/stale":
+			w.Header().Set("Cache-Control", "no-cache")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=30")
+			w.Header().Set("Vary", "Accept")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+	for _, path := range []string{"/minute", "/hour", "/stale", "/vary"} {
+		Get(t, client, origin.URL+path)
+	}
+
+	forecast, err := transport.Forecast(time.Minute, 3, 0)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, forecast.Interval)
+	require.Equal(t, 5, forecast.Entries, "expected the vary index to be listed")
+	require.Equal(t, 5, forecast.Sampled)
+	require.Equal(t, 1, forecast.Stale)
+	require.Equal(t, []int{1, 1, 0}, forecast.Expiring)
+	require.Equal(t, 1, forecast.Later)
+	require.Equal(t, 1, forecast.Estimate(0))
+	require.Equal(t, 0, forecast.Estimate(3))
+
+	forecast, err = transport.Forecast(time.Minute, 3, 2)
+	require.NoError(t, err)
+	require.Equal(t, 5, forecast.Entries)
+	require.Equal(t, 2, forecast.Sampled)
+
+	transport = httpcache.NewTransport(nopCache{})
+	_, err = transport.Forecast(time.Minute, 3, 0)
+	require.ErrorIs(t, err, httpcache.ErrKeysUnsupported)
+}
+
+func TestForecastPolicy(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/shared":
+			w.Header().Set("Cache-Control", "max-age=3600, s-maxage=30")
+		case "/rule/ttl":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// The entries are forecast with the freshness that lookups would compute.
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithSharedMode(),
+		httpcache.WithNegativeCaching(150*time.Second, http.StatusNotFound),
+		httpcache.WithRules(httpcache.Rule{Path: "/rule/*", TTL: 90 * time.Second}),
+	)
+	client := transport.Client()
+	for _, path := range []string{"/shared", "/rule/ttl", "/missing"} {
+		Get(t, client, origin.URL+path)
+	}
+
+	forecast, err := transport.Forecast(time.Minute, 3, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, forecast.Sampled)
+	require.Zero(t, forecast.Stale)
+	require.Equal(t, []int{1, 1, 1}, forecast.Expiring)
+	require.Zero(t, forecast.Later)
+}
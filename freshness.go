@@ -0,0 +1,253 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// heuristicStatus are the status codes that are cacheable by default and may be
+// assigned a heuristic freshness lifetime (RFC 9110 §15.1).
+var heuristicStatus = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusNotFound:             {},
+	http.StatusMethodNotAllowed:     {},
+	http.StatusGone:                 {},
+	http.StatusRequestURITooLong:    {},
+	http.StatusNotImplemented:       {},
+}
+
+// freshness is the result of evaluating a stored response at a point in time.
+type freshness struct {
+	lifetime             time.Duration
+	age                  time.Duration
+	heuristic            bool
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	noCache              bool
+	immutable            bool
+	maxStale             time.Duration
+	rejected             bool
+	mustRevalidate       bool
+}
+
+// fresh returns true if the response may be served without contacting the origin.
+// Responses that require revalidation (no-cache) or that do not satisfy the request
+// directives are never fresh; stale responses are accepted within the max-stale window
+// requested by the client unless the response must be revalidated once stale.
+func (f freshness) fresh() bool {
+	maxStale := f.maxStale
+	if f.mustRevalidate {
+		maxStale = 0
+	}
+	return !f.noCache && !f.rejected && f.age < f.lifetime+maxStale
+}
+
+// revalidateInBackground returns true if the response is stale but may be served while
+// it is revalidated in the background (RFC 5861 §3).
+func (f freshness) revalidateInBackground() bool {
+	return !f.noCache && !f.rejected && !f.mustRevalidate && !f.fresh() && f.age < f.lifetime+f.staleWhileRevalidate
+}
+
+// expired returns true if the response is stale beyond any window in which it may be
+// served stale to any request. Responses that must be revalidated once stale can never
+// be served stale; other responses may be served stale to requests with max-stale or
+// stale-if-error directives, whose largest allowance is maxDeltaSeconds. It must be
+// evaluated before the request directives are applied.
+func (f freshness) expired() bool {
+	if !f.servableStale() {
+		return f.age >= f.lifetime
+	}
+	return f.age >= f.lifetime+maxDeltaSeconds*time.Second
+}
+
+// servableStale returns true if the response may ever be served stale, i.e. it does not
+// require revalidation once stale (RFC 9111 §5.2.2.2 and §5.2.2.8).
+func (f freshness) servableStale() bool {
+	return !f.noCache && !f.mustRevalidate
+}
+
+// request applies the Cache-Control directives of the request that tighten or relax
+// the freshness requirements of the client (RFC 9111 §5.2.1). Requests to revalidate
+// an immutable response are ignored while it is fresh (RFC 8246).
+func (f *freshness) request(cc cacheControl) {
+	if f.immutable && f.age < f.lifetime {
+		return
+	}
+
+	if cc.has("no-cache") {
+		f.noCache = true
+	}
+
+	if maxAge, ok := cc.seconds("max-age"); ok && f.age > maxAge {
+		f.rejected = true
+	}
+
+	if minFresh, ok := cc.seconds("min-fresh"); ok && f.lifetime-f.age < minFresh {
+		f.rejected = true
+	}
+
+	// A max-stale directive without a value accepts a stale response of any age.
+	if maxStale, ok := cc["max-stale"]; ok {
+		if maxStale == "" {
+			f.maxStale = maxDeltaSeconds * time.Second
+		} else {
+			f.maxStale, _ = deltaSeconds(maxStale)
+		}
+	}
+}
+
+// staleIfError returns true if the stale response may be served because the origin
+// could not be reached or returned a server error (RFC 5861 §4). The stale-if-error
+// directive of the request takes precedence over that of the response. Responses that
+// require revalidation are never served stale.
+func staleIfError(req *http.Request, f freshness) bool {
+	if !f.servableStale() {
+		return false
+	}
+
+	window := f.staleIfError
+	if reqwindow, ok := parseCacheControl(req.Header).seconds("stale-if-error"); ok {
+		window = reqwindow
+	}
+	return f.age < f.lifetime+window
+}
+
+// serverError returns true for the status codes that are considered errors by the
+// stale-if-error extension.
+func serverError(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// evaluateFreshness computes the freshness lifetime and current age of a stored
+// response at the specified time. If shared is true, s-maxage takes precedence.
+func evaluateFreshness(rep *http.Response, shared bool, now time.Time) freshness {
+	cc := parseCacheControl(rep.Header)
+	requestTime, responseTime := storedTimes(rep.Header)
+	date := dateOf(rep.Header, responseTime)
+
+	f := freshness{age: currentAge(rep.Header, date, requestTime, responseTime, now)}
+	f.lifetime, f.heuristic = freshnessLifetime(rep, cc, date, shared)
+	if rep.Header.Get(headerPurged) != "" {
+		f.lifetime = 0
+	}
+	f.staleWhileRevalidate, _ = cc.seconds("stale-while-revalidate")
+	f.staleIfError, _ = cc.seconds("stale-if-error")
+	f.noCache = cc.has("no-cache")
+	f.immutable = cc.has("immutable")
+
+	// A shared cache treats s-maxage as implying proxy-revalidate (RFC 9111 §5.2.2.10).
+	f.mustRevalidate = cc.has("must-revalidate") || (shared && (cc.has("proxy-revalidate") || cc.has("s-maxage")))
+	return f
+}
+
+// freshnessLifetime returns how long the response is fresh for after it was generated
+// and whether the lifetime was computed heuristically (RFC 9111 §4.2.1).
+func freshnessLifetime(rep *http.Response, cc cacheControl, date time.Time, shared bool) (time.Duration, bool) {
+	if shared {
+		if lifetime, ok := cc.seconds("s-maxage"); ok {
+			return lifetime, false
+		}
+	}
+
+	if lifetime, ok := cc.seconds("max-age"); ok {
+		return lifetime, false
+	}
+
+	// Invalid dates, e.g. "0", represent a time in the past (RFC 9111 §5.3).
+	if value := rep.Header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			return 0, false
+		}
+		return max(expires.Sub(date), 0), false
+	}
+
+	// Heuristic freshness is 10% of the time since the response was last modified.
+	if _, ok := heuristicStatus[rep.StatusCode]; ok || cc.has("public") {
+		if modified, err := http.ParseTime(rep.Header.Get("Last-Modified")); err == nil && modified.Before(date) {
+			return date.Sub(modified) / 10, true
+		}
+	}
+
+	return 0, false
+}
+
+// explicitExpiration returns true if the response specifies its freshness lifetime
+// rather than relying on a heuristic or cache configuration.
+func explicitExpiration(header http.Header, shared bool) bool {
+	cc := parseCacheControl(header)
+	return cc.has("max-age") || (shared && cc.has("s-maxage")) || header.Get("Expires") != ""
+}
+
+// currentAge estimates the time since the response was generated by the origin server
+// using the calculation in RFC 9111 §4.2.3.
+func currentAge(header http.Header, date, requestTime, responseTime, now time.Time) time.Duration {
+	ageValue, _ := deltaSeconds(header.Get("Age"))
+	apparentAge := max(responseTime.Sub(date), 0)
+	responseDelay := responseTime.Sub(requestTime)
+	correctedAgeValue := ageValue + responseDelay
+	correctedInitialAge := max(apparentAge, correctedAgeValue)
+	residentTime := now.Sub(responseTime)
+	return correctedInitialAge + residentTime
+}
+
+// dateOf returns the parsed Date header or the fallback if it is missing or invalid.
+func dateOf(header http.Header, fallback time.Time) time.Time {
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		return date
+	}
+	return fallback
+}
+
+// setStoredTimes records the request and response times on the header.
+func setStoredTimes(header http.Header, requestTime, responseTime time.Time) {
+	header.Set(headerRequestTime, strconv.FormatInt(requestTime.UnixNano(), 10))
+	header.Set(headerResponseTime, strconv.FormatInt(responseTime.UnixNano(), 10))
+}
+
+// storedTimes returns the request and response times recorded on the header. If the
+// times were not recorded, the Date of the response is used for both.
+func storedTimes(header http.Header) (requestTime, responseTime time.Time) {
+	requestTime, reqok := parseUnixNano(header.Get(headerRequestTime))
+	responseTime, repok := parseUnixNano(header.Get(headerResponseTime))
+
+	if !reqok || !repok {
+		date := dateOf(header, time.Time{})
+		return date, date
+	}
+	return requestTime, responseTime
+}
+
+func parseUnixNano(value string) (time.Time, bool) {
+	nsec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nsec), true
+}
+
+// networkError returns true if the error is caused by a failure to connect to or
+// communicate with the origin, including timeouts, rather than by the caller canceling
+// the request.
+func networkError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var neterr net.Error
+	return errors.As(err, &neterr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
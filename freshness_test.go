@@ -0,0 +1,125 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestEvaluateFreshness(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Add(-10 * time.Minute)
+
+	tests := []struct {
+		name      string
+		status    int
+		headers   map[string]string
+		shared    bool
+		lifetime  time.Duration
+		age       time.Duration
+		heuristic bool
+	}{
+		{
+			name:     "max-age",
+			headers:  map[string]string{"Cache-Control": "max-age=3600"},
+			lifetime: time.Hour,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "s-maxage ignored by private caches",
+			headers:  map[string]string{"Cache-Control": "max-age=60, s-maxage=3600"},
+			lifetime: time.Minute,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "s-maxage used by shared caches",
+			headers:  map[string]string{"Cache-Control": "max-age=60, s-maxage=3600"},
+			shared:   true,
+			lifetime: time.Hour,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "max-age takes precedence over Expires",
+			headers:  map[string]string{"Cache-Control": "max-age=60", "Expires": now.Add(time.Hour).Format(http.TimeFormat)},
+			lifetime: time.Minute,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "Expires relative to Date",
+			headers:  map[string]string{"Expires": date.Add(time.Hour).Format(http.TimeFormat)},
+			lifetime: time.Hour,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "Expires before Date",
+			headers:  map[string]string{"Expires": date.Add(-time.Hour).Format(http.TimeFormat)},
+			lifetime: 0,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "Invalid Expires is expired",
+			headers:  map[string]string{"Expires": "0", "Last-Modified": date.Add(-100 * time.Hour).Format(http.TimeFormat)},
+			lifetime: 0,
+			age:      10 * time.Minute,
+		},
+		{
+			name:      "Heuristic from Last-Modified",
+			headers:   map[string]string{"Last-Modified": date.Add(-100 * time.Hour).Format(http.TimeFormat)},
+			lifetime:  10 * time.Hour,
+			age:       10 * time.Minute,
+			heuristic: true,
+		},
+		{
+			name:     "No heuristic for uncacheable status",
+			status:   http.StatusFound,
+			headers:  map[string]string{"Last-Modified": date.Add(-100 * time.Hour).Format(http.TimeFormat)},
+			lifetime: 0,
+			age:      10 * time.Minute,
+		},
+		{
+			name:     "Age header",
+			headers:  map[string]string{"Cache-Control": "max-age=3600", "Age": "1200"},
+			lifetime: time.Hour,
+			age:      30 * time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		if test.status == 0 {
+			test.status = http.StatusOK
+		}
+
+		rep := &http.Response{StatusCode: test.status, Header: make(http.Header)}
+		rep.Header.Set("Date", date.Format(http.TimeFormat))
+		for k, v := range test.headers {
+			rep.Header.Set(k, v)
+		}
+		httpcache.SetStoredTimes(rep.Header, date, date)
+
+		lifetime, age, heuristic := httpcache.EvaluateFreshness(rep, test.shared, now)
+		require.Equal(t, test.lifetime, lifetime, "Test Case: %q", test.name)
+		require.Equal(t, test.age, age, "Test Case: %q", test.name)
+		require.Equal(t, test.heuristic, heuristic, "Test Case: %q", test.name)
+	}
+}
+
+func TestCurrentAgeCorrections(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// The response took 2 seconds to arrive and the origin clock is 5 seconds behind,
+	// so the apparent age is larger than the corrected age value.
+	rep := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	rep.Header.Set("Date", now.Add(-65*time.Second).Format(http.TimeFormat))
+	httpcache.SetStoredTimes(rep.Header, now.Add(-62*time.Second), now.Add(-60*time.Second))
+
+	_, age, _ := httpcache.EvaluateFreshness(rep, false, now)
+	require.Equal(t, 65*time.Second, age)
+
+	// If the upstream Age is larger it takes precedence over the apparent age.
+	rep.Header.Set("Age", "30")
+	_, age, _ = httpcache.EvaluateFreshness(rep, false, now)
+	require.Equal(t, 92*time.Second, age)
+}
@@ -0,0 +1,34 @@
+package httpcache
+
+// GregjonesCache is the Cache interface of github.com/gregjones/httpcache. Its backends,
+// e.g. diskcache, memcache or redis caches, implement it without importing this package.
+type GregjonesCache interface {
+	Get(key string) (responseBytes []byte, ok bool)
+	Set(key string, responseBytes []byte)
+	Delete(key string)
+}
+
+// FromGregjones returns a Cache that stores entries in a cache implementing the
+// github.com/gregjones/httpcache Cache interface, so that existing custom backends can
+// be used without modification. Both packages store serialized HTTP responses keyed by
+// the request URL, so entries stored by github.com/gregjones/httpcache can be read,
+// although they are served as if their age was computed from their Date header.
+func FromGregjones(cache GregjonesCache) Cache {
+	return gregjonesCache{cache: cache}
+}
+
+type gregjonesCache struct {
+	cache GregjonesCache
+}
+
+func (c gregjonesCache) Get(key string) ([]byte, bool) {
+	return c.cache.Get(key)
+}
+
+func (c gregjonesCache) Put(key string, val []byte) {
+	c.cache.Set(key, val)
+}
+
+func (c gregjonesCache) Del(key string) {
+	c.cache.Delete(key)
+}
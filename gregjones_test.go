@@ -0,0 +1,80 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// gregjonesMemoryCache mimics the MemoryCache of github.com/gregjones/httpcache.
+type gregjonesMemoryCache struct {
+	mu    sync.RWMutex
+	items map[string][]byte
+}
+
+func (c *gregjonesMemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.items[key]
+	return val, ok
+}
+
+func (c *gregjonesMemoryCache) Set(key string, val []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = val
+}
+
+func (c *gregjonesMemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+func TestFromGregjones(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	legacy := &gregjonesMemoryCache{items: make(map[string][]byte)}
+	cache := httpcache.FromGregjones(legacy)
+
+	cache.Put("key", []byte("value"))
+	val, ok := cache.Get("key")
+	require.True(t, ok)
+	require.Equal(t, []byte("value"), val)
+	cache.Del("key")
+	_, ok = legacy.Get("key")
+	require.False(t, ok)
+
+	client := httpcache.NewTransport(cache).Client()
+	Get(t, client, origin.URL)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(1), origin.Requests())
+	require.Contains(t, legacy.items, origin.URL)
+
+	// Entries stored by github.com/gregjones/httpcache are dumped responses.
+	rep := &http.Response{
+		StatusCode:    http.StatusOK,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Cache-Control": {"max-age=3600"}, "Date": {time.Now().UTC().Format(http.TimeFormat)}},
+		Body:          http.NoBody,
+		ContentLength: 0,
+	}
+	dump, err := httputil.DumpResponse(rep, true)
+	require.NoError(t, err)
+	legacy.Set(origin.URL+"/legacy", dump)
+
+	rep, _ = Get(t, client, origin.URL+"/legacy")
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, int64(1), origin.Requests())
+}
@@ -0,0 +1,44 @@
+package httpcache
+
+import "github.com/cespare/xxhash/v2"
+
+// conflictSeed derives the seed of the second hash returned by KeyToHash.
+const conflictSeed = 0x9e3779b97f4a7c15
+
+// KeyHasher hashes cache keys with xxHash. Backends and tools that hash keys, e.g. to
+// assign them to slots or shards, use the same hasher so that a key maps identically
+// everywhere and debugging tools can compute where a key is stored.
+type KeyHasher struct {
+	// Seed randomizes the hashes, e.g. to protect against hash flooding with keys chosen
+	// by untrusted clients. All processes that share hashed data must use the same seed.
+	Seed uint64
+}
+
+// DefaultKeyHasher is the unseeded hasher used by the backends of this module.
+var DefaultKeyHasher = KeyHasher{}
+
+// HashKey returns the hash of the key computed by the DefaultKeyHasher.
+func HashKey(key string) uint64 {
+	return DefaultKeyHasher.Sum64(key)
+}
+
+// Sum64 returns the 64-bit hash of the key.
+func (h KeyHasher) Sum64(key string) uint64 {
+	return sum64(h.Seed, key)
+}
+
+// KeyToHash returns the hash of the key and a second, independent hash that is used to
+// detect conflicts, e.g. by the ristretto backend.
+func (h KeyHasher) KeyToHash(key string) (uint64, uint64) {
+	return sum64(h.Seed, key), sum64(h.Seed^conflictSeed, key)
+}
+
+func sum64(seed uint64, key string) uint64 {
+	if seed == 0 {
+		return xxhash.Sum64String(key)
+	}
+
+	digest := xxhash.NewWithSeed(seed)
+	digest.WriteString(key)
+	return digest.Sum64()
+}
@@ -0,0 +1,25 @@
+package httpcache_test
+
+import (
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestKeyHasher(t *testing.T) {
+	key := "https://example.com/"
+	require.Equal(t, xxhash.Sum64String(key), httpcache.HashKey(key))
+	require.Equal(t, httpcache.HashKey(key), httpcache.DefaultKeyHasher.Sum64(key))
+
+	seeded := httpcache.KeyHasher{Seed: 42}
+	require.Equal(t, seeded.Sum64(key), httpcache.KeyHasher{Seed: 42}.Sum64(key))
+	require.NotEqual(t, httpcache.HashKey(key), seeded.Sum64(key))
+
+	for _, hasher := range []httpcache.KeyHasher{httpcache.DefaultKeyHasher, seeded} {
+		hash, conflict := hasher.KeyToHash(key)
+		require.Equal(t, hasher.Sum64(key), hash)
+		require.NotEqual(t, hash, conflict)
+	}
+}
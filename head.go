@@ -0,0 +1,103 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+)
+
+// isHead returns true for HEAD requests that may be answered from a stored response to
+// a GET request for the same target URI.
+func isHead(req *http.Request) bool {
+	return req.Method == http.MethodHead && req.Header.Get("Range") == ""
+}
+
+// roundTripHead answers a HEAD request with the header of a fresh stored response to a
+// GET request for the same target URI. Otherwise the request is forwarded to the origin
+// and a successful response is used to update the stored GET response (RFC 9111 §4.3.5):
+// if its validators match, the stored header is updated, otherwise the stored response
+// describes a different representation and is removed. The request directives are
+// handled like those of GET requests.
+func (t *Transport) roundTripHead(req *http.Request) (rep *http.Response, err error) {
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	get = t.collapseLanguage(get)
+
+	policy := t.policy(get)
+	primary := policy.key(get)
+	reqcc := requestCacheControl(req.Header)
+	if reqcc.has("no-store") {
+		t.deleteEntry(policy.cache, primary)
+		return t.bypass(req)
+	}
+
+	_, cached, freshness := t.lookup(primary, get, policy)
+	freshness.request(reqcc)
+	if cached != nil && freshness.fresh() {
+		cached.Body.Close()
+		rep = serve(cached, freshness)
+		rep.Body = http.NoBody
+		rep.Request = req
+		return t.annotate(rep, CacheHit), nil
+	}
+
+	// A request with only-if-cached must not be forwarded to the origin (§5.2.1.7).
+	if reqcc.has("only-if-cached") {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		t.Hooks.failed(req, ErrOnlyIfCachedMiss)
+		return t.annotate(gatewayTimeout(req), CacheMiss), nil
+	}
+
+	if _, err = t.limit(req, policy, false); err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+
+	requestTime := t.now()
+	if rep, err = t.forward(req); err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+	responseTime := t.now()
+	t.annotate(rep, CacheMiss)
+
+	if cached == nil || rep.StatusCode != http.StatusOK || cached.StatusCode != http.StatusOK {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return rep, nil
+	}
+	defer cached.Body.Close()
+
+	if !sameRepresentation(cached.Header, rep.Header) || parseCacheControl(rep.Header).has("no-store") {
+		t.deleteEntry(policy.cache, primary)
+		return rep, nil
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(cached.Body); err != nil {
+		return rep, nil
+	}
+
+	updateHeaders(cached.Header, rep.Header)
+	t.storeEntry(primary, get, cached, body, policy, requestTime, responseTime)
+	return rep, nil
+}
+
+// sameRepresentation returns true if the header of a HEAD response describes the same
+// representation as the stored response: their strong validators match or, if neither
+// has one, their Content-Length does not differ.
+func sameRepresentation(stored, head http.Header) bool {
+	validator := strongValidator(stored)
+	if validator != "" || strongValidator(head) != "" {
+		return validator == strongValidator(head)
+	}
+
+	length := head.Get("Content-Length")
+	return length == "" || length == stored.Get("Content-Length")
+}
@@ -0,0 +1,96 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func Head(t *testing.T, client *http.Client, url string, headers ...string) *http.Response {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rep, err := client.Do(req)
+	require.NoError(t, err)
+	rep.Body.Close()
+	return rep
+}
+
+func TestTransportHead(t *testing.T) {
+	var maxAge, etag atomic.Value
+	maxAge.Store("max-age=3600")
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", maxAge.Load().(string))
+		w.Header().Set("ETag", etag.Load().(string))
+		w.Header().Set("X-Request", fmt.Sprint(n))
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	t.Run("Fresh", func(t *testing.T) {
+		Get(t, client, origin.URL+"/fresh")
+		rep := Head(t, client, origin.URL+"/fresh")
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		require.Equal(t, "1", rep.Header.Get("X-Request"))
+		require.Equal(t, int64(10), rep.ContentLength)
+		require.Equal(t, int64(1), origin.Requests())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		maxAge.Store("max-age=0")
+		Get(t, client, origin.URL+"/update")
+
+		// The HEAD response updates the header of the stale stored response.
+		maxAge.Store("max-age=3600")
+		rep := Head(t, client, origin.URL+"/update")
+		require.Equal(t, "3", rep.Header.Get("X-Request"))
+
+		rep, body := Get(t, client, origin.URL+"/update")
+		require.Equal(t, "response 2", body)
+		require.Equal(t, "3", rep.Header.Get("X-Request"))
+		require.Equal(t, int64(3), origin.Requests())
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		maxAge.Store("max-age=0")
+		Get(t, client, origin.URL+"/invalidate")
+
+		// A HEAD response for a different representation removes the stored response.
+		etag.Store(`"v2"`)
+		Head(t, client, origin.URL+"/invalidate")
+		_, ok := cache.Get(origin.URL + "/invalidate")
+		require.False(t, ok)
+		require.Equal(t, int64(5), origin.Requests())
+	})
+
+	t.Run("NoStore", func(t *testing.T) {
+		maxAge.Store("max-age=3600")
+		Get(t, client, origin.URL+"/no-store")
+
+		// The stored response is not used to answer a request with no-store.
+		rep := Head(t, client, origin.URL+"/no-store", "Cache-Control", "no-store")
+		require.Equal(t, "7", rep.Header.Get("X-Request"))
+		require.Equal(t, int64(7), origin.Requests())
+	})
+
+	t.Run("OnlyIfCached", func(t *testing.T) {
+		rep := Head(t, client, origin.URL+"/only-if-cached", "Cache-Control", "only-if-cached")
+		require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+		require.Equal(t, int64(7), origin.Requests())
+
+		rep = Head(t, client, origin.URL+"/fresh", "Cache-Control", "only-if-cached")
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		require.Equal(t, int64(7), origin.Requests())
+	})
+}
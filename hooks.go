@@ -0,0 +1,143 @@
+package httpcache
+
+import "net/http"
+
+// Hooks are callbacks invoked by the Transport as it handles requests so that
+// applications can record custom metrics or audit caching decisions. Any of the
+// callbacks may be nil. Callbacks are invoked synchronously, possibly concurrently and
+// while the caller reads the response body, so they must be safe for concurrent use
+// and should return quickly. They must not modify the requests or responses they are
+// passed or read their bodies.
+type Hooks struct {
+	// OnHit is called when a stored response is served without being revalidated;
+	// stale is true if the response was served stale, e.g. while it is revalidated in
+	// the background or because the origin failed.
+	OnHit func(req *http.Request, stale bool)
+
+	// OnMiss is called when a response is fetched from the origin because no usable
+	// response was stored.
+	OnMiss func(req *http.Request)
+
+	// OnRevalidate is called when a stored response is served after the origin
+	// confirmed with 304 Not Modified that it is still current.
+	OnRevalidate func(req *http.Request)
+
+	// OnStore is called once a response has been stored at the key. The header of the
+	// response is a copy without the internal headers of the cache.
+	OnStore func(key string, rep *http.Response)
+
+	// OnSkipStore is called when a response fetched from the origin is not stored,
+	// with the reason for the decision.
+	OnSkipStore func(req *http.Request, rep *http.Response, reason SkipReason)
+
+	// OnError is called when the Transport recovers from a failure without returning an
+	// error to the caller, e.g. by removing a corrupt entry or answering an only-if-cached
+	// request with 504 Gateway Timeout. The error wraps one of ErrEntryCorrupt,
+	// ErrOnlyIfCachedMiss, ErrTooLarge or ErrKeyMismatch so that it can be inspected with
+	// errors.Is.
+	OnError func(req *http.Request, err error)
+}
+
+// SkipReason describes why a response was not stored in the cache.
+type SkipReason uint8
+
+const (
+	notSkipped SkipReason = iota
+
+	// SkipStatus is a response whose status code is not stored, e.g. an interim or
+	// partial response or a status code excluded by StatusCodes.
+	SkipStatus
+
+	// SkipNoStore is a response with the no-store directive.
+	SkipNoStore
+
+	// SkipVaryAll is a response that varies on all request headers (Vary: *).
+	SkipVaryAll
+
+	// SkipPrivate is a private response that a shared cache must not store.
+	SkipPrivate
+
+	// SkipAuthorization is a response to an authorized request that does not allow a
+	// shared cache to store it.
+	SkipAuthorization
+
+	// SkipNotCacheable is a response without explicit freshness information whose
+	// status code is not cacheable by default.
+	SkipNotCacheable
+
+	// SkipTooLarge is a response body that exceeded the maximum body size.
+	SkipTooLarge
+
+	// SkipSuperseded is a response that was not stored because a newer response was
+	// already stored according to the WritePolicy.
+	SkipSuperseded
+
+	// SkipVetoed is a response that the ShouldCache predicate of the Transport did not
+	// allow to be stored.
+	SkipVetoed
+
+	numSkipReasons
+)
+
+var skipLabels = [numSkipReasons]string{
+	notSkipped:        "stored",
+	SkipStatus:        "status",
+	SkipNoStore:       "no_store",
+	SkipVaryAll:       "vary_all",
+	SkipPrivate:       "private",
+	SkipAuthorization: "authorization",
+	SkipNotCacheable:  "not_cacheable",
+	SkipTooLarge:      "too_large",
+	SkipSuperseded:    "superseded",
+	SkipVetoed:        "vetoed",
+}
+
+// String returns the label of the reason, e.g. for use as a metrics label.
+func (r SkipReason) String() string {
+	if r < numSkipReasons {
+		return skipLabels[r]
+	}
+	return "unknown"
+}
+
+// observe invokes the hook for the status with which the response was served.
+func (h *Hooks) observe(rep *http.Response, status CacheStatus) {
+	req := rep.Request
+	switch {
+	case status == CacheHit && h.OnHit != nil:
+		h.OnHit(req, false)
+	case status == CacheStale && h.OnHit != nil:
+		h.OnHit(req, true)
+	case status == CacheMiss && h.OnMiss != nil:
+		h.OnMiss(req)
+	case status == CacheRevalidated && h.OnRevalidate != nil:
+		h.OnRevalidate(req)
+	}
+}
+
+// stored invokes OnStore for a response that was stored at the key.
+func (h *Hooks) stored(key string, rep *http.Response) {
+	if h.OnStore == nil {
+		return
+	}
+
+	snapshot := *rep
+	snapshot.Header = rep.Header.Clone()
+	snapshot.Body = http.NoBody
+	removeInternalHeaders(snapshot.Header)
+	h.OnStore(key, &snapshot)
+}
+
+// skipped invokes OnSkipStore for a response that was not stored.
+func (h *Hooks) skipped(req *http.Request, rep *http.Response, reason SkipReason) {
+	if h.OnSkipStore != nil {
+		h.OnSkipStore(req, rep, reason)
+	}
+}
+
+// failed invokes OnError for a failure the Transport recovered from.
+func (h *Hooks) failed(req *http.Request, err error) {
+	if h.OnError != nil {
+		h.OnError(req, err)
+	}
+}
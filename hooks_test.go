@@ -0,0 +1,173 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportHooks(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/large":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte(strings.Repeat("x", 1024)))
+			return
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(format string, args ...any) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+
+	clock := &fakeClock{now: time.Now()}
+	host := strings.TrimPrefix(origin.URL, "http://")
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithClock(clock),
+		httpcache.WithProfile(host, &httpcache.Profile{MaxBodySize: 512}),
+		httpcache.WithHooks(httpcache.Hooks{
+			OnHit:        func(req *http.Request, stale bool) { record("hit %s stale=%t", req.URL.Path, stale) },
+			OnMiss:       func(req *http.Request) { record("miss %s", req.URL.Path) },
+			OnRevalidate: func(req *http.Request) { record("revalidate %s", req.URL.Path) },
+			OnStore: func(key string, rep *http.Response) {
+				require.Empty(t, rep.Header.Get("X-Httpcache-Key"))
+				record("store %s", strings.TrimPrefix(key, origin.URL))
+			},
+			OnSkipStore: func(req *http.Request, rep *http.Response, reason httpcache.SkipReason) {
+				record("skip %s %s", req.URL.Path, reason)
+			},
+		}),
+	)
+	client := transport.Client()
+
+	Get(t, client, origin.URL+"/a")
+	Get(t, client, origin.URL+"/a")
+	clock.Advance(2 * time.Minute)
+	Get(t, client, origin.URL+"/a")
+	Get(t, client, origin.URL+"/no-store")
+	Get(t, client, origin.URL+"/large")
+
+	require.Equal(t, []string{
+		"miss /a",
+		"store /a",
+		"hit /a stale=false",
+		"store /a",
+		"revalidate /a",
+		"skip /no-store no_store",
+		"miss /no-store",
+		"skip /large too_large",
+		"miss /large",
+	}, events)
+}
+
+func TestTransportHooksOnError(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/large" {
+			w.Write([]byte(strings.Repeat("x", 1024)))
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	cache := &httpcache.InMemoryCache{}
+	host := strings.TrimPrefix(origin.URL, "http://")
+	client := httpcache.NewTransport(cache,
+		httpcache.WithProfile(host, &httpcache.Profile{MaxBodySize: 512}),
+		httpcache.WithHooks(httpcache.Hooks{
+			OnError: func(req *http.Request, err error) {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			},
+		}),
+	).Client()
+
+	testCases := []struct {
+		path    string
+		headers []string
+		err     error
+	}{
+		{"/corrupt", nil, httpcache.ErrEntryCorrupt},
+		{"/missing", []string{"Cache-Control", "only-if-cached"}, httpcache.ErrOnlyIfCachedMiss},
+		{"/large", nil, httpcache.ErrTooLarge},
+	}
+
+	cache.Put(origin.URL+"/corrupt", []byte("garbage"))
+	for _, tc := range testCases {
+		errs = nil
+		Get(t, client, origin.URL+tc.path, tc.headers...)
+		require.Len(t, errs, 1, "Test Case: %q", tc.path)
+		require.ErrorIs(t, errs[0], tc.err, "Test Case: %q", tc.path)
+	}
+}
+
+func TestTransportShouldCache(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/error" {
+			fmt.Fprintf(w, `{"error": "response %d"}`, n)
+			return
+		}
+		fmt.Fprintf(w, `{"data": "response %d"}`, n)
+	})
+
+	var reasons []httpcache.SkipReason
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithShouldCache(func(req *http.Request, rep *http.Response) bool {
+			require.Empty(t, rep.Header.Get("X-Httpcache-Key"))
+			body, err := io.ReadAll(rep.Body)
+			require.NoError(t, err)
+			return !strings.Contains(string(body), `"error"`)
+		}),
+		httpcache.WithHooks(httpcache.Hooks{
+			OnSkipStore: func(req *http.Request, rep *http.Response, reason httpcache.SkipReason) {
+				reasons = append(reasons, reason)
+			},
+		}),
+	)
+	client := transport.Client()
+
+	_, body := Get(t, client, origin.URL+"/ok")
+	require.Equal(t, `{"data": "response 1"}`, body)
+	_, body = Get(t, client, origin.URL+"/ok")
+	require.Equal(t, `{"data": "response 1"}`, body, "expected the response to be stored")
+
+	_, body = Get(t, client, origin.URL+"/error")
+	require.Equal(t, `{"error": "response 2"}`, body)
+	_, body = Get(t, client, origin.URL+"/error")
+	require.Equal(t, `{"error": "response 3"}`, body, "expected the vetoed response not to be stored")
+	require.Equal(t, []httpcache.SkipReason{httpcache.SkipVetoed, httpcache.SkipVetoed}, reasons)
+}
+
+func TestSkipReason(t *testing.T) {
+	require.Equal(t, "too_large", httpcache.SkipTooLarge.String())
+	require.Equal(t, "unknown", httpcache.SkipReason(255).String())
+}
@@ -1,8 +1,17 @@
 package httpcache
 
 import (
+	"bytes"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httputil"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -10,6 +19,11 @@ const (
 )
 
 func normalize(value string) string {
+	// Most header values are already normalized; return them without allocating.
+	if normalized(value) {
+		return value
+	}
+
 	// Trim leading/trailing whitespace
 	value = strings.TrimSpace(value)
 
@@ -18,6 +32,7 @@ func normalize(value string) string {
 		norm      strings.Builder
 		prevSpace bool
 	)
+	norm.Grow(len(value))
 
 	for _, c := range value {
 		if c == nbsp || c == '\t' || c == '\n' || c == '\r' {
@@ -36,10 +51,892 @@ func normalize(value string) string {
 	return result
 }
 
+// normalized returns true if normalize would return the value unchanged: it does not
+// begin or end with whitespace and does not contain tabs, line breaks, consecutive
+// spaces or a space after a comma, and is valid UTF-8. Values that begin or end with
+// non-ASCII characters are conservatively normalized since they may be Unicode
+// whitespace.
+func normalized(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	if edge(value[0]) || edge(value[len(value)-1]) {
+		return false
+	}
+
+	ascii := true
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\t' || c == '\n' || c == '\r':
+			return false
+		case c == nbsp:
+			if prev := value[i-1]; prev == nbsp || prev == ',' {
+				return false
+			}
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+
+	// Invalid UTF-8 is replaced when the value is normalized.
+	return ascii || utf8.ValidString(value)
+}
+
+// edge returns true if the byte may be whitespace that is trimmed by normalize.
+func edge(c byte) bool {
+	return c >= utf8.RuneSelf || c == nbsp || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
 //===========================================================================
 // Transport
 //===========================================================================
 
+// Transport is an http.RoundTripper that stores responses in a Cache and serves them
+// without contacting the origin server while they are fresh as described by RFC 9111.
+// Stale responses are refetched from the origin and replaced in the cache. Transport
+// behaves as a private (client-side) cache unless Shared is set.
 type Transport struct {
+	// Transport is the RoundTripper used to make requests to the origin server. If
+	// nil, http.DefaultTransport is used.
 	Transport http.RoundTripper
+
+	// Cache stores the responses. If nil, requests are passed through uncached.
+	Cache Cache
+
+	// CacheSelector selects the cache that stores the responses to the request, e.g. to
+	// keep responses with tokens in memory while catalog data is stored in a shared
+	// backend. It is used to look up, store and invalidate the responses to a request;
+	// if it is nil or returns nil, Cache is used. It may be called several times for a
+	// request, so it must be fast and return the same cache for equivalent requests.
+	// Operations that apply to the whole cache, e.g. Flush, EvictIdle or PurgeOrigin,
+	// only apply to Cache.
+	CacheSelector func(req *http.Request) Cache
+
+	// Shared declares that the cache is shared between multiple users, e.g. when the
+	// Transport is used by a proxy. A shared cache does not store responses marked
+	// private or responses to requests with an Authorization header (unless the
+	// response explicitly allows it) and uses s-maxage to determine freshness.
+	Shared bool
+
+	// ReplayCookies stores the Set-Cookie header of responses in a shared cache so that
+	// the cookies are set on every client the stored response is served to. By default
+	// Set-Cookie is removed from responses before they are stored in a shared cache.
+	ReplayCookies bool
+
+	// Version scopes the cache keys of stored responses, e.g. to a build identifier,
+	// so that responses stored by a deployment with an incompatible serialization or
+	// caching policy are never served. Entries stored under other versions can be
+	// removed with CollectGarbage.
+	Version string
+
+	// StatusCodes lists the status codes of responses that may be stored; responses
+	// with these status codes may be stored without explicit freshness information. If
+	// empty, responses with the status codes that are heuristically cacheable by default
+	// (RFC 9110 §15.1) may be stored and responses with other status codes are only
+	// stored if they have explicit freshness information.
+	StatusCodes []int
+
+	// NegativeTTL is the freshness lifetime assigned to negative responses, e.g. 404
+	// Not Found, that do not have explicit expiration information so that repeated
+	// requests for missing resources are not forwarded to the origin. Zero disables
+	// negative caching.
+	NegativeTTL time.Duration
+
+	// NegativeStatusCodes are the status codes of negative responses that are cached
+	// for NegativeTTL. Defaults to 404 Not Found and 410 Gone; 403 Forbidden may also
+	// be included.
+	NegativeStatusCodes []int
+
+	// RespectRetryAfter caches 429 Too Many Requests and 503 Service Unavailable
+	// responses for the duration indicated by their Retry-After header, unless they have
+	// explicit expiration information, so that clients retrying in a loop do not keep
+	// sending requests to a rate limited or unavailable origin.
+	RespectRetryAfter bool
+
+	// Languages are the languages supported by the origin servers, e.g. "en" and "fr".
+	// If set, the Accept-Language header of cacheable requests is collapsed to the most
+	// preferred supported language before the request is keyed and sent to the origin,
+	// so that regional variants such as en-US and en-GB share a stored response.
+	Languages []string
+
+	// WritePolicy determines which response is kept when concurrent requests race to
+	// store a response for the same key. By default the last response stored wins.
+	WritePolicy WritePolicy
+
+	// Derivations generate derived variants of stored responses that are served to
+	// the requests they match instead of the original response.
+	Derivations []Derivation
+
+	// KeyHeaders are the request headers whose values are included in the cache key,
+	// e.g. X-Tenant-ID, so that clients of a multi-tenant API do not share responses.
+	// Profiles with their own KeyHeaders replace them for requests to their host.
+	KeyHeaders []string
+
+	// IgnoredQueryParams are the names of query parameters that are removed from the
+	// request URL before its cache key is built, e.g. cache busters such as v in ?v=123,
+	// so that URLs that only differ by them share a stored response. The full URL of the
+	// request that most recently stored the response is recorded in its Metadata.
+	IgnoredQueryParams []string
+
+	// CachePOST stores responses to POST requests keyed by a digest of the request
+	// body, e.g. for GraphQL or search APIs that only accept queries with POST. It must
+	// only be enabled for origins whose POST requests do not change state: cached POST
+	// requests are not sent to the origin and do not invalidate stored responses.
+	CachePOST bool
+
+	// PartitionByCredential stores responses to requests with an Authorization header
+	// in a separate partition of the cache for each credential. A partition is only
+	// used by requests with the same credential so it is treated as a private cache,
+	// even if Shared is set, rather than refusing to store the responses.
+	PartitionByCredential bool
+
+	// Profiles configures caching behavior for specific hosts, keyed by the host of the
+	// request URL (e.g. "api.example.com" or "localhost:8080"). Requests to hosts
+	// without a profile use the defaults of the Transport. The profiles, rules, status
+	// codes and negative caching options can be replaced at runtime with UpdateConfig.
+	Profiles map[string]*Profile
+
+	// Rules override the caching policy of requests whose URL matches a pattern, e.g.
+	// to bypass the cache for /auth/* or to cache /static/* for a day. The first rule
+	// that matches a request applies, after its host profile.
+	Rules []Rule
+
+	// MaxBackgroundTasks is the maximum number of background tasks, e.g. revalidations
+	// of stale-while-revalidate responses, that may run concurrently. Defaults to 8.
+	MaxBackgroundTasks int
+
+	// BackgroundPolicy determines how background work is handled while the maximum
+	// number of background tasks are running. By default the work is dropped.
+	BackgroundPolicy BackpressurePolicy
+
+	// ShadowRate is the fraction of cache hits, between 0 and 1, that are also fetched
+	// from the origin in the background and compared with the stored response. The
+	// divergence is reported by Stats. This is a safety net when first caching an
+	// unfamiliar API; the origin responses are not stored. Zero disables shadow mode.
+	ShadowRate float64
+
+	// CollapseRequests collapses concurrent requests for the same response that is not
+	// stored into a single request to the origin, preventing a thundering herd on the
+	// origin, e.g. when the cache is cold. If the response may be stored, its body is
+	// streamed to the waiting requests as it arrives from the origin; otherwise the
+	// waiting requests are forwarded to the origin.
+	CollapseRequests bool
+
+	// ServeStaleOnNetworkError serves any stored response, however stale, if the origin
+	// cannot be reached because of a connection error, regardless of stale-if-error
+	// directives. Responses with must-revalidate (or proxy-revalidate in a shared cache)
+	// and responses with no-cache are never served stale. This is useful for clients
+	// with flaky connectivity. Stored responses are not removed when they expire so that
+	// they remain available.
+	ServeStaleOnNetworkError bool
+
+	// VerifyKeys records the method, URL and vary header values of the request with each
+	// stored response and verifies on every hit that they match the request, treating
+	// entries that do not match as misses and passing an error wrapping ErrKeyMismatch
+	// to Hooks.OnError. This detects key collisions, e.g. of hashed keys or a
+	// misconfigured CacheSelector, before they serve one request's response to another.
+	VerifyKeys bool
+
+	// RetryOnNetworkError retries GET and HEAD requests without a body once if the
+	// origin cannot be reached because of a connection error. If the retry fails too,
+	// a stale response is served if ServeStaleOnNetworkError or stale-if-error allows
+	// it, otherwise the error is returned.
+	RetryOnNetworkError bool
+
+	// Limiter limits the rate of the requests sent to the origin on cache misses and
+	// revalidations, e.g. to respect the quota of an API. Profiles with their own
+	// Limiter replace it for requests to their host. If nil, requests are not limited.
+	Limiter Limiter
+
+	// MaxLimiterDelay is the longest that a request for which a stale response is
+	// stored waits for the Limiter. If the Limiter would delay the request for longer,
+	// the stale response is served instead, unless it must be revalidated. Zero waits
+	// for the Limiter without serving stale responses.
+	MaxLimiterDelay time.Duration
+
+	// MaxIdle is the duration after which entries that have not been read or written by
+	// the Transport are removed by EvictIdle, independent of their freshness. Zero
+	// disables idle eviction and access tracking.
+	MaxIdle time.Duration
+
+	// DeduplicateWindow enables the deduplication of identical unsafe requests, e.g.
+	// POST requests, identified by their method, URL, credentials and body. Duplicates
+	// that are sent while the first request is in flight, or within the window after it
+	// completes, receive a copy of its response instead of being sent to the origin. This
+	// is useful for clients that fan out idempotent submissions such as webhooks. Zero
+	// disables deduplication.
+	DeduplicateWindow time.Duration
+
+	// StatusHeader is the name of a header, e.g. DefaultStatusHeader, that is added to
+	// every response to report how the cache handled the request: HIT, MISS, STALE,
+	// REVALIDATED or BYPASS (see CacheStatus), so that applications and tests can
+	// observe the behavior of the cache. If empty, responses are not annotated.
+	StatusHeader string
+
+	// ShouldCache is called with responses that may be stored according to RFC 9111
+	// once their body has been read, just before they are stored. If it returns false
+	// the response is not stored, e.g. because the body contains an error envelope
+	// despite a 200 status. The response is a copy without the internal headers of the
+	// cache whose body may be read; it must not be modified. If nil, all responses that
+	// may be stored are stored.
+	ShouldCache func(req *http.Request, rep *http.Response) bool
+
+	// TenantHeader is the name of a request header, e.g. X-Tenant-ID, that identifies the
+	// tenant of a request, typically also listed in KeyHeaders to partition the cache by
+	// tenant. If set, Stats reports the hits, misses and stored bytes of each tenant.
+	TenantHeader string
+
+	// TrackUsage records the hits and misses of each host and how often each entry is
+	// read in memory so that the effectiveness of the cache can be analyzed with Report.
+	TrackUsage bool
+
+	// Hooks are callbacks invoked on cache hits, misses, revalidations and decisions to
+	// store or skip storing responses, e.g. to record custom metrics.
+	Hooks Hooks
+
+	// BatchRevalidator revalidates stale-while-revalidate responses that have
+	// validators in batches rather than with a conditional request for each response.
+	// Stale entries are collected until BatchSize entries are pending (default 100) or
+	// BatchDelay has passed since the first was scheduled (default 100ms).
+	BatchRevalidator BatchRevalidator
+	BatchSize        int
+	BatchDelay       time.Duration
+
+	// PersistRevalidations records the pending background revalidations, e.g. of
+	// stale-while-revalidate responses and batches, with their due times in the Cache so
+	// that they are resumed by ResumeRevalidations if the process exits before they
+	// complete. Revalidations are resumed at least once; those of responses partitioned
+	// by credential are not recorded. Each revalidation updates the record in the Cache.
+	PersistRevalidations bool
+
+	// Clock provides the current time used to compute the age and freshness of stored
+	// responses. If nil, the system time is used.
+	Clock Clock
+
+	background  background
+	writes      sync.Mutex
+	indexes     sync.Mutex
+	partials    sync.Mutex
+	buffered    bufferGauge
+	failOpen    failOpenCounters
+	shadows     shadowCounters
+	flights     flights
+	accesses    accessTracker
+	config      atomic.Pointer[Config]
+	strict      bool
+	submissions submissions
+	batches     batches
+	tasks       tasks
+
+	revalidations revalidations
+	tenants       usageCounters
+	hosts         usageCounters
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport returns a Transport that stores responses in the specified cache and
+// uses http.DefaultTransport to make requests to the origin server unless configured
+// otherwise by the options. If PersistRevalidations is set by the options, revalidations
+// that were pending in the cache are resumed.
+func NewTransport(cache Cache, opts ...Option) *Transport {
+	t := &Transport{Cache: cache}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.ResumeRevalidations()
+	return t
+}
+
+// Client returns an *http.Client that caches responses using the Transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip serves the request from the cache if a fresh response is stored, otherwise
+// the request is made to the origin server and the response is stored in the cache if
+// it is storable. Stale responses with validators are revalidated with a conditional
+// request and served from the cache if the origin responds 304 Not Modified. Stale
+// responses are also served if the origin fails within a stale-if-error window.
+// Requests with only-if-cached that cannot be served from the cache receive a
+// synthetic 504 Gateway Timeout response.
+// Successful requests with unsafe methods invalidate the stored responses for the
+// target URI, unless they are POST requests that are cached because CachePOST is set.
+// Range requests are served by slicing a fresh, complete stored response and HEAD
+// requests are answered with the header of a fresh response to a GET request.
+// Responses are stored once their body has been completely read.
+func (t *Transport) RoundTrip(req *http.Request) (rep *http.Response, err error) {
+	caching := t.selectCache(req) != nil
+	if caching && (!IsUnsafeMethod(req.Method) || t.isQuery(req)) && t.bypassed(req) {
+		return t.bypass(req)
+	}
+
+	if caching && isRange(req) {
+		return t.roundTripRange(req)
+	}
+
+	if caching && t.isQuery(req) {
+		return t.roundTripQuery(req)
+	}
+
+	if IsUnsafeMethod(req.Method) {
+		switch {
+		case t.DeduplicateWindow > 0:
+			rep, err = t.roundTripDeduplicated(req)
+			return t.annotate(rep, CacheBypass), err
+		case caching:
+			rep, err = t.roundTripUnsafe(req)
+			return t.annotate(rep, CacheBypass), err
+		}
+	}
+
+	if caching && isHead(req) {
+		return t.roundTripHead(req)
+	}
+
+	if !caching || !cacheableRequest(req) {
+		return t.bypass(req)
+	}
+
+	// A request with no-store must not be served from or stored in the cache and any
+	// previously stored response for the request is removed.
+	req = t.collapseLanguage(req)
+	policy := t.policy(req)
+	primary := policy.key(req)
+	reqcc := requestCacheControl(req.Header)
+	if reqcc.has("no-store") {
+		t.deleteEntry(policy.cache, primary)
+		return t.bypass(req)
+	}
+
+	if derivation := t.derivation(req); derivation != nil {
+		return t.roundTripDerived(req, derivation, policy, primary, reqcc)
+	}
+	return t.roundTrip(req, policy, primary, reqcc)
+}
+
+// roundTrip serves a cacheable request from the cache or the origin server.
+func (t *Transport) roundTrip(req *http.Request, policy policy, primary string, reqcc cacheControl) (rep *http.Response, err error) {
+	// Responses that cannot be revalidated or served stale to any request are dead and
+	// are removed unless they are replaced. The request directives may then require the
+	// stored response to be revalidated.
+	key, cached, freshness := t.lookup(primary, req, policy)
+	dead := cached != nil && freshness.expired() && !hasValidators(cached.Header) && !t.ServeStaleOnNetworkError
+	freshness.request(reqcc)
+
+	if cached != nil && freshness.fresh() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if t.sampleShadow() {
+			t.shadow(key, req, cached)
+		}
+		return t.annotate(serve(cached, freshness), CacheHit), nil
+	}
+
+	// A request with only-if-cached must not be forwarded to the origin (§5.2.1.7).
+	if reqcc.has("only-if-cached") {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		t.Hooks.failed(req, ErrOnlyIfCachedMiss)
+		return t.annotate(gatewayTimeout(req), CacheMiss), nil
+	}
+
+	if cached != nil && freshness.revalidateInBackground() && !t.revalidate(key, req, cached.Header) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return t.annotate(serve(cached, freshness), CacheStale), nil
+	}
+
+	// A stale response can only be revalidated if it has validators and the request
+	// is not already conditional, otherwise the origin response replaces it.
+	outreq := req
+	if cached != nil {
+		if cond := conditionalRequest(req, cached.Header); cond != nil {
+			outreq = cond
+		}
+	}
+
+	// Concurrent misses for the same key wait for a single fetch from the origin.
+	var leader *flight
+	if cached == nil && t.CollapseRequests {
+		if rep, leader, err = t.collapse(key, primary, req, policy, reqcc); rep != nil || err != nil {
+			return rep, err
+		}
+	}
+
+	// Requests to the origin respect its rate limit, preferring stale responses to
+	// long delays.
+	var stale bool
+	if stale, err = t.limit(req, policy, cached != nil && freshness.servableStale()); stale || err != nil {
+		if leader != nil {
+			leader.finish()
+		}
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if stale {
+			return t.annotate(serve(cached, freshness), CacheStale), nil
+		}
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+
+	requestTime := t.now()
+	if rep, err = t.forward(outreq); err != nil {
+		if leader != nil {
+			leader.finish()
+		}
+		if cached != nil {
+			if staleIfError(req, freshness) || (t.ServeStaleOnNetworkError && freshness.servableStale() && networkError(err)) {
+				return t.annotate(serve(cached, freshness), CacheStale), nil
+			}
+			cached.Body.Close()
+		}
+		if dead {
+			t.deleteExpired(policy.cache, key)
+		}
+		return nil, err
+	}
+	responseTime := t.now()
+
+	if cached != nil {
+		switch {
+		case rep.StatusCode == http.StatusNotModified && outreq != req:
+			return t.annotate(t.revalidated(primary, req, cached, rep, policy, requestTime, responseTime), CacheRevalidated), nil
+		case serverError(rep.StatusCode) && staleIfError(req, freshness):
+			io.Copy(io.Discard, rep.Body)
+			rep.Body.Close()
+			return t.annotate(serve(cached, freshness), CacheStale), nil
+		}
+		cached.Body.Close()
+	}
+
+	reason := storeDecision(req, rep, policy)
+	cacheable := reason == notSkipped
+	switch {
+	case cacheable:
+		t.storeOnRead(primary, req, rep, policy, requestTime, responseTime)
+	case parseCacheControl(rep.Header).has("no-store"):
+		t.remove(policy.cache, key)
+	case dead:
+		t.deleteExpired(policy.cache, key)
+	}
+
+	if !cacheable {
+		t.Hooks.skipped(req, rep, reason)
+	}
+
+	t.annotate(rep, CacheMiss)
+	if leader != nil {
+		leader.stream(req, rep, cacheable, policy.maxBodySize)
+	}
+	return rep, nil
+}
+
+// deleteExpired removes a dead entry from the cache so that backends do not retain
+// entries that can never be served, even without a sweeper. The entry is removed
+// before the response is returned so that later requests never observe it.
+func (t *Transport) deleteExpired(cache Cache, key string) {
+	GetLogger().Debug("removing expired cache entry", slog.String("key", key))
+	t.remove(cache, key)
+}
+
+// roundTripRange handles Range requests. If a fresh, complete response is stored and
+// the If-Range precondition, if any, matches its validator, the range is sliced from
+// the stored body and served from the cache. Ranges are also served from partial
+// entries that combine the 206 responses received from the origin. Otherwise the
+// request is forwarded to the origin and, if the origin responds to an If-Range
+// request with a complete response because the validator did not match, the stored
+// response is replaced. The request directives are handled like those of other GET
+// requests: no-store bypasses the cache and only-if-cached is never forwarded.
+func (t *Transport) roundTripRange(req *http.Request) (rep *http.Response, err error) {
+	req = t.collapseLanguage(req)
+	policy := t.policy(req)
+	primary := policy.key(req)
+	reqcc := requestCacheControl(req.Header)
+	if reqcc.has("no-store") {
+		t.deleteEntry(policy.cache, primary)
+		return t.bypass(req)
+	}
+
+	key, cached, freshness := t.lookup(primary, req, policy)
+	freshness.request(reqcc)
+	if cached != nil {
+		if freshness.fresh() && cached.StatusCode == http.StatusOK && rangeMatches(req, cached.Header) {
+			var ok bool
+			if rep, ok, err = rangeResponse(serve(cached, freshness), req.Header.Get("Range")); err != nil || ok {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return t.annotate(rep, CacheHit), err
+			}
+		}
+		cached.Body.Close()
+	}
+
+	// Otherwise the range may be stored in a partial entry.
+	if p, freshness := t.lookupPartial(primary, req, policy); p != nil && freshness.fresh() && rangeMatches(req, p.rep.Header) {
+		if rep, ok := servePartial(p, freshness, req.Header.Get("Range")); ok {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return t.annotate(rep, CacheHit), nil
+		}
+	}
+
+	if reqcc.has("only-if-cached") {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		t.Hooks.failed(req, ErrOnlyIfCachedMiss)
+		return t.annotate(gatewayTimeout(req), CacheMiss), nil
+	}
+
+	if _, err = t.limit(req, policy, false); err != nil {
+		return nil, err
+	}
+
+	requestTime := t.now()
+	if rep, err = t.forward(req); err != nil {
+		return nil, err
+	}
+	responseTime := t.now()
+
+	switch {
+	case storablePartial(req, rep, policy):
+		t.storePartialOnRead(primary, req, rep, policy, requestTime, responseTime)
+	case rep.StatusCode == http.StatusOK && req.Header.Get("If-Range") != "" && storableResponse(req, rep, policy):
+		t.storeOnRead(primary, req, rep, policy, requestTime, responseTime)
+	case parseCacheControl(rep.Header).has("no-store"):
+		t.remove(policy.cache, key)
+	}
+	return t.annotate(rep, CacheMiss), nil
+}
+
+// lookup returns the stored response for the primary key that matches the request, if
+// any, the key it is stored at, and its current freshness. Corrupt entries are removed
+// from the cache and treated as a miss.
+func (t *Transport) lookup(primary string, req *http.Request, policy policy) (string, *http.Response, freshness) {
+	key, cached, err := cachedVariant(policy.cache, primary, req)
+	if err != nil {
+		GetLogger().Warn("removing corrupt cache entry", slog.String("key", key), slog.Any("error", err))
+		t.failOpen.add(FailOpenCorruptEntry)
+		t.Hooks.failed(req, err)
+		t.remove(policy.cache, key)
+		return key, nil, freshness{}
+	}
+
+	if cached == nil {
+		return key, nil, freshness{}
+	}
+
+	if t.VerifyKeys {
+		if err = verifyEntry(key, req, cached, policy); err != nil {
+			GetLogger().Warn("ignoring cache entry that does not match the request", slog.String("key", key), slog.Any("error", err))
+			t.Hooks.failed(req, err)
+			cached.Body.Close()
+			return key, nil, freshness{}
+		}
+	}
+
+	t.read(primary, key)
+	return key, cached, policy.freshness(cached, t.now())
+}
+
+// serve prepares a stored response to be returned to the caller by removing internal
+// headers and setting the Age header to the current age of the response.
+func serve(cached *http.Response, freshness freshness) *http.Response {
+	removeInternalHeaders(cached.Header)
+	cached.Header.Set("Age", strconv.FormatInt(int64(freshness.age/time.Second), 10))
+	return cached
+}
+
+// storeOnRead arranges for the response to be stored in the cache once its body has
+// been completely read by the caller. Bodies larger than the maximum body size of the
+// policy are not stored.
+func (t *Transport) storeOnRead(primary string, req *http.Request, rep *http.Response, policy policy, requestTime, responseTime time.Time) {
+	if !policy.fits(rep.ContentLength) {
+		t.failOpen.add(FailOpenTooLarge)
+		t.Hooks.failed(req, policy.tooLarge())
+		t.Hooks.skipped(req, rep, SkipTooLarge)
+		return
+	}
+
+	// Snapshot the response so that changes made by the caller are not stored.
+	snapshot := *rep
+	snapshot.Header = rep.Header.Clone()
+	store := func(body []byte) {
+		t.store(primary, req, &snapshot, body, policy, requestTime, responseTime)
+	}
+
+	if rep.Body == nil || rep.Body == http.NoBody || rep.ContentLength == 0 {
+		store(nil)
+	} else {
+		body := newCachingReadCloser(rep.Body, policy.maxBodySize, &t.buffered, store)
+		body.exceeded = func() {
+			t.failOpen.add(FailOpenTooLarge)
+			t.Hooks.failed(req, policy.tooLarge())
+			t.Hooks.skipped(req, &snapshot, SkipTooLarge)
+		}
+		rep.Body = body
+	}
+}
+
+// store records the time a response was received from the origin and puts it and its
+// derived variants into the cache. The response and its header are modified so a
+// snapshot of the response is required.
+func (t *Transport) store(primary string, req *http.Request, rep *http.Response, body []byte, policy policy, requestTime, responseTime time.Time) {
+	if !t.shouldCache(req, rep, body) {
+		t.Hooks.skipped(req, rep, SkipVetoed)
+		return
+	}
+
+	t.storeEntry(primary, req, rep, body, policy, requestTime, responseTime)
+	if rep.StatusCode == http.StatusOK {
+		t.storeDerived(primary, req, rep, body, policy, requestTime, responseTime)
+	}
+}
+
+// shouldCache returns false if the ShouldCache predicate vetoes storing the response.
+func (t *Transport) shouldCache(req *http.Request, rep *http.Response, body []byte) bool {
+	if t.ShouldCache == nil {
+		return true
+	}
+
+	snapshot := *rep
+	snapshot.Header = rep.Header.Clone()
+	snapshot.Body = io.NopCloser(bytes.NewReader(body))
+	removeInternalHeaders(snapshot.Header)
+	return t.ShouldCache(req, &snapshot)
+}
+
+// storeEntry puts a response into the cache. Responses that vary are stored with the
+// vary key of the request and a vary index is stored at the primary key.
+func (t *Transport) storeEntry(primary string, req *http.Request, rep *http.Response, body []byte, policy policy, requestTime, responseTime time.Time) {
+	key := primary
+	vary := varyHeaders(rep.Header)
+	if len(vary) > 0 {
+		key = varyKey(primary, req, vary)
+	}
+
+	// The stored response is compared and replaced atomically with respect to other
+	// writes by the Transport when the freshest response wins.
+	if t.WritePolicy == WriteFreshestWins {
+		t.writes.Lock()
+		defer t.writes.Unlock()
+	}
+
+	if !t.supersedes(policy.cache, key, rep.Header, responseTime) {
+		t.Hooks.skipped(req, rep, SkipSuperseded)
+		return
+	}
+
+	t.sanitize(rep.Header, policy)
+	setStoredTimes(rep.Header, requestTime, responseTime)
+	rep.Header.Set(headerKey, key)
+	rep.Header.Set(headerURL, req.URL.String())
+	rep.Header.Del(headerPurged)
+	t.recordRequest(rep.Header, req, vary)
+	if !t.put(policy.cache, key, rep, body) {
+		return
+	}
+	t.touch(primary, key)
+	t.tenant(req).stored(len(body))
+	t.host(req).stored(len(body))
+	t.Hooks.stored(key, rep)
+
+	if len(vary) > 0 {
+		t.indexVariant(policy.cache, primary, vary, key)
+	}
+}
+
+// sanitize removes the header fields of a response that must not be stored.
+func (t *Transport) sanitize(header http.Header, policy policy) {
+	removeHopByHop(header)
+	if policy.shared {
+		removePrivateFields(header)
+		if !t.ReplayCookies {
+			header.Del("Set-Cookie")
+		}
+	}
+}
+
+// put serializes the response with the specified body and puts it into the cache. It
+// returns false if the response could not be serialized.
+func (t *Transport) put(cache Cache, key string, rep *http.Response, body []byte) bool {
+	rep.Body = io.NopCloser(bytes.NewReader(body))
+	rep.ContentLength = int64(len(body))
+	rep.TransferEncoding = nil
+	rep.Trailer = nil
+
+	val, err := httputil.DumpResponse(rep, true)
+	if err != nil {
+		GetLogger().Warn("could not serialize response for cache", slog.String("key", key), slog.Any("error", err))
+		t.failOpen.add(FailOpenSerialize)
+		return false
+	}
+	cache.Put(key, val)
+	return true
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// forward sends the request to the origin and honors any Clear-Site-Data header of the
+// response before it is stored.
+func (t *Transport) forward(req *http.Request) (*http.Response, error) {
+	rep, err := t.transport().RoundTrip(req)
+	if err != nil && t.RetryOnNetworkError && retryable(req, err) {
+		GetLogger().Debug("retrying request after network error", slog.String("url", req.URL.String()), slog.Any("error", err))
+		rep, err = t.transport().RoundTrip(req)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.clearSiteData(req, rep)
+	return rep, nil
+}
+
+// cacheableRequest returns true if a response to the request may be served from or
+// stored in the cache. Range requests are handled by roundTripRange since partial
+// responses are not stored.
+func cacheableRequest(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.Header.Get("Range") == ""
+}
+
+// isRange returns true for GET requests for a range of the representation.
+func isRange(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.Header.Get("Range") != ""
+}
+
+// storable returns true if the response may be stored by the cache (RFC 9111 §3).
+func storable(req *http.Request, rep *http.Response, policy policy) bool {
+	return cacheableRequest(req) && storableResponse(req, rep, policy)
+}
+
+// storableResponse returns true if the response to a cacheable request may be stored.
+func storableResponse(req *http.Request, rep *http.Response, policy policy) bool {
+	return storeDecision(req, rep, policy) == notSkipped
+}
+
+// storeDecision returns the reason that the response to a cacheable request may not
+// be stored, or notSkipped if it may be stored.
+func storeDecision(req *http.Request, rep *http.Response, policy policy) SkipReason {
+	// Only final, complete responses are stored.
+	if rep.StatusCode < 200 || rep.StatusCode == http.StatusPartialContent || rep.StatusCode == http.StatusNotModified {
+		return SkipStatus
+	}
+
+	if !policy.allowedStatus(rep.StatusCode) {
+		return SkipStatus
+	}
+
+	cc := parseCacheControl(rep.Header)
+	if policy.force && policy.overridable(rep.StatusCode) {
+		// Forced responses to authorized requests are still never shared.
+		switch {
+		case varyAll(rep.Header):
+			return SkipVaryAll
+		case policy.shared && req.Header.Get("Authorization") != "":
+			return SkipAuthorization
+		}
+		return notSkipped
+	}
+
+	switch {
+	case cc.has("no-store"):
+		return SkipNoStore
+	case varyAll(rep.Header):
+		return SkipVaryAll
+	}
+
+	shared := policy.shared
+	if shared {
+		// Qualified private directives only prevent the named fields from being stored.
+		if private, ok := cc["private"]; ok && private == "" {
+			return SkipPrivate
+		}
+
+		// Responses to authorized requests must explicitly allow shared caching (§3.5).
+		if req.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+			return SkipAuthorization
+		}
+	}
+
+	if _, throttled := policy.throttled(rep); throttled {
+		return notSkipped
+	}
+
+	switch {
+	case policy.ttl > 0 && policy.overridable(rep.StatusCode):
+		return notSkipped
+	case policy.negativeTTL > 0 && policy.negative(rep.StatusCode):
+		return notSkipped
+	case cc.has("max-age"), cc.has("public"):
+		return notSkipped
+	case cc.has("private") && !shared, cc.has("s-maxage") && shared:
+		return notSkipped
+	case rep.Header.Get("Expires") != "":
+		return notSkipped
+	}
+
+	if !policy.cacheableByDefault(rep.StatusCode) {
+		return SkipNotCacheable
+	}
+	return notSkipped
+}
+
+// removePrivateFields removes the header fields named by a qualified private directive
+// so that they are not stored by a shared cache.
+func removePrivateFields(header http.Header) {
+	if private := parseCacheControl(header)["private"]; private != "" {
+		for _, name := range strings.Split(private, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+}
+
+// hopByHop headers are meaningful only for a single connection and are not stored.
+var hopByHop = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHop removes hop-by-hop headers including those listed by Connection.
+func removeHopByHop(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+
+	for _, name := range hopByHop {
+		header.Del(name)
+	}
 }
@@ -2,9 +2,17 @@ package httpcache_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.rtnl.ai/httpcache"
@@ -39,6 +47,43 @@ func (tr *TestRequest) HTTP() *http.Request {
 	return req
 }
 
+// Origin is a test server that counts the number of requests it receives and responds
+// using the handler. The handler is called with the current request count.
+type Origin struct {
+	*httptest.Server
+	requests atomic.Int64
+}
+
+func NewOrigin(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int64)) *Origin {
+	origin := &Origin{}
+	origin.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, origin.requests.Add(1))
+	}))
+	t.Cleanup(origin.Close)
+	return origin
+}
+
+func (o *Origin) Requests() int64 {
+	return o.requests.Load()
+}
+
+// Get performs a GET request using the client and returns the response with its body.
+func Get(t *testing.T, client *http.Client, url string, headers ...string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rep, err := client.Do(req)
+	require.NoError(t, err)
+	defer rep.Body.Close()
+
+	body, err := io.ReadAll(rep.Body)
+	require.NoError(t, err)
+	return rep, string(body)
+}
+
 //===========================================================================
 // Package Helpers Testing
 //===========================================================================
@@ -62,3 +107,937 @@ func TestNormalize(t *testing.T) {
 		require.Equal(t, test.expected, result)
 	}
 }
+
+//===========================================================================
+// Transport Testing
+//===========================================================================
+
+func TestTransportFresh(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Empty(t, rep.Header.Get("Age"))
+
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "0", rep.Header.Get("Age"))
+	require.Empty(t, rep.Header.Get(httpcache.HeaderRequestTime))
+	require.Empty(t, rep.Header.Get(httpcache.HeaderResponseTime))
+	require.Equal(t, int64(1), origin.Requests())
+}
+
+func TestTransportStale(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 2", body)
+	require.Equal(t, int64(2), origin.Requests())
+}
+
+func TestTransportExpires(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		now := time.Now().UTC()
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Get(t, client, origin.URL)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(1), origin.Requests())
+}
+
+func TestTransportNotStored(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/found" {
+			w.Header().Del("Cache-Control")
+			w.WriteHeader(http.StatusFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	t.Run("Unsafe Method", func(t *testing.T) {
+		rep, err := client.Post(origin.URL, "text/plain", nil)
+		require.NoError(t, err)
+		io.Copy(io.Discard, rep.Body)
+		rep.Body.Close()
+		require.Zero(t, cache.Len())
+	})
+
+	t.Run("Range Request", func(t *testing.T) {
+		Get(t, client, origin.URL, "Range", "bytes=0-3")
+		require.Zero(t, cache.Len())
+	})
+
+	t.Run("Uncacheable Status", func(t *testing.T) {
+		noredirect := httpcache.NewTransport(cache).Client()
+		noredirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		Get(t, noredirect, origin.URL+"/found")
+		require.Zero(t, cache.Len())
+	})
+
+	t.Run("Body Not Read", func(t *testing.T) {
+		rep, err := client.Get(origin.URL)
+		require.NoError(t, err)
+		rep.Body.Close()
+		require.Zero(t, cache.Len())
+	})
+}
+
+func TestTransportCorruptEntry(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	cache.Put(origin.URL, []byte("garbage"))
+
+	client := httpcache.NewTransport(cache).Client()
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(1), origin.Requests())
+}
+
+func TestTransportRevalidateETag(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", etag.Load().(string))
+		w.Header().Set("X-Request", fmt.Sprintf("%d", n))
+		if r.Header.Get("If-None-Match") == etag.Load().(string) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	// Stale response is revalidated and served from the cache with updated headers.
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "2", rep.Header.Get("X-Request"))
+	require.Equal(t, "0", rep.Header.Get("Age"))
+
+	// Requests with their own preconditions are passed through to the origin.
+	rep, _ = Get(t, client, origin.URL, "If-None-Match", `"v1"`)
+	require.Equal(t, http.StatusNotModified, rep.StatusCode)
+
+	// A changed validator results in a full response that replaces the entry.
+	etag.Store(`"v2"`)
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 4", body)
+
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 4", body)
+	require.Equal(t, "5", rep.Header.Get("X-Request"))
+	require.Equal(t, int64(5), origin.Requests())
+}
+
+func TestTransportRevalidateUpdatesStored(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Request", fmt.Sprintf("%d", n))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Clock = clock
+	client := transport.Client()
+	Get(t, client, origin.URL)
+
+	clock.Advance(2 * time.Minute)
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "2", rep.Header.Get("X-Request"))
+
+	// The updated header is stored and the freshness of the stored response is reset.
+	clock.Advance(30 * time.Second)
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "2", rep.Header.Get("X-Request"))
+	require.Equal(t, "30", rep.Header.Get("Age"))
+	require.Equal(t, int64(2), origin.Requests())
+}
+
+func TestTransportRevalidateLastModified(t *testing.T) {
+	modified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("Last-Modified", modified)
+		if r.Header.Get("If-Modified-Since") == modified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Get(t, client, origin.URL)
+
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(2), origin.Requests())
+}
+
+func TestTransportStaleWhileRevalidate(t *testing.T) {
+	release := make(chan struct{})
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if n > 1 {
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// Release blocked revalidations if the test fails so that the origin can be closed.
+	var releaseOnce sync.Once
+	closeRelease := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(closeRelease)
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	// Stale responses are served immediately and only one revalidation is started.
+	for i := 0; i < 5; i++ {
+		_, body = Get(t, client, origin.URL)
+		require.Equal(t, "response 1", body)
+	}
+
+	require.Eventually(t, func() bool { return origin.Requests() == 2 }, time.Second, time.Millisecond)
+
+	// Each request for the stale response starts another revalidation once the origin
+	// responds, so a later response may already have replaced the first revalidation.
+	closeRelease()
+	require.Eventually(t, func() bool {
+		_, body = Get(t, client, origin.URL)
+		return body != "response 1"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestTransportStaleWhileRevalidateExpired(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Header().Set("Age", "120")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Get(t, client, origin.URL)
+
+	// Beyond the stale-while-revalidate window the origin is contacted synchronously.
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 2", body)
+}
+
+// RoundTripFunc adapts a function to an http.RoundTripper for testing.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportStaleIfError(t *testing.T) {
+	var status atomic.Int64
+	status.Store(http.StatusOK)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if status := int(status.Load()); status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		if r.URL.Path == "/sie" {
+			w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var offline atomic.Bool
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Transport = RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if offline.Load() {
+			return nil, errors.New("network unreachable")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := transport.Client()
+
+	Get(t, client, origin.URL+"/sie")
+	Get(t, client, origin.URL+"/none")
+
+	status.Store(http.StatusServiceUnavailable)
+	t.Run("Server Error", func(t *testing.T) {
+		rep, body := Get(t, client, origin.URL+"/sie")
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		require.Equal(t, "response 1", body)
+
+		rep, _ = Get(t, client, origin.URL+"/none")
+		require.Equal(t, http.StatusServiceUnavailable, rep.StatusCode)
+	})
+
+	t.Run("Request Directive", func(t *testing.T) {
+		rep, body := Get(t, client, origin.URL+"/none", "Cache-Control", "stale-if-error=60")
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		require.Equal(t, "response 2", body)
+	})
+
+	offline.Store(true)
+	t.Run("Network Error", func(t *testing.T) {
+		_, body := Get(t, client, origin.URL+"/sie")
+		require.Equal(t, "response 1", body)
+
+		_, err := client.Get(origin.URL + "/none")
+		require.ErrorContains(t, err, "network unreachable")
+	})
+}
+
+func TestTransportNoStore(t *testing.T) {
+	var nostore atomic.Bool
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch {
+		case nostore.Load():
+			w.Header().Set("Cache-Control", "no-store, max-age=3600")
+		case r.URL.Path == "/request":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		default:
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	t.Run("Request", func(t *testing.T) {
+		Get(t, client, origin.URL+"/request")
+		require.Equal(t, 1, cache.Len())
+
+		// The request bypasses the cache and removes the stored response.
+		_, body := Get(t, client, origin.URL+"/request", "Cache-Control", "no-store")
+		require.Equal(t, "response 2", body)
+		require.Zero(t, cache.Len())
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		Get(t, client, origin.URL+"/response")
+		require.Equal(t, 1, cache.Len())
+
+		// A no-store response is not stored and removes the previous response.
+		nostore.Store(true)
+		_, body := Get(t, client, origin.URL+"/response")
+		require.Equal(t, "response 4", body)
+		require.Zero(t, cache.Len())
+
+		_, body = Get(t, client, origin.URL+"/response")
+		require.Equal(t, "response 5", body)
+		require.Zero(t, cache.Len())
+	})
+}
+
+func TestTransportNoCache(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.URL.Path == "/response" {
+			w.Header().Set("Cache-Control", "no-cache, max-age=3600, stale-while-revalidate=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+
+	t.Run("Response", func(t *testing.T) {
+		Get(t, client, origin.URL+"/response")
+		_, body := Get(t, client, origin.URL+"/response")
+		require.Equal(t, "response 1", body)
+		require.Equal(t, int64(2), origin.Requests())
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		Get(t, client, origin.URL+"/request")
+		Get(t, client, origin.URL+"/request")
+		require.Equal(t, int64(3), origin.Requests())
+
+		_, body := Get(t, client, origin.URL+"/request", "Cache-Control", "no-cache")
+		require.Equal(t, "response 3", body)
+		require.Equal(t, int64(4), origin.Requests())
+	})
+}
+
+func TestTransportSharedMode(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		case "/private-field":
+			w.Header().Set("Cache-Control", `private="Set-Cookie", max-age=3600`)
+			w.Header().Set("Set-Cookie", "session=secret")
+		case "/s-maxage":
+			w.Header().Set("Cache-Control", "max-age=0, s-maxage=3600")
+		case "/auth":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/auth-public":
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+		case "/auth-must-revalidate":
+			w.Header().Set("Cache-Control", "must-revalidate, max-age=3600")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tests := []struct {
+		path    string
+		headers []string
+		private bool // expected to be served from cache in a private cache
+		shared  bool // expected to be served from cache in a shared cache
+	}{
+		{"/private", nil, true, false},
+		{"/private-field", nil, true, true},
+		{"/s-maxage", nil, false, true},
+		{"/auth", []string{"Authorization", "Bearer token"}, true, false},
+		{"/auth-public", []string{"Authorization", "Bearer token"}, true, true},
+		{"/auth-must-revalidate", []string{"Authorization", "Bearer token"}, true, true},
+	}
+
+	for _, shared := range []bool{false, true} {
+		transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, Shared: shared}
+		client := transport.Client()
+
+		for _, test := range tests {
+			before := origin.Requests()
+			Get(t, client, origin.URL+test.path, test.headers...)
+			rep, _ := Get(t, client, origin.URL+test.path, test.headers...)
+
+			expected := test.private
+			if shared {
+				expected = test.shared
+			}
+
+			cached := origin.Requests()-before == 1
+			require.Equal(t, expected, cached, "Test Case: %q (shared=%t)", test.path, shared)
+
+			if shared && test.path == "/private-field" {
+				require.Empty(t, rep.Header.Get("Set-Cookie"), "private field should not be stored")
+			}
+		}
+	}
+}
+
+func TestTransportPartitionByCredential(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		fmt.Fprintf(w, "response %d for %q", n, r.Header.Get("Authorization"))
+	})
+
+	transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, Shared: true, PartitionByCredential: true}
+	client := transport.Client()
+
+	_, alice := Get(t, client, origin.URL, "Authorization", "Bearer alice")
+	_, bob := Get(t, client, origin.URL, "Authorization", "Bearer bob")
+	require.NotEqual(t, alice, bob)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Each credential is served its own stored response.
+	_, body := Get(t, client, origin.URL, "Authorization", "Bearer alice")
+	require.Equal(t, alice, body)
+	_, body = Get(t, client, origin.URL, "Authorization", "Bearer bob")
+	require.Equal(t, bob, body)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Unauthorized requests use the shared cache where private responses are not stored.
+	Get(t, client, origin.URL)
+	Get(t, client, origin.URL)
+	require.Equal(t, int64(4), origin.Requests())
+}
+
+func TestTransportKeyHeaders(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d for %q", n, r.Header.Get("X-Tenant-ID"))
+	})
+
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithKeyHeaders("x-tenant-id"))
+	client := transport.Client()
+
+	_, acme := Get(t, client, origin.URL, "X-Tenant-ID", "acme")
+	_, globex := Get(t, client, origin.URL, "X-Tenant-ID", "globex")
+	require.NotEqual(t, acme, globex)
+
+	_, body := Get(t, client, origin.URL, "X-Tenant-ID", "acme")
+	require.Equal(t, acme, body)
+	_, body = Get(t, client, origin.URL, "X-Tenant-ID", "globex")
+	require.Equal(t, globex, body)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Profiles with their own key headers replace those of the Transport.
+	transport.UpdateConfig(httpcache.Config{Profiles: map[string]*httpcache.Profile{u.Host: {KeyHeaders: []string{"X-Region"}}}})
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme", "X-Region", "eu")
+	_, body = Get(t, client, origin.URL, "X-Tenant-ID", "globex", "X-Region", "eu")
+	require.Equal(t, `response 3 for "acme"`, body)
+	require.Equal(t, int64(3), origin.Requests())
+}
+
+func TestTransportRequestDirectives(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		// The stored response is fresh for another 30 seconds or stale by 30 seconds.
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/stale" {
+			w.Header().Set("Age", "90")
+		} else {
+			w.Header().Set("Age", "30")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tests := []struct {
+		path      string
+		directive string
+		cached    bool
+	}{
+		{"/fresh", "", true},
+		{"/fresh", "max-age=10", false},
+		{"/fresh", "max-age=60", true},
+		{"/fresh", "max-age=0", false},
+		{"/fresh", "min-fresh=40", false},
+		{"/fresh", "min-fresh=20", true},
+		{"/stale", "", false},
+		{"/stale", "max-stale=10", false},
+		{"/stale", "max-stale=60", true},
+		{"/stale", "max-stale", true},
+		{"/stale", "max-stale, max-age=60", false},
+	}
+
+	for _, test := range tests {
+		client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+		Get(t, client, origin.URL+test.path)
+
+		before := origin.Requests()
+		if test.directive == "" {
+			Get(t, client, origin.URL+test.path)
+		} else {
+			Get(t, client, origin.URL+test.path, "Cache-Control", test.directive)
+		}
+
+		cached := origin.Requests() == before
+		require.Equal(t, test.cached, cached, "Test Case: %q with %q", test.path, test.directive)
+	}
+}
+
+func TestTransportOnlyIfCached(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/stale" {
+			w.Header().Set("Age", "90")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+
+	// Nothing is stored so the origin must not be contacted.
+	rep, _ := Get(t, client, origin.URL+"/fresh", "Cache-Control", "only-if-cached")
+	require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+	require.Zero(t, origin.Requests())
+
+	_, body := Get(t, client, origin.URL+"/fresh")
+	rep, cached := Get(t, client, origin.URL+"/fresh", "Cache-Control", "only-if-cached")
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, body, cached)
+
+	// Stale responses are only served if the client accepts them.
+	Get(t, client, origin.URL+"/stale")
+	rep, _ = Get(t, client, origin.URL+"/stale", "Cache-Control", "only-if-cached")
+	require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+
+	rep, _ = Get(t, client, origin.URL+"/stale", "Cache-Control", "only-if-cached, max-stale")
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, int64(2), origin.Requests())
+}
+
+func TestTransportVary(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/star" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Set("Vary", "accept-language, Accept-Encoding")
+		}
+		fmt.Fprintf(w, "response %d in %q", n, r.Header.Get("Accept-Language"))
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	_, en := Get(t, client, origin.URL, "Accept-Language", "en")
+	_, fr := Get(t, client, origin.URL, "Accept-Language", "fr")
+	require.NotEqual(t, en, fr)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Each variant is served to requests with matching header values.
+	_, body := Get(t, client, origin.URL, "Accept-Language", "en")
+	require.Equal(t, en, body)
+	_, body = Get(t, client, origin.URL, "Accept-Language", "fr")
+	require.Equal(t, fr, body)
+	require.Equal(t, int64(2), origin.Requests())
+
+	// A request without the header is a different variant.
+	Get(t, client, origin.URL)
+	require.Equal(t, int64(3), origin.Requests())
+
+	// The vary index and the variants are stored.
+	require.Equal(t, 4, cache.Len())
+
+	// Responses that vary on everything are never stored.
+	Get(t, client, origin.URL+"/star")
+	Get(t, client, origin.URL+"/star")
+	require.Equal(t, int64(5), origin.Requests())
+}
+
+func TestTransportLegacyHeaders(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/expires-invalid":
+			w.Header().Set("Expires", "0")
+		default:
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Last-Modified", time.Now().Add(-100*time.Hour).UTC().Format(http.TimeFormat))
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+
+	// An invalid Expires date is already expired, even with a heuristic validator.
+	Get(t, client, origin.URL+"/expires-invalid")
+	Get(t, client, origin.URL+"/expires-invalid")
+	require.Equal(t, int64(2), origin.Requests())
+
+	Get(t, client, origin.URL+"/expires")
+	Get(t, client, origin.URL+"/expires")
+	require.Equal(t, int64(3), origin.Requests())
+
+	// Pragma: no-cache requires revalidation when Cache-Control is absent.
+	Get(t, client, origin.URL+"/expires", "Pragma", "no-cache")
+	require.Equal(t, int64(4), origin.Requests())
+}
+
+func TestTransportImmutable(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/immutable":
+			w.Header().Set("Cache-Control", "max-age=3600, immutable")
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=60, immutable")
+			w.Header().Set("Age", "90")
+		default:
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/immutable", true},
+		{"/mutable", false},
+		{"/stale", false},
+	}
+
+	for _, directive := range []string{"no-cache", "max-age=0"} {
+		for _, test := range tests {
+			client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+			Get(t, client, origin.URL+test.path)
+
+			before := origin.Requests()
+			Get(t, client, origin.URL+test.path, "Cache-Control", directive)
+			cached := origin.Requests() == before
+			require.Equal(t, test.cached, cached, "Test Case: %q with %q", test.path, directive)
+		}
+	}
+}
+
+func TestTransportNegativeCaching(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/explicit":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tests := []struct {
+		path     string
+		statuses []int
+		cached   bool
+	}{
+		{"/missing", nil, true},
+		{"/gone", nil, true},
+		{"/forbidden", nil, false},
+		{"/forbidden", []int{http.StatusForbidden}, true},
+		{"/explicit", nil, false},
+		{"/no-store", nil, false},
+	}
+
+	for _, test := range tests {
+		transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, NegativeTTL: time.Minute, NegativeStatusCodes: test.statuses}
+		client := transport.Client()
+
+		before := origin.Requests()
+		Get(t, client, origin.URL+test.path)
+		Get(t, client, origin.URL+test.path)
+		cached := origin.Requests()-before == 1
+		require.Equal(t, test.cached, cached, "Test Case: %q with %v", test.path, test.statuses)
+	}
+
+	// Negative responses are not cached without a negative TTL.
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	before := origin.Requests()
+	Get(t, client, origin.URL+"/missing")
+	Get(t, client, origin.URL+"/missing")
+	require.Equal(t, before+2, origin.Requests())
+}
+
+func TestTransportStatusCodes(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		switch r.URL.Path {
+		case "/accepted":
+			w.WriteHeader(http.StatusAccepted)
+		case "/missing":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, StatusCodes: []int{http.StatusOK, http.StatusAccepted}}
+	client := transport.Client()
+
+	// Responses with listed status codes are stored without explicit freshness and
+	// revalidated when they are requested again.
+	Get(t, client, origin.URL+"/accepted")
+	rep, body := Get(t, client, origin.URL+"/accepted")
+	require.Equal(t, http.StatusAccepted, rep.StatusCode)
+	require.Equal(t, "response 1", body)
+
+	// Responses with other status codes are not stored even with explicit freshness.
+	Get(t, client, origin.URL+"/missing")
+	_, body = Get(t, client, origin.URL+"/missing")
+	require.Equal(t, "response 4", body)
+}
+
+func TestTransportLazyDeletion(t *testing.T) {
+	var failing atomic.Bool
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/validators":
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate")
+			w.Header().Set("ETag", `"v1"`)
+		case "/revalidate":
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60, stale-if-error=60")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(cache)
+	transport.Clock = clock
+	client := transport.Client()
+
+	Get(t, client, origin.URL)
+	Get(t, client, origin.URL+"/validators")
+	Get(t, client, origin.URL+"/revalidate")
+	require.Equal(t, 3, cache.Len())
+
+	// Stale responses are kept while they may be served if the origin fails.
+	failing.Store(true)
+	clock.Advance(90 * time.Second)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, 3, cache.Len())
+
+	// Stale responses are kept even past their stale-if-error window since requests
+	// may still accept them with max-stale or stale-if-error.
+	clock.Advance(time.Minute)
+	rep, _ := Get(t, client, origin.URL)
+	require.Equal(t, http.StatusServiceUnavailable, rep.StatusCode)
+	_, body = Get(t, client, origin.URL, "Cache-Control", "max-stale")
+	require.Equal(t, "response 1", body)
+
+	// Expired responses that can neither be revalidated nor served stale are removed
+	// before the response is returned when they are not replaced.
+	rep, _ = Get(t, client, origin.URL+"/revalidate")
+	require.Equal(t, http.StatusServiceUnavailable, rep.StatusCode)
+	_, ok := cache.Get(origin.URL + "/revalidate")
+	require.False(t, ok, "expected expired response to be removed")
+
+	Get(t, client, origin.URL+"/validators")
+	_, ok = cache.Get(origin.URL + "/validators")
+	require.True(t, ok, "expected response with validators to be kept")
+
+	_, ok = cache.Get(origin.URL)
+	require.True(t, ok, "expected response that may be served stale to be kept")
+}
+
+func TestTransportServeStaleOnNetworkError(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Clock = clock
+	client := transport.Client()
+	Get(t, client, origin.URL)
+
+	// Without the option, stale responses are not served when the origin is unreachable.
+	origin.Close()
+	clock.Advance(time.Hour)
+	_, err := client.Get(origin.URL)
+	require.Error(t, err)
+
+	transport.ServeStaleOnNetworkError = true
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "3600", rep.Header.Get("Age"))
+
+	// Canceled requests are not served stale.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, origin.URL, nil)
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTransportMustRevalidate(t *testing.T) {
+	var failing atomic.Bool
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/must":
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate, stale-while-revalidate=600, stale-if-error=600")
+		case "/proxy":
+			w.Header().Set("Cache-Control", "max-age=60, proxy-revalidate, stale-if-error=600")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	tests := []struct {
+		path    string
+		shared  bool
+		reused  bool
+		headers []string
+	}{
+		{"/must", false, false, []string{"Cache-Control", "max-stale=600"}},
+		{"/must", true, false, []string{"Cache-Control", "max-stale"}},
+		{"/proxy", true, false, []string{"Cache-Control", "max-stale=600"}},
+		{"/proxy", false, true, []string{"Cache-Control", "max-stale=600"}},
+	}
+
+	for _, test := range tests {
+		transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+		transport.Clock, transport.Shared = clock, test.shared
+		client := transport.Client()
+
+		failing.Store(false)
+		_, first := Get(t, client, origin.URL+test.path)
+		clock.Advance(2 * time.Minute)
+
+		// The stale response is only served if it is not required to be revalidated.
+		_, body := Get(t, client, origin.URL+test.path, test.headers...)
+		require.Equal(t, test.reused, body == first, "Test Case: %q shared=%t", test.path, test.shared)
+
+		// A failing origin does not cause the stale response to be served.
+		clock.Advance(2 * time.Minute)
+		failing.Store(true)
+		rep, body := Get(t, client, origin.URL+test.path)
+		if test.reused {
+			require.Equal(t, http.StatusOK, rep.StatusCode, "Test Case: %q shared=%t", test.path, test.shared)
+			require.Equal(t, first, body, "Test Case: %q shared=%t", test.path, test.shared)
+		} else {
+			require.Equal(t, http.StatusServiceUnavailable, rep.StatusCode, "Test Case: %q shared=%t", test.path, test.shared)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package httpcache
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessTracker records when entries were last read or written by the Transport so
+// that entries that are no longer used can be evicted, independent of their freshness,
+// and how often they were read since they were stored. Access times are only kept in
+// memory; entries without a recorded access are treated as if they were last accessed
+// when they were stored or when tracking started, whichever is later.
+type accessTracker struct {
+	mu      sync.Mutex
+	started time.Time
+	last    map[string]access
+}
+
+// access is the most recent access of an entry and the number of times it was read
+// since it was last written.
+type access struct {
+	last     time.Time
+	lastRead time.Time
+	reads    int64
+}
+
+// touch records a write of the keys at the specified time.
+func (a *accessTracker) touch(now time.Time, keys ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init(now)
+	for _, key := range keys {
+		a.last[key] = access{last: now}
+	}
+}
+
+// read records a read of the keys at the specified time.
+func (a *accessTracker) read(now time.Time, keys ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init(now)
+	for _, key := range keys {
+		acc := a.last[key]
+		acc.last, acc.lastRead = now, now
+		acc.reads++
+		a.last[key] = acc
+	}
+}
+
+// lastAccess returns the time the key was last accessed, if it was recorded, and the
+// time that tracking started.
+func (a *accessTracker) lastAccess(now time.Time, key string) (last time.Time, ok bool, started time.Time) {
+	acc, ok, started := a.get(now, key)
+	return acc.last, ok, started
+}
+
+// get returns the recorded access of the key, if any, and the time tracking started.
+func (a *accessTracker) get(now time.Time, key string) (acc access, ok bool, started time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init(now)
+	acc, ok = a.last[key]
+	return acc, ok, a.started
+}
+
+// since returns the time that tracking started.
+func (a *accessTracker) since(now time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init(now)
+	return a.started
+}
+
+// prune forgets the accesses of all keys that are not listed.
+func (a *accessTracker) prune(keys []string) {
+	listed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		listed[key] = struct{}{}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.last {
+		if _, ok := listed[key]; !ok {
+			delete(a.last, key)
+		}
+	}
+}
+
+func (a *accessTracker) forget(key string) {
+	a.mu.Lock()
+	delete(a.last, key)
+	a.mu.Unlock()
+}
+
+func (a *accessTracker) init(now time.Time) {
+	if a.last == nil {
+		a.started = now
+		a.last = make(map[string]access)
+	}
+}
+
+// tracking returns true if accesses of entries are recorded, i.e. if idle eviction or
+// usage tracking is enabled.
+func (t *Transport) tracking() bool {
+	return t.MaxIdle > 0 || t.TrackUsage
+}
+
+// touch records a write of the keys if accesses are tracked.
+func (t *Transport) touch(keys ...string) {
+	if t.tracking() {
+		t.accesses.touch(t.now(), keys...)
+	}
+}
+
+// read records a read of the stored response at the key, and of its primary key if it
+// is a variant, if accesses are tracked.
+func (t *Transport) read(primary, key string) {
+	if !t.tracking() {
+		return
+	}
+
+	if primary == key {
+		t.accesses.read(t.now(), key)
+		return
+	}
+	t.accesses.read(t.now(), primary, key)
+}
+
+// remove deletes the entry at the key from the cache and forgets its accesses.
+func (t *Transport) remove(cache Cache, key string) {
+	cache.Del(key)
+	t.accesses.forget(key)
+}
+
+// untouched returns true if the key stores values that the Transport does not read or
+// write as it handles requests, e.g. Aside values, collection indexes and pending
+// revalidations, which must not be evicted as idle.
+func untouched(key string) bool {
+	if strings.HasPrefix(key, defaultAsidePrefix) {
+		return true
+	}
+
+	if strings.HasPrefix(key, "[") {
+		if _, unversioned, ok := strings.Cut(key, "] "); ok {
+			key = unversioned
+		}
+	}
+	return strings.HasPrefix(key, collectionKey("", "")) || key == revalidationsKey("")
+}
+
+// EvictIdle removes all entries from the cache that have not been read or written by
+// the Transport for longer than MaxIdle, regardless of their freshness, and returns the
+// number of entries that were removed. It should be called periodically so that rarely
+// used entries do not occupy storage indefinitely. Values that the Transport does not
+// read or write as it handles requests, e.g. Aside values, are not removed. The access
+// times of entries that are no longer in the cache are discarded. The cache must
+// implement KeyLister otherwise ErrKeysUnsupported is returned. If MaxIdle is not set,
+// no entries are removed.
+func (t *Transport) EvictIdle() (n int, err error) {
+	lister, ok := t.Cache.(KeyLister)
+	if !ok {
+		return 0, ErrKeysUnsupported
+	}
+
+	if t.MaxIdle <= 0 {
+		return 0, nil
+	}
+
+	now := t.now()
+	keys := lister.Keys()
+	for _, key := range keys {
+		if untouched(key) {
+			continue
+		}
+
+		last, ok, started := t.accesses.lastAccess(now, key)
+		if !ok {
+			val, found := t.Cache.Get(key)
+			if !found {
+				continue
+			}
+
+			// Values that are neither responses nor vary indexes, e.g. of an Aside with
+			// a custom prefix, were not stored by the Transport.
+			last = started
+			meta, err := parseMetadata(val, now)
+			switch {
+			case err == nil && meta.Stored.After(last):
+				last = meta.Stored
+			case err != nil && !bytes.HasPrefix(val, []byte(varyIndexPrefix)):
+				continue
+			}
+		}
+
+		if now.Sub(last) >= t.MaxIdle {
+			t.remove(t.Cache, key)
+			n++
+		}
+	}
+
+	t.accesses.prune(keys)
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportEvictIdle(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=86400")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(cache)
+	transport.Clock = clock
+	client := transport.Client()
+
+	// Idle eviction is disabled by default.
+	n, err := transport.EvictIdle()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	transport.MaxIdle = time.Hour
+	Get(t, client, origin.URL+"/read")
+	Get(t, client, origin.URL+"/unread")
+
+	clock.Advance(30 * time.Minute)
+	Get(t, client, origin.URL+"/read")
+
+	// Entries are evicted once they are idle even though they are still fresh.
+	clock.Advance(40 * time.Minute)
+	n, err = transport.EvictIdle()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	_, ok := cache.Get(origin.URL + "/unread")
+	require.False(t, ok)
+	_, ok = cache.Get(origin.URL + "/read")
+	require.True(t, ok)
+
+	// A new transport does not evict entries it has not tracked until they are idle
+	// for MaxIdle after it started tracking.
+	restarted := httpcache.NewTransport(cache)
+	restarted.Clock, restarted.MaxIdle = clock, time.Hour
+	n, err = restarted.EvictIdle()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	clock.Advance(time.Hour)
+	n, err = restarted.EvictIdle()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Zero(t, cache.Len())
+
+	_, err = httpcache.NewTransport(nopCache{}).EvictIdle()
+	require.ErrorIs(t, err, httpcache.ErrKeysUnsupported)
+}
+
+func TestTransportEvictIdleTracking(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=86400")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(cache, httpcache.WithClock(clock), httpcache.WithMaxIdle(time.Hour))
+	client := transport.Client()
+
+	// Removing entries through the Transport forgets their accesses.
+	Get(t, client, origin.URL+"/invalidated")
+	require.Equal(t, 1, transport.TrackedKeys())
+	require.NoError(t, transport.Invalidate(origin.URL+"/invalidated"))
+	require.Zero(t, transport.TrackedKeys())
+
+	// Accesses of entries removed from the cache by others are pruned.
+	Get(t, client, origin.URL+"/deleted")
+	cache.Del(origin.URL + "/deleted")
+	require.Equal(t, 1, transport.TrackedKeys())
+
+	// Values that the Transport does not handle are never evicted.
+	aside := &httpcache.Aside[string]{Cache: cache}
+	custom := &httpcache.Aside[string]{Cache: cache, Prefix: "tokens/"}
+	require.NoError(t, aside.Put("token", "secret"))
+	require.NoError(t, custom.Put("token", "secret"))
+
+	clock.Advance(2 * time.Hour)
+	n, err := transport.EvictIdle()
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Zero(t, transport.TrackedKeys())
+
+	for _, a := range []*httpcache.Aside[string]{aside, custom} {
+		value, ok := a.Get("token")
+		require.True(t, ok, "Test Case: %q", a.Prefix)
+		require.Equal(t, "secret", value, "Test Case: %q", a.Prefix)
+	}
+}
@@ -1,38 +1,130 @@
 package httpcache
 
-import "sync"
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
 
 // InMemoryCache is an implementation of Cache that stores responses in an in-memory
 // map. This cache if volatile and will be cleared when the program exits, but is often
 // a good choice for testing or short-lived applications.
 type InMemoryCache struct {
 	sync.RWMutex
-	store map[string][]byte
+	store map[string]inmemEntry
+	seq   uint64
+	size  int64
+}
+
+// inmemEntry records the order in which values were stored so that the oldest entries
+// can be evicted under memory pressure.
+type inmemEntry struct {
+	val []byte
+	seq uint64
 }
 
 var _ Cache = (*InMemoryCache)(nil)
+var _ Evictor = (*InMemoryCache)(nil)
+var _ KeyLister = (*InMemoryCache)(nil)
+var _ Sizer = (*InMemoryCache)(nil)
+
+// NewMemoryClient returns an *http.Client that caches responses in a new InMemoryCache.
+func NewMemoryClient() *http.Client {
+	return NewTransport(&InMemoryCache{}).Client()
+}
 
 // Get the []byte representation of the response and true if present.
 func (c *InMemoryCache) Get(key string) (val []byte, ok bool) {
+	var entry inmemEntry
 	c.RLock()
-	val, ok = c.store[key]
+	entry, ok = c.store[key]
 	c.RUnlock()
-	return
+	return entry.val, ok
 }
 
 // Put stores the []byte representation of the response with the specified key.
 func (c *InMemoryCache) Put(key string, val []byte) {
 	c.Lock()
 	if c.store == nil {
-		c.store = make(map[string][]byte)
+		c.store = make(map[string]inmemEntry)
 	}
-	c.store[key] = val
+	if prev, ok := c.store[key]; ok {
+		c.size -= EntrySize(key, prev.val)
+	}
+
+	c.seq++
+	c.store[key] = inmemEntry{val: val, seq: c.seq}
+	c.size += EntrySize(key, val)
 	c.Unlock()
 }
 
 // Del removes the cached response associated with the key.
 func (c *InMemoryCache) Del(key string) {
 	c.Lock()
-	delete(c.store, key)
+	c.delete(key)
 	c.Unlock()
 }
+
+// delete removes the entry for the key and its size; the lock must be held.
+func (c *InMemoryCache) delete(key string) {
+	if entry, ok := c.store[key]; ok {
+		c.size -= EntrySize(key, entry.val)
+		delete(c.store, key)
+	}
+}
+
+// Keys returns the keys of all entries in the cache.
+func (c *InMemoryCache) Keys() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	keys := make([]string, 0, len(c.store))
+	for key := range c.store {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Len returns the number of entries in the cache.
+func (c *InMemoryCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.store)
+}
+
+// Size returns the number of bytes accounted for the entries of the cache with
+// EntrySize. Implements Sizer.
+func (c *InMemoryCache) Size() (int64, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.size, nil
+}
+
+// EvictOldest removes up to n of the least recently stored entries from the cache and
+// returns the number of entries that were removed.
+func (c *InMemoryCache) EvictOldest(n int) int {
+	c.Lock()
+	defer c.Unlock()
+
+	if n <= 0 {
+		return 0
+	}
+
+	if n >= len(c.store) {
+		n = len(c.store)
+		clear(c.store)
+		c.size = 0
+		return n
+	}
+
+	keys := make([]string, 0, len(c.store))
+	for key := range c.store {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return c.store[keys[i]].seq < c.store[keys[j]].seq })
+	for _, key := range keys[:n] {
+		c.delete(key)
+	}
+	return n
+}
@@ -1,7 +1,9 @@
 package httpcache_test
 
 import (
+	"fmt"
 	"math/rand/v2"
+	"net/http"
 	"sync"
 	"testing"
 
@@ -48,3 +50,39 @@ func TestInMemoryRace(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestInMemoryEvictOldest(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Put(key, []byte(key))
+	}
+
+	// Overwriting a key makes it the most recently stored entry.
+	cache.Put("a", []byte("a"))
+	require.Equal(t, 4, cache.Len())
+
+	require.Equal(t, 2, cache.EvictOldest(2))
+	require.Equal(t, 2, cache.Len())
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		_, ok := cache.Get(key)
+		require.Equal(t, expected, ok, "unexpected presence of key %q", key)
+	}
+
+	require.Equal(t, 0, cache.EvictOldest(0))
+	require.Equal(t, 2, cache.EvictOldest(10))
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestNewMemoryClient(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := httpcache.NewMemoryClient()
+	_, body := Get(t, client, origin.URL)
+	_, cached := Get(t, client, origin.URL)
+	require.Equal(t, body, cached)
+	require.Equal(t, int64(1), origin.Requests())
+}
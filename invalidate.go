@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IsUnsafeMethod returns true if the request method is not safe as defined by RFC 9110
+// §9.2.1, i.e. the request may change the state of the origin server.
+func IsUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsSameOrigin returns true if the URLs have the same scheme, host, and port.
+func IsSameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b))
+}
+
+// hostPort returns the host of the URL including the default port of its scheme.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return u.Host + ":80"
+	case "https":
+		return u.Host + ":443"
+	default:
+		return u.Host
+	}
+}
+
+// invalidate removes the stored responses for the target URI of an unsafe request and
+// for the URIs in the Location and Content-Location headers of the response if they
+// have the same origin (RFC 9111 §4.4). Responses are only invalidated if the request
+// succeeded, i.e. the response has a non-error status code.
+func (t *Transport) invalidate(req *http.Request, rep *http.Response) {
+	if rep.StatusCode < 200 || rep.StatusCode >= 400 {
+		return
+	}
+
+	t.invalidateURL(req, req.URL)
+	for _, name := range []string{"Location", "Content-Location"} {
+		if value := rep.Header.Get(name); value != "" {
+			if target, err := req.URL.Parse(value); err == nil && IsSameOrigin(req.URL, target) {
+				t.invalidateURL(req, target)
+			}
+		}
+	}
+}
+
+// invalidateURL removes the stored responses for the URL that a GET request with the
+// same headers as the request could be served, including all of the variants of a
+// response that varies and its partial and derived variants.
+func (t *Transport) invalidateURL(req *http.Request, target *url.URL) {
+	get := &http.Request{Method: http.MethodGet, URL: target, Header: req.Header, Host: target.Host}
+	policy := t.policy(get)
+	primary := policy.key(get)
+
+	t.deleteEntry(policy.cache, primary)
+	t.deleteEntry(policy.cache, partialKey(primary))
+	for _, d := range t.Derivations {
+		t.deleteEntry(policy.cache, derivedKey(primary, d.Name))
+	}
+}
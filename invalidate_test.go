@@ -0,0 +1,137 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestIsUnsafeMethod(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace} {
+		require.False(t, httpcache.IsUnsafeMethod(method), "Test Case: %q", method)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, "PURGE"} {
+		require.True(t, httpcache.IsUnsafeMethod(method), "Test Case: %q", method)
+	}
+}
+
+func TestIsSameOrigin(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"http://example.com/a", "http://example.com/b?q=1", true},
+		{"http://example.com/", "HTTP://EXAMPLE.COM/", true},
+		{"http://example.com/", "http://example.com:80/", true},
+		{"https://example.com/", "https://example.com:443/", true},
+		{"http://example.com/", "https://example.com/", false},
+		{"http://example.com/", "http://example.com:8080/", false},
+		{"http://example.com/", "http://api.example.com/", false},
+	}
+
+	for _, test := range tests {
+		a, err := url.Parse(test.a)
+		require.NoError(t, err)
+		b, err := url.Parse(test.b)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, httpcache.IsSameOrigin(a, b), "Test Case: %q and %q", test.a, test.b)
+	}
+}
+
+func TestTransportInvalidation(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case r.URL.Path == "/fail":
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/create":
+			w.Header().Set("Location", "/a")
+			w.Header().Set("Content-Location", "http://other.example/a")
+			w.WriteHeader(http.StatusCreated)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	cache.Put("http://other.example/a", []byte("other"))
+	client := httpcache.NewTransport(cache).Client()
+
+	// cached reports if a GET request for the path is served from the cache.
+	cached := func(path string) bool {
+		before := origin.Requests()
+		Get(t, client, origin.URL+path)
+		return origin.Requests() == before
+	}
+
+	post := func(path string) {
+		rep, err := client.Post(origin.URL+path, "text/plain", nil)
+		require.NoError(t, err)
+		rep.Body.Close()
+	}
+
+	require.False(t, cached("/a"))
+	require.True(t, cached("/a"))
+
+	// A successful unsafe request invalidates the target URI.
+	post("/a")
+	require.False(t, cached("/a"))
+	require.True(t, cached("/a"))
+
+	// Failed requests do not invalidate the target URI.
+	require.False(t, cached("/fail"))
+	post("/fail")
+	require.True(t, cached("/fail"))
+
+	// Location targets are invalidated if they have the same origin.
+	post("/create")
+	require.False(t, cached("/a"))
+
+	_, ok := cache.Get("http://other.example/a")
+	require.True(t, ok, "expected entries for other origins to remain")
+}
+
+func TestTransportInvalidationVary(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Vary", "Accept")
+		}
+		fmt.Fprintf(w, "%s response %d", r.Header.Get("Accept"), n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+
+	_, body := Get(t, client, origin.URL, "Accept", "json")
+	require.Equal(t, "json response 1", body)
+	_, body = Get(t, client, origin.URL, "Accept", "text")
+	require.Equal(t, "text response 2", body)
+
+	// Every variant is invalidated, not only the vary index.
+	Post(t, client, origin.URL, "{}")
+	_, body = Get(t, client, origin.URL, "Accept", "xml")
+	require.Equal(t, "xml response 4", body)
+	_, body = Get(t, client, origin.URL, "Accept", "json")
+	require.Equal(t, "json response 5", body)
+	_, body = Get(t, client, origin.URL, "Accept", "text")
+	require.Equal(t, "text response 6", body)
+
+	// Invalidating the URL and requests with no-store remove every variant too.
+	require.NoError(t, transport.Invalidate(origin.URL))
+	_, body = Get(t, client, origin.URL, "Accept", "xml")
+	require.Equal(t, "xml response 7", body)
+	_, body = Get(t, client, origin.URL, "Accept", "json")
+	require.Equal(t, "json response 8", body)
+
+	Get(t, client, origin.URL, "Accept", "text", "Cache-Control", "no-store")
+	_, body = Get(t, client, origin.URL, "Accept", "xml")
+	require.Equal(t, "xml response 10", body)
+	_, body = Get(t, client, origin.URL, "Accept", "json")
+	require.Equal(t, "json response 11", body)
+}
@@ -0,0 +1,83 @@
+package httpcache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// collapseLanguage returns the request with its Accept-Language header replaced by the
+// most preferred of the supported languages, so that requests for regional variants of
+// a language share a cache key and Vary match. The original request is returned if the
+// Transport has no supported languages or none of them are acceptable.
+func (t *Transport) collapseLanguage(req *http.Request) *http.Request {
+	if len(t.Languages) == 0 {
+		return req
+	}
+
+	value := req.Header.Get("Accept-Language")
+	if value == "" {
+		return req
+	}
+
+	language, ok := negotiateLanguage(value, t.Languages)
+	if !ok || language == value {
+		return req
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Language", language)
+	return req
+}
+
+// negotiateLanguage returns the supported language that best matches the language
+// ranges of an Accept-Language header (RFC 9110 §12.5.4). A supported language matches
+// a range that is equal to it or that is a more specific tag with it as a prefix, e.g.
+// en matches en-US and en-GB. The wildcard matches the first supported language.
+func negotiateLanguage(value string, supported []string) (string, bool) {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(value, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		r := languageRange{tag: strings.TrimSpace(tag), q: 1}
+		if qvalue, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(qvalue, 64); err == nil {
+				r.q = q
+			}
+		}
+
+		if r.tag != "" && r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+
+	// Ranges with equal weights keep the order of the header.
+	slices.SortStableFunc(ranges, func(a, b languageRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return supported[0], true
+		}
+
+		for _, language := range supported {
+			if strings.EqualFold(r.tag, language) || (len(r.tag) > len(language) && strings.EqualFold(r.tag[:len(language)+1], language+"-")) {
+				return language, true
+			}
+		}
+	}
+	return "", false
+}
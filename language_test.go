@@ -0,0 +1,63 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	supported := []string{"en", "fr", "pt-BR"}
+
+	tests := []struct {
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"en", "en", true},
+		{"en-US", "en", true},
+		{"EN-gb", "en", true},
+		{"de, fr-CA;q=0.8, en;q=0.5", "fr", true},
+		{"en;q=0.5, fr;q=0.9", "fr", true},
+		{"fr;q=0, en", "en", true},
+		{"pt-BR", "pt-BR", true},
+		{"pt", "", false},
+		{"enx", "", false},
+		{"de, *;q=0.1", "en", true},
+		{"de, ja", "", false},
+	}
+
+	for _, test := range tests {
+		language, ok := httpcache.NegotiateLanguage(test.value, supported)
+		require.Equal(t, test.ok, ok, "Test Case: %q", test.value)
+		require.Equal(t, test.expected, language, "Test Case: %q", test.value)
+	}
+}
+
+func TestTransportLanguages(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "response %d in %s", n, r.Header.Get("Accept-Language"))
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Languages = []string{"en", "fr"}
+	client := transport.Client()
+
+	_, body := Get(t, client, origin.URL, "Accept-Language", "en-US")
+	require.Equal(t, "response 1 in en", body)
+
+	_, body = Get(t, client, origin.URL, "Accept-Language", "en-GB, fr;q=0.5")
+	require.Equal(t, "response 1 in en", body)
+
+	_, body = Get(t, client, origin.URL, "Accept-Language", "fr-CA")
+	require.Equal(t, "response 2 in fr", body)
+
+	// Unsupported languages are passed through unchanged.
+	_, body = Get(t, client, origin.URL, "Accept-Language", "de")
+	require.Equal(t, "response 3 in de", body)
+}
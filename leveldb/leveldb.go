@@ -2,15 +2,33 @@ package leveldb
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"go.rtnl.ai/httpcache"
 )
 
 // Cache is an implementation of httpcache.Cache with leveldb storage
 type Cache struct {
 	db *leveldb.DB
+
+	mu         sync.Mutex
+	compaction *schedule
+	closed     bool
+}
+
+var _ httpcache.Cache = (*Cache)(nil)
+var _ httpcache.Sizer = (*Cache)(nil)
+
+// schedule tracks a background compaction routine so that it can be stopped.
+type schedule struct {
+	stop chan struct{}
+	done chan struct{}
 }
 
 // New returns a cache that will store cached data in a leveldb database at the path.
@@ -22,6 +40,16 @@ func New(path string) (_ *Cache, err error) {
 	return cache, nil
 }
 
+// NewClient returns an *http.Client that caches responses in a leveldb database at the
+// path. The cache is returned so that it can be closed when the client is no longer used.
+func NewClient(path string) (_ *http.Client, _ *Cache, err error) {
+	var cache *Cache
+	if cache, err = New(path); err != nil {
+		return nil, nil, err
+	}
+	return httpcache.NewTransport(cache).Client(), cache, nil
+}
+
 // Make returns a cache using the specified db instance as the underlying storage.
 func Make(db *leveldb.DB) *Cache {
 	return &Cache{db: db}
@@ -33,7 +61,7 @@ func (c *Cache) Get(key string) ([]byte, bool) {
 	data, err := c.db.Get([]byte(key), nil)
 	if err != nil {
 		if !errors.Is(err, leveldb.ErrNotFound) {
-			httpcache.GetLogger().Warn("failed to read from leveldb cache", slog.Any("error", err))
+			httpcache.GetLogger().Warn("failed to read from leveldb cache", slog.String(httpcache.BackendKey, "leveldb"), slog.Any("error", err))
 		}
 		return nil, false
 	}
@@ -43,19 +71,125 @@ func (c *Cache) Get(key string) ([]byte, bool) {
 // Put a value into the cache with the specified key. If an error occurs it is logged.
 func (c *Cache) Put(key string, value []byte) {
 	if err := c.db.Put([]byte(key), value, nil); err != nil {
-		httpcache.GetLogger().Warn("failed to write to leveldb cache", slog.Any("error", err))
+		httpcache.GetLogger().Warn("failed to write to leveldb cache", slog.String(httpcache.BackendKey, "leveldb"), slog.Any("error", err))
 	}
 }
 
 // Del removes a value from the cache for the specified key. If an error occurs it is logged.
 func (c *Cache) Del(key string) {
 	if err := c.db.Delete([]byte(key), nil); err != nil {
-		httpcache.GetLogger().Warn("failed to delete from leveldb cache", slog.Any("error", err))
+		httpcache.GetLogger().Warn("failed to delete from leveldb cache", slog.String(httpcache.BackendKey, "leveldb"), slog.Any("error", err))
+	}
+}
+
+// Keys returns the keys of all entries in the cache. If an error occurs while
+// iterating over the database it is logged and the keys read so far are returned.
+func (c *Cache) Keys() (keys []string) {
+	iter := c.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+
+	if err := iter.Error(); err != nil {
+		httpcache.GetLogger().Warn("failed to list leveldb cache keys", slog.String(httpcache.BackendKey, "leveldb"), slog.Any("error", err))
 	}
+	return keys
 }
 
-// Close closes the underlying leveldb database.
+// Close stops any scheduled compaction and closes the underlying leveldb database.
 // Implements io.Closer.
 func (c *Cache) Close() error {
+	c.mu.Lock()
+	c.stopCompaction()
+	c.closed = true
+	c.mu.Unlock()
 	return c.db.Close()
 }
+
+// Compact the entire underlying database, discarding deleted and overwritten values.
+// HTTP caches with heavy churn accumulate tombstones that are only reclaimed from disk
+// by compaction.
+func (c *Cache) Compact() error {
+	return unavailable(c.db.CompactRange(util.Range{}))
+}
+
+// Size returns the approximate number of bytes the cached data, including keys,
+// occupies on disk. Data that has not yet been flushed from the in-memory journal is
+// not included. An error wrapping httpcache.ErrBackendUnavailable is returned once the
+// cache is closed. Implements httpcache.Sizer.
+func (c *Cache) Size() (int64, error) {
+	iter := c.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	if !iter.Last() {
+		return 0, unavailable(iter.Error())
+	}
+
+	// The range must extend just past the last key to include its table.
+	limit := append(append(make([]byte, 0, len(iter.Key())+1), iter.Key()...), 0)
+	sizes, err := c.db.SizeOf([]util.Range{{Limit: limit}})
+	if err != nil {
+		return 0, unavailable(err)
+	}
+	return sizes.Sum(), nil
+}
+
+// unavailable wraps errors caused by a closed database with
+// httpcache.ErrBackendUnavailable.
+func unavailable(err error) error {
+	if errors.Is(err, leveldb.ErrClosed) {
+		return fmt.Errorf("%w: %w", httpcache.ErrBackendUnavailable, err)
+	}
+	return err
+}
+
+// ScheduleCompaction starts a background routine that compacts the database at the
+// specified interval until the cache is closed. Calling ScheduleCompaction again
+// replaces the previous schedule; an interval of zero stops scheduled compaction. It
+// does nothing once the cache is closed, and the routine stops if the underlying
+// database is closed without closing the cache.
+func (c *Cache) ScheduleCompaction(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopCompaction()
+	if interval <= 0 || c.closed {
+		return
+	}
+
+	c.compaction = &schedule{stop: make(chan struct{}), done: make(chan struct{})}
+	go c.compact(interval, c.compaction)
+}
+
+func (c *Cache) compact(interval time.Duration, s *schedule) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := c.Compact()
+			if errors.Is(err, httpcache.ErrBackendUnavailable) {
+				return
+			}
+
+			if err != nil {
+				httpcache.GetLogger().Warn("failed to compact leveldb cache", slog.String(httpcache.BackendKey, "leveldb"), slog.Any("error", err))
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// stopCompaction must be called while holding the lock.
+func (c *Cache) stopCompaction() {
+	if c.compaction != nil {
+		close(c.compaction.stop)
+		<-c.compaction.done
+		c.compaction = nil
+	}
+}
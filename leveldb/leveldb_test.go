@@ -1,12 +1,17 @@
 package leveldb_test
 
 import (
+	"bytes"
+	"log/slog"
 	"math/rand/v2"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"go.rtnl.ai/httpcache"
 	"go.rtnl.ai/httpcache/leveldb"
 )
 
@@ -28,6 +33,18 @@ func TestLevelDBCache(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestLevelDBKeys(t *testing.T) {
+	cache, err := leveldb.New(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.Empty(t, cache.Keys())
+
+	cache.Put("b", []byte("2"))
+	cache.Put("a", []byte("1"))
+	require.Equal(t, []string{"a", "b"}, cache.Keys())
+}
+
 func TestLevelDBRace(t *testing.T) {
 	// Ensures no race conditions occur during concurrent access.
 	path := filepath.Join(t.TempDir(), "cache.db")
@@ -58,3 +75,74 @@ func TestLevelDBRace(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestLevelDBCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	cache, err := leveldb.New(path)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	size, err := cache.Size()
+	require.NoError(t, err)
+	require.Zero(t, size)
+
+	value := make([]byte, 2048)
+	for i := 0; i < 128; i++ {
+		cache.Put(string(rune('a'+i)), value)
+	}
+
+	require.NoError(t, cache.Compact())
+	size, err = cache.Size()
+	require.NoError(t, err)
+	require.Greater(t, size, int64(0))
+
+	// Scheduled compaction should run in the background and be stopped on close.
+	cache.ScheduleCompaction(time.Millisecond)
+	for i := 0; i < 128; i++ {
+		cache.Del(string(rune('a' + i)))
+	}
+	time.Sleep(10 * time.Millisecond)
+	cache.ScheduleCompaction(5 * time.Millisecond)
+}
+
+func TestLevelDBClosed(t *testing.T) {
+	cache, err := leveldb.New(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	require.NoError(t, cache.Close())
+
+	_, err = cache.Size()
+	require.ErrorIs(t, err, httpcache.ErrBackendUnavailable)
+	require.ErrorIs(t, cache.Compact(), httpcache.ErrBackendUnavailable)
+}
+
+func TestLevelDBCompactionClosed(t *testing.T) {
+	defer httpcache.SetLogger(slog.Default())
+	logs := &bytes.Buffer{}
+	httpcache.SetLogger(slog.New(slog.NewTextHandler(logs, nil)))
+
+	// Compaction is not scheduled once the cache is closed.
+	cache, err := leveldb.New(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	require.NoError(t, cache.Close())
+	cache.ScheduleCompaction(time.Millisecond)
+
+	// Scheduled compaction stops when the underlying database is closed.
+	db, err := goleveldb.OpenFile(filepath.Join(t.TempDir(), "db"), nil)
+	require.NoError(t, err)
+	made := leveldb.Make(db)
+	made.ScheduleCompaction(time.Millisecond)
+	require.NoError(t, db.Close())
+
+	time.Sleep(20 * time.Millisecond)
+	made.ScheduleCompaction(0)
+	require.Empty(t, logs.String())
+}
+
+func TestNewClient(t *testing.T) {
+	client, cache, err := leveldb.NewClient(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.IsType(t, &httpcache.Transport{}, client.Transport)
+	require.Same(t, cache, client.Transport.(*httpcache.Transport).Cache)
+}
@@ -0,0 +1,39 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+)
+
+// Limiter limits the rate of requests to an origin server, e.g. to respect the quota of
+// an API. It is implemented by *rate.Limiter of golang.org/x/time/rate. Wait blocks
+// until a request may be sent and returns an error if the context is done first; it
+// should return an error immediately if the request could not be sent before the
+// deadline of the context.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// limit waits until the limiter of the policy allows a request to the origin. If a
+// stale response may be served instead and the limiter would delay the request for
+// longer than MaxLimiterDelay, limit returns true without waiting further so that the
+// stale response is served.
+func (t *Transport) limit(req *http.Request, policy policy, stale bool) (_ bool, err error) {
+	if policy.limiter == nil {
+		return false, nil
+	}
+
+	if !stale || t.MaxLimiterDelay <= 0 {
+		return false, policy.limiter.Wait(req.Context())
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.MaxLimiterDelay)
+	defer cancel()
+	if err = policy.limiter.Wait(ctx); err != nil {
+		if err = req.Context().Err(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,78 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+// fakeLimiter allows every request after the delay without waiting for it, unless the
+// delay exceeds the deadline of the context like a *rate.Limiter.
+type fakeLimiter struct {
+	delay atomic.Int64
+	waits atomic.Int64
+}
+
+func (l *fakeLimiter) Wait(ctx context.Context) error {
+	delay := time.Duration(l.delay.Load())
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return errors.New("would exceed context deadline")
+	}
+	l.waits.Add(1)
+	return nil
+}
+
+func TestTransportLimiter(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.URL.Path == "/must" {
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	limiter, other := &fakeLimiter{}, &fakeLimiter{}
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithClock(clock),
+		httpcache.WithStatusHeader(httpcache.DefaultStatusHeader),
+		httpcache.WithLimiter(limiter, time.Second),
+		httpcache.WithProfile("localhost", &httpcache.Profile{Limiter: other}),
+	).Client()
+
+	// Misses wait for the limiter but hits do not.
+	Get(t, client, origin.URL+"/stale")
+	Get(t, client, origin.URL+"/must")
+	Get(t, client, origin.URL+"/stale")
+	require.Equal(t, int64(2), limiter.waits.Load())
+
+	// Stale responses are served rather than waiting for longer than the maximum delay.
+	clock.Advance(time.Hour)
+	limiter.delay.Store(int64(time.Minute))
+	rep, body := Get(t, client, origin.URL+"/stale")
+	require.Equal(t, "response 1", body)
+	require.Equal(t, string(httpcache.CacheStale), rep.Header.Get(httpcache.DefaultStatusHeader))
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Responses that must be revalidated wait for the limiter.
+	_, body = Get(t, client, origin.URL+"/must")
+	require.Equal(t, "response 3", body)
+	require.Equal(t, int64(3), limiter.waits.Load())
+
+	// Profiles replace the limiter for their host.
+	Get(t, client, "http://localhost:"+u.Port()+"/other")
+	require.Equal(t, int64(3), limiter.waits.Load())
+	require.Equal(t, int64(1), other.waits.Load())
+}
@@ -11,8 +11,10 @@ var (
 )
 
 // SetLogger sets a custom slog.Logger instance to be used by httpcache. If not set,
-// the default slog logger will be used. Rotational apps should implement a zerolog
-// slogger for observability.
+// the default slog logger will be used. Rotational apps should use a zerolog slogger
+// for observability, e.g. by calling zlog.Install from the zlog subpackage. In high
+// throughput deployments the handler can be wrapped with Sample so that a failing
+// backend does not flood the logs.
 func SetLogger(l *slog.Logger) {
 	logger = l
 }
@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"context"
+	"log/slog"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	heapMetric              = "/memory/classes/heap/objects:bytes"
+	defaultPressureInterval = time.Second
+	defaultPressureFraction = 0.1
+)
+
+// Evictor is implemented by in-memory caches that can proactively evict entries in
+// order to release memory.
+type Evictor interface {
+	// Len returns the number of entries in the cache.
+	Len() int
+
+	// EvictOldest removes up to n of the oldest entries and returns the number removed.
+	EvictOldest(n int) int
+}
+
+// MemoryWatcher periodically samples the heap usage of the process and evicts the
+// oldest entries from an in-memory cache while the heap exceeds a threshold. This
+// protects co-tenant workloads from unbounded growth of the cache.
+type MemoryWatcher struct {
+	// Cache is the in-memory cache to evict entries from.
+	Cache Evictor
+
+	// Threshold is the number of live heap bytes above which entries are evicted.
+	Threshold uint64
+
+	// Interval is how often the heap is sampled (default 1s).
+	Interval time.Duration
+
+	// Fraction of the cache's entries to evict each interval while the heap is above
+	// the threshold (default 0.1).
+	Fraction float64
+}
+
+// Run blocks, watching memory usage until the context is canceled.
+func (w *MemoryWatcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultPressureInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sample := []metrics.Sample{{Name: heapMetric}}
+	for {
+		select {
+		case <-ticker.C:
+			metrics.Read(sample)
+			if sample[0].Value.Kind() != metrics.KindUint64 {
+				GetLogger().Warn("heap metric is not supported, memory watcher stopped", slog.String("metric", heapMetric))
+				return
+			}
+			w.check(sample[0].Value.Uint64())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check evicts entries if the heap usage exceeds the threshold.
+func (w *MemoryWatcher) check(heap uint64) (evicted int) {
+	if heap <= w.Threshold {
+		return 0
+	}
+
+	fraction := w.Fraction
+	if fraction <= 0 || fraction > 1 {
+		fraction = defaultPressureFraction
+	}
+
+	n := int(float64(w.Cache.Len())*fraction) + 1
+	evicted = w.Cache.EvictOldest(n)
+
+	GetLogger().Debug("evicted cache entries under memory pressure",
+		slog.Uint64("heap", heap),
+		slog.Uint64("threshold", w.Threshold),
+		slog.Int("evicted", evicted),
+	)
+	return evicted
+}
@@ -0,0 +1,36 @@
+package httpcache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestMemoryWatcher(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	for i := 0; i < 100; i++ {
+		cache.Put(string(rune('a'+i)), []byte("value"))
+	}
+
+	watcher := &httpcache.MemoryWatcher{Cache: cache, Threshold: 1 << 20, Fraction: 0.2}
+	require.Equal(t, 0, watcher.Check(1<<10), "no entries should be evicted below the threshold")
+	require.Equal(t, 21, watcher.Check(2<<20))
+	require.Equal(t, 79, cache.Len())
+
+	// The oldest entries should have been evicted first.
+	_, ok := cache.Get("a")
+	require.False(t, ok)
+	_, ok = cache.Get(string(rune('a' + 99)))
+	require.True(t, ok)
+
+	// A threshold of zero bytes should eventually evict everything.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher = &httpcache.MemoryWatcher{Cache: cache, Interval: time.Millisecond, Fraction: 1}
+	go watcher.Run(ctx)
+	require.Eventually(t, func() bool { return cache.Len() == 0 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Internal headers used to record how a response was stored. They are added to the
+// response when it is stored and removed before it is served.
+const (
+	headerKey          = "X-Httpcache-Key"
+	headerRequestTime  = "X-Httpcache-Request-Time"
+	headerResponseTime = "X-Httpcache-Response-Time"
+	headerPurged       = "X-Httpcache-Purged"
+	headerURL          = "X-Httpcache-Url"
+	headerMethod       = "X-Httpcache-Method"
+	headerVary         = "X-Httpcache-Vary"
+)
+
+// Metadata describes a stored response without its body. It allows applications and
+// backends to inspect cache entries (e.g. when they are evicted) without having to
+// parse the serialized response themselves.
+type Metadata struct {
+	// Key is the cache key the response was stored with, if it was recorded.
+	Key string
+
+	// URL is the full URL of the request that most recently stored the response, which
+	// differs from the URL in the key if query parameters are ignored.
+	URL string
+
+	// StatusCode and Header of the stored response excluding internal headers.
+	StatusCode int
+	Header     http.Header
+
+	// Size is the number of bytes of the serialized entry.
+	Size int
+
+	// Stored is the time that the response was received from the origin.
+	Stored time.Time
+
+	// Lifetime is the freshness lifetime of the response and Heuristic is true if it
+	// was computed heuristically rather than from explicit expiration information.
+	Lifetime  time.Duration
+	Heuristic bool
+
+	// NoCache is true if the response must be revalidated before it is served.
+	NoCache bool
+
+	// Age is the age of the response at the time the metadata was parsed.
+	Age time.Duration
+}
+
+// ParseMetadata parses the metadata of a serialized entry as stored by the Transport.
+// Its freshness is evaluated as by a private cache without the profiles, rules or
+// negative caching of a Transport. If the entry cannot be parsed an error wrapping
+// ErrEntryCorrupt is returned.
+func ParseMetadata(val []byte) (_ *Metadata, err error) {
+	return parseMetadata(val, time.Now())
+}
+
+// parseMetadata parses the metadata of a serialized entry with its age at now.
+func parseMetadata(val []byte, now time.Time) (*Metadata, error) {
+	return readMetadata(val, func(rep *http.Response) freshness {
+		return evaluateFreshness(rep, false, now)
+	})
+}
+
+// entryMetadata parses the metadata of an entry of the cache with its age at now. Its
+// freshness is evaluated with the policy that lookups apply to the request that the
+// response was stored for, including the profiles, rules and negative caching of the
+// Transport.
+func (t *Transport) entryMetadata(val []byte, now time.Time) (*Metadata, error) {
+	return readMetadata(val, func(rep *http.Response) freshness {
+		return t.entryPolicy(rep.Header).freshness(rep, now)
+	})
+}
+
+// entryPolicy returns the policy of the request that the response with the header was
+// stored for. Entries stored before the URL was recorded use the default policy.
+func (t *Transport) entryPolicy(header http.Header) policy {
+	u, err := url.Parse(header.Get(headerURL))
+	if err != nil {
+		u = &url.URL{}
+	}
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}
+	p := t.policy(req)
+
+	// Responses to requests partitioned by credential are evaluated as a private cache.
+	if strings.Contains(header.Get(headerKey), "|credential:") {
+		p.shared = false
+	}
+	return p
+}
+
+// readMetadata parses the metadata of a serialized entry, evaluating its freshness with
+// the function before the internal headers are removed.
+func readMetadata(val []byte, evaluate func(*http.Response) freshness) (_ *Metadata, err error) {
+	var rep *http.Response
+	if rep, err = http.ReadResponse(bufio.NewReader(bytes.NewReader(val)), nil); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+	rep.Body.Close()
+
+	freshness := evaluate(rep)
+	_, stored := storedTimes(rep.Header)
+
+	meta := &Metadata{
+		Key:        rep.Header.Get(headerKey),
+		URL:        rep.Header.Get(headerURL),
+		StatusCode: rep.StatusCode,
+		Header:     rep.Header,
+		Size:       len(val),
+		Stored:     stored,
+		Lifetime:   freshness.lifetime,
+		Heuristic:  freshness.heuristic,
+		NoCache:    freshness.noCache,
+		Age:        freshness.age,
+	}
+
+	removeInternalHeaders(meta.Header)
+	return meta, nil
+}
+
+// Fresh returns true if the response was fresh when the metadata was parsed.
+func (m *Metadata) Fresh() bool {
+	return !m.NoCache && m.Age < m.Lifetime
+}
+
+// removeInternalHeaders removes the internal headers before a response is served.
+func removeInternalHeaders(header http.Header) {
+	header.Del(headerKey)
+	header.Del(headerRequestTime)
+	header.Del(headerResponseTime)
+	header.Del(headerPurged)
+	header.Del(headerURL)
+	header.Del(headerMethod)
+	header.Del(headerVary)
+	header.Del(headerRanges)
+}
@@ -0,0 +1,41 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestParseMetadata(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+	Get(t, client, origin.URL+"/resource")
+
+	val, ok := cache.Get(origin.URL + "/resource")
+	require.True(t, ok)
+
+	meta, err := httpcache.ParseMetadata(val)
+	require.NoError(t, err)
+	require.Equal(t, origin.URL+"/resource", meta.Key)
+	require.Equal(t, http.StatusOK, meta.StatusCode)
+	require.Equal(t, "text/plain", meta.Header.Get("Content-Type"))
+	require.Empty(t, meta.Header.Get(httpcache.HeaderResponseTime))
+	require.Equal(t, len(val), meta.Size)
+	require.WithinDuration(t, time.Now(), meta.Stored, time.Second)
+	require.Equal(t, time.Hour, meta.Lifetime)
+	require.False(t, meta.Heuristic)
+	require.True(t, meta.Fresh())
+
+	_, err = httpcache.ParseMetadata([]byte("garbage"))
+	require.ErrorIs(t, err, httpcache.ErrEntryCorrupt)
+}
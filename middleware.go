@@ -0,0 +1,33 @@
+package httpcache
+
+import "net/http"
+
+// Middleware wraps a RoundTripper with additional behavior, e.g. retries, authentication,
+// or tracing, and returns the wrapped RoundTripper.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain wraps the RoundTripper with the middleware in order so that the first
+// middleware is the outermost, i.e. it sees the request first and the response last.
+// If the RoundTripper is nil, http.DefaultTransport is used.
+func Chain(rt http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		rt = middleware[i](rt)
+	}
+	return rt
+}
+
+// EnsureCachingOutermost installs the middleware between the caching Transport and the
+// RoundTripper it uses to make requests to the origin, and returns the Transport. The
+// cache is the outermost layer so that cache hits are served without running the
+// middleware (e.g. without retries or fetching tokens) and so that per-request headers
+// added by the middleware, such as Authorization or request signatures, are never seen
+// by the cache, keyed, or stored. Middleware that adds credentials should therefore
+// only be used with a private cache or a Transport that partitions by credential.
+func EnsureCachingOutermost(t *Transport, middleware ...Middleware) *Transport {
+	t.Transport = Chain(t.Transport, middleware...)
+	return t
+}
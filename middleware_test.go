@@ -0,0 +1,72 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestChain(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+
+	record := func(name string) httpcache.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	client := &http.Client{Transport: httpcache.Chain(nil, record("tracing"), record("retry"), record("auth"))}
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, []string{"tracing", "retry", "auth"}, calls)
+}
+
+func TestEnsureCachingOutermost(t *testing.T) {
+	var tokens int
+	auth := func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			tokens++
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer token-%d", tokens))
+			return next.RoundTrip(req)
+		})
+	}
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d for %s", n, r.Header.Get("Authorization"))
+	})
+
+	transport := httpcache.EnsureCachingOutermost(httpcache.NewTransport(&httpcache.InMemoryCache{}), auth)
+	client := transport.Client()
+
+	// Cache hits are served without running the middleware.
+	for i := 0; i < 3; i++ {
+		_, body := Get(t, client, origin.URL)
+		require.Equal(t, "response 1 for Bearer token-1", body)
+	}
+	require.Equal(t, 1, tokens)
+	require.Equal(t, int64(1), origin.Requests())
+}
@@ -0,0 +1,242 @@
+//go:build linux || darwin
+
+/*
+Package mmap provides an experimental implementation of httpcache.Cache that stores
+entries in a memory-mapped file so that processes on the same host, e.g. an application
+and its sidecar proxy, can share hot entries without a network hop.
+
+The file is divided into a fixed number of fixed-size slots and each key is assigned a
+slot by its hash (see httpcache.HashKey), so the cache behaves like a ring that
+overwrites older entries when keys collide. Entries that do not fit into a slot are not
+stored. Access from multiple processes is coordinated with an advisory lock on the file.
+
+Example Usage:
+
+	cache, err := mmap.New("/dev/shm/httpcache", 4096, 64<<10)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	client := httpcache.NewTransport(cache).Client()
+*/
+package mmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+
+	"go.rtnl.ai/httpcache"
+)
+
+const (
+	magic      = "HTTPCMM2"
+	headerSize = len(magic) + 8
+	slotHeader = 6 // key length (uint16) and value length (uint32)
+	maxKeySize = 1<<16 - 1
+)
+
+// Cache is an implementation of httpcache.Cache backed by a memory-mapped file.
+type Cache struct {
+	mu       sync.Mutex
+	file     *os.File
+	data     []byte
+	slots    int
+	slotSize int
+}
+
+var _ httpcache.Cache = (*Cache)(nil)
+var _ io.Closer = (*Cache)(nil)
+var _ httpcache.Sizer = (*Cache)(nil)
+
+// New opens or creates the memory-mapped file at the path with the specified number of
+// slots of slotSize bytes each. Every process sharing the file must use the same
+// geometry; if an existing file was created with a different geometry, an error is
+// returned.
+func New(path string, slots, slotSize int) (_ *Cache, err error) {
+	if slots <= 0 || slotSize <= slotHeader {
+		return nil, errors.New("mmap cache requires a positive number of slots and slot size")
+	}
+
+	cache := &Cache{slots: slots, slotSize: slotSize}
+	if cache.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600); err != nil {
+		return nil, err
+	}
+
+	if err = cache.init(); err != nil {
+		cache.file.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// init sizes and maps the file, writing the header if the file is new.
+func (c *Cache) init() (err error) {
+	if err = syscall.Flock(int(c.file.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(c.file.Fd()), syscall.LOCK_UN)
+
+	size := headerSize + c.slots*c.slotSize
+
+	var info os.FileInfo
+	if info, err = c.file.Stat(); err != nil {
+		return err
+	}
+
+	created := info.Size() == 0
+	if created {
+		if err = c.file.Truncate(int64(size)); err != nil {
+			return err
+		}
+	}
+
+	if c.data, err = syscall.Mmap(int(c.file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED); err != nil {
+		return err
+	}
+
+	if created {
+		copy(c.data, magic)
+		binary.LittleEndian.PutUint32(c.data[len(magic):], uint32(c.slots))
+		binary.LittleEndian.PutUint32(c.data[len(magic)+4:], uint32(c.slotSize))
+		return nil
+	}
+
+	if info.Size() != int64(size) || string(c.data[:len(magic)]) != magic ||
+		binary.LittleEndian.Uint32(c.data[len(magic):]) != uint32(c.slots) ||
+		binary.LittleEndian.Uint32(c.data[len(magic)+4:]) != uint32(c.slotSize) {
+		syscall.Munmap(c.data)
+		c.data = nil
+		return fmt.Errorf("mmap cache file %s has a different geometry", c.file.Name())
+	}
+	return nil
+}
+
+// Get returns a copy of the value stored for the key and true if present. Access is
+// serialized within the process since the file lock is shared by all goroutines.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lock(syscall.LOCK_SH) {
+		return nil, false
+	}
+	defer c.unlock()
+
+	slot := c.slot(key)
+	stored, val := entry(slot)
+	if stored != key {
+		return nil, false
+	}
+	return bytes.Clone(val), true
+}
+
+// Put stores the value for the key, replacing any entry that was assigned the same
+// slot. Entries that are too large for a slot are not stored.
+func (c *Cache) Put(key string, val []byte) {
+	if len(key) > maxKeySize || slotHeader+len(key)+len(val) > c.slotSize {
+		httpcache.GetLogger().Debug("entry too large for mmap cache slot", slog.String(httpcache.BackendKey, "mmap"), slog.String("key", key), slog.Int("size", len(val)))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lock(syscall.LOCK_EX) {
+		return
+	}
+	defer c.unlock()
+
+	slot := c.slot(key)
+	binary.LittleEndian.PutUint16(slot, uint16(len(key)))
+	binary.LittleEndian.PutUint32(slot[2:], uint32(len(val)))
+	copy(slot[slotHeader:], key)
+	copy(slot[slotHeader+len(key):], val)
+}
+
+// Del removes the entry for the key if it is stored.
+func (c *Cache) Del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lock(syscall.LOCK_EX) {
+		return
+	}
+	defer c.unlock()
+
+	slot := c.slot(key)
+	if stored, _ := entry(slot); stored == key {
+		clear(slot[:slotHeader])
+	}
+}
+
+// Size returns the size of the mapped file, which is allocated in full when the cache
+// is created regardless of how many slots are used. An error wrapping
+// httpcache.ErrBackendUnavailable is returned once the cache is closed. Implements
+// httpcache.Sizer.
+func (c *Cache) Size() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		return 0, httpcache.ErrBackendUnavailable
+	}
+	return int64(len(c.data)), nil
+}
+
+// Close unmaps and closes the file. The entries remain in the file for other processes.
+func (c *Cache) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.data != nil {
+		err = syscall.Munmap(c.data)
+		c.data = nil
+	}
+
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// slot returns the bytes of the slot assigned to the key.
+func (c *Cache) slot(key string) []byte {
+	offset := headerSize + int(httpcache.HashKey(key)%uint64(c.slots))*c.slotSize
+	return c.data[offset : offset+c.slotSize]
+}
+
+// lock acquires the advisory file lock, returning false if the cache is closed or the
+// lock cannot be acquired.
+func (c *Cache) lock(how int) bool {
+	if c.data == nil {
+		return false
+	}
+
+	if err := syscall.Flock(int(c.file.Fd()), how); err != nil {
+		httpcache.GetLogger().Warn("could not lock mmap cache file", slog.String(httpcache.BackendKey, "mmap"), slog.Any("error", err))
+		return false
+	}
+	return true
+}
+
+func (c *Cache) unlock() {
+	syscall.Flock(int(c.file.Fd()), syscall.LOCK_UN)
+}
+
+// entry returns the key and value stored in the slot; the key is empty if the slot is
+// unused or its lengths are invalid.
+func entry(slot []byte) (string, []byte) {
+	klen := int(binary.LittleEndian.Uint16(slot))
+	vlen := int(binary.LittleEndian.Uint32(slot[2:]))
+	if klen == 0 || slotHeader+klen+vlen > len(slot) {
+		return "", nil
+	}
+	return string(slot[slotHeader : slotHeader+klen]), slot[slotHeader+klen : slotHeader+klen+vlen]
+}
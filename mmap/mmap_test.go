@@ -0,0 +1,98 @@
+//go:build linux || darwin
+
+package mmap_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/mmap"
+)
+
+func TestMmapCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.mmap")
+	cache, err := mmap.New(path, 64, 1024)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	// The file is allocated in full when the cache is created.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	size, err := cache.Size()
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), size)
+
+	cache.Put("foo", []byte("bar"))
+
+	val, ok := cache.Get("foo")
+	require.True(t, ok)
+	require.Equal(t, []byte("bar"), val)
+
+	cache.Del("foo")
+	_, ok = cache.Get("foo")
+	require.False(t, ok)
+
+	// Entries that do not fit into a slot are not stored.
+	cache.Put("large", make([]byte, 1024))
+	_, ok = cache.Get("large")
+	require.False(t, ok)
+
+	// Closed caches report that the backend is unavailable.
+	require.NoError(t, cache.Close())
+	_, err = cache.Size()
+	require.ErrorIs(t, err, httpcache.ErrBackendUnavailable)
+}
+
+func TestMmapCacheShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.mmap")
+
+	app, err := mmap.New(path, 64, 1024)
+	require.NoError(t, err)
+	defer app.Close()
+
+	sidecar, err := mmap.New(path, 64, 1024)
+	require.NoError(t, err)
+
+	app.Put("foo", []byte("bar"))
+	val, ok := sidecar.Get("foo")
+	require.True(t, ok)
+	require.Equal(t, []byte("bar"), val)
+
+	// Entries remain in the file after a process closes the cache.
+	sidecar.Put("baz", []byte("qux"))
+	require.NoError(t, sidecar.Close())
+
+	val, ok = app.Get("baz")
+	require.True(t, ok)
+	require.Equal(t, []byte("qux"), val)
+
+	_, err = mmap.New(path, 32, 1024)
+	require.ErrorContains(t, err, "different geometry")
+}
+
+func TestMmapRace(t *testing.T) {
+	cache, err := mmap.New(filepath.Join(t.TempDir(), "cache.mmap"), 16, 256)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := string(rune('a' + (i+j)%26))
+				cache.Put(key, []byte(key))
+				if val, ok := cache.Get(key); ok {
+					require.Equal(t, []byte(key), val)
+				}
+				cache.Del(key)
+			}
+		}()
+	}
+	wg.Wait()
+}
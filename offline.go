@@ -0,0 +1,65 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// NewOfflineClient returns an http.Client that never contacts the network and serves
+// responses strictly from the cache. Requests that are not in the cache receive a
+// synthetic 504 Gateway Timeout response. This is useful for air-gapped environments
+// and for deterministic test runs against a pre-populated cache. The options must match
+// those of the Transport that populated the cache, e.g. WithVersion, WithKeyHeaders or
+// WithCredentialPartitioning, so that requests are looked up with the same keys.
+func NewOfflineClient(cache Cache, opts ...Option) *http.Client {
+	return NewTransport(cache, opts...).OfflineClient()
+}
+
+// OfflineClient returns an http.Client that never contacts the network and serves the
+// responses stored by the Transport, however stale, using the same keys as RoundTrip.
+// Requests that are not in the cache receive a synthetic 504 Gateway Timeout response
+// and corrupt entries are returned as an error wrapping ErrEntryCorrupt.
+func (t *Transport) OfflineClient() *http.Client {
+	return &http.Client{Transport: &offlineTransport{transport: t}}
+}
+
+type offlineTransport struct {
+	transport *Transport
+}
+
+var _ http.RoundTripper = (*offlineTransport)(nil)
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (_ *http.Response, err error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	var cached *http.Response
+	policy := t.transport.policy(req)
+	if _, cached, err = cachedVariant(policy.cache, policy.key(req), req); err != nil {
+		return nil, err
+	}
+
+	if cached == nil {
+		return gatewayTimeout(req), nil
+	}
+	return serve(cached, policy.freshness(cached, t.transport.now())), nil
+}
+
+// gatewayTimeout synthesizes a 504 response for requests that cannot be satisfied
+// from the cache without contacting the origin server.
+func gatewayTimeout(req *http.Request) *http.Response {
+	body := http.StatusText(http.StatusGatewayTimeout)
+	return &http.Response{
+		Status:        "504 " + body,
+		StatusCode:    http.StatusGatewayTimeout,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,83 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestOfflineClient(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	cache.Put("http://example.com/cached", []byte("HTTP/1.1 200 OK\r\nContent-Length: 6\r\n\r\ncached"))
+	cache.Put("http://example.com/corrupt", []byte("garbage"))
+
+	client := httpcache.NewOfflineClient(cache)
+
+	t.Run("Hit", func(t *testing.T) {
+		rep, err := client.Get("http://example.com/cached")
+		require.NoError(t, err)
+		defer rep.Body.Close()
+
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		body, err := io.ReadAll(rep.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("cached"), body)
+	})
+
+	t.Run("Miss", func(t *testing.T) {
+		rep, err := client.Get("http://example.com/missing")
+		require.NoError(t, err)
+		defer rep.Body.Close()
+
+		require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		_, err := client.Get("http://example.com/corrupt")
+		require.ErrorIs(t, err, httpcache.ErrEntryCorrupt)
+	})
+}
+
+func TestTransportOfflineClient(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "%s %s response %d", r.Header.Get("X-Tenant-ID"), r.Header.Get("Accept-Language"), n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	opts := []httpcache.Option{httpcache.WithVersion("v2"), httpcache.WithKeyHeaders("X-Tenant-ID")}
+	client := httpcache.NewTransport(cache, opts...).Client()
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme", "Accept-Language", "en")
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme", "Accept-Language", "fr")
+	origin.Close()
+
+	offline := httpcache.NewOfflineClient(cache, opts...)
+	testCases := []struct {
+		tenant, language string
+		status           int
+		body             string
+	}{
+		{"acme", "en", http.StatusOK, "acme en response 1"},
+		{"acme", "fr", http.StatusOK, "acme fr response 2"},
+		{"acme", "de", http.StatusGatewayTimeout, "Gateway Timeout"},
+		{"other", "en", http.StatusGatewayTimeout, "Gateway Timeout"},
+	}
+
+	for _, tc := range testCases {
+		rep, body := Get(t, offline, origin.URL, "X-Tenant-ID", tc.tenant, "Accept-Language", tc.language)
+		require.Equal(t, tc.status, rep.StatusCode, "Test Case: %q", tc.body)
+		require.Equal(t, tc.body, body, "Test Case: %q", tc.body)
+		for name := range rep.Header {
+			require.NotContains(t, name, "X-Httpcache", "Test Case: %q", tc.body)
+		}
+	}
+
+	// Without the options of the Transport that populated the cache nothing is found.
+	rep, _ := Get(t, httpcache.NewOfflineClient(cache), origin.URL, "X-Tenant-ID", "acme", "Accept-Language", "en")
+	require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+}
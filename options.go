@@ -0,0 +1,257 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Transport created by NewTransport. Each option sets one or more of
+// the exported fields of the Transport, which remain available for configuration after
+// the Transport is created.
+type Option func(*Transport)
+
+// WithTransport sets the RoundTripper used to make requests to the origin server.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(t *Transport) {
+		t.Transport = rt
+	}
+}
+
+// WithSharedMode makes the Transport behave as a shared cache, e.g. in a proxy.
+func WithSharedMode() Option {
+	return func(t *Transport) {
+		t.Shared = true
+	}
+}
+
+// WithReplayCookies stores Set-Cookie headers in a shared cache; see ReplayCookies.
+func WithReplayCookies() Option {
+	return func(t *Transport) {
+		t.ReplayCookies = true
+	}
+}
+
+// WithVersion scopes the cache keys of stored responses to the version.
+func WithVersion(version string) Option {
+	return func(t *Transport) {
+		t.Version = version
+	}
+}
+
+// WithStatusCodes restricts the status codes of responses that may be stored.
+func WithStatusCodes(codes ...int) Option {
+	return func(t *Transport) {
+		t.StatusCodes = codes
+	}
+}
+
+// WithNegativeCaching caches negative responses without explicit expiration information
+// for the TTL. If no status codes are specified, 404 Not Found and 410 Gone are cached.
+func WithNegativeCaching(ttl time.Duration, codes ...int) Option {
+	return func(t *Transport) {
+		t.NegativeTTL = ttl
+		t.NegativeStatusCodes = codes
+	}
+}
+
+// WithRetryAfter caches 429 and 503 responses for the duration of their Retry-After.
+func WithRetryAfter() Option {
+	return func(t *Transport) {
+		t.RespectRetryAfter = true
+	}
+}
+
+// WithLanguages collapses the Accept-Language of requests to the supported languages.
+func WithLanguages(languages ...string) Option {
+	return func(t *Transport) {
+		t.Languages = languages
+	}
+}
+
+// WithWritePolicy determines which response is kept when concurrent requests race to
+// store a response for the same key.
+func WithWritePolicy(policy WritePolicy) Option {
+	return func(t *Transport) {
+		t.WritePolicy = policy
+	}
+}
+
+// WithDerivations adds derivations that generate derived variants of stored responses.
+func WithDerivations(derivations ...Derivation) Option {
+	return func(t *Transport) {
+		t.Derivations = append(t.Derivations, derivations...)
+	}
+}
+
+// WithCacheSelector sets the function that selects the cache for each request.
+func WithCacheSelector(selector func(req *http.Request) Cache) Option {
+	return func(t *Transport) {
+		t.CacheSelector = selector
+	}
+}
+
+// WithKeyHeaders adds request headers whose values are included in the cache key.
+func WithKeyHeaders(headers ...string) Option {
+	return func(t *Transport) {
+		t.KeyHeaders = append(t.KeyHeaders, headers...)
+	}
+}
+
+// WithTenantHeader reports the stats of each tenant identified by the request header.
+func WithTenantHeader(name string) Option {
+	return func(t *Transport) {
+		t.TenantHeader = name
+	}
+}
+
+// WithUsageTracking records the usage of hosts and entries for Report.
+func WithUsageTracking() Option {
+	return func(t *Transport) {
+		t.TrackUsage = true
+	}
+}
+
+// WithIgnoredQueryParams adds query parameters that are not included in the cache key.
+func WithIgnoredQueryParams(names ...string) Option {
+	return func(t *Transport) {
+		t.IgnoredQueryParams = append(t.IgnoredQueryParams, names...)
+	}
+}
+
+// WithShouldCache sets the predicate that may veto storing responses.
+func WithShouldCache(fn func(req *http.Request, rep *http.Response) bool) Option {
+	return func(t *Transport) {
+		t.ShouldCache = fn
+	}
+}
+
+// WithHooks sets the callbacks invoked as the Transport handles requests.
+func WithHooks(hooks Hooks) Option {
+	return func(t *Transport) {
+		t.Hooks = hooks
+	}
+}
+
+// WithPOSTCaching stores responses to POST requests keyed by a digest of their body.
+func WithPOSTCaching() Option {
+	return func(t *Transport) {
+		t.CachePOST = true
+	}
+}
+
+// WithRules adds rules that override the caching policy of matching requests.
+func WithRules(rules ...Rule) Option {
+	return func(t *Transport) {
+		t.Rules = append(t.Rules, rules...)
+	}
+}
+
+// WithCredentialPartitioning stores responses to authorized requests in a separate
+// partition of the cache for each credential.
+func WithCredentialPartitioning() Option {
+	return func(t *Transport) {
+		t.PartitionByCredential = true
+	}
+}
+
+// WithProfile configures the caching behavior for requests to the host.
+func WithProfile(host string, profile *Profile) Option {
+	return func(t *Transport) {
+		if t.Profiles == nil {
+			t.Profiles = make(map[string]*Profile)
+		}
+		t.Profiles[strings.ToLower(host)] = profile
+	}
+}
+
+// WithBackgroundTasks limits the number of concurrent background tasks and determines
+// how background work is handled when the limit is reached.
+func WithBackgroundTasks(max int, policy BackpressurePolicy) Option {
+	return func(t *Transport) {
+		t.MaxBackgroundTasks = max
+		t.BackgroundPolicy = policy
+	}
+}
+
+// WithPersistentRevalidations records pending background revalidations in the cache and
+// resumes those left by a previous Transport.
+func WithPersistentRevalidations() Option {
+	return func(t *Transport) {
+		t.PersistRevalidations = true
+	}
+}
+
+// WithKeyVerification verifies that stored responses match the requests they serve.
+func WithKeyVerification() Option {
+	return func(t *Transport) {
+		t.VerifyKeys = true
+	}
+}
+
+// WithShadowRate compares the fraction of cache hits with the origin in the background.
+func WithShadowRate(rate float64) Option {
+	return func(t *Transport) {
+		t.ShadowRate = rate
+	}
+}
+
+// WithRequestCollapsing collapses concurrent requests for the same response that is not
+// stored into a single request to the origin.
+func WithRequestCollapsing() Option {
+	return func(t *Transport) {
+		t.CollapseRequests = true
+	}
+}
+
+// WithDeduplication deduplicates identical unsafe requests within the window.
+func WithDeduplication(window time.Duration) Option {
+	return func(t *Transport) {
+		t.DeduplicateWindow = window
+	}
+}
+
+// WithServeStaleOnNetworkError serves stored responses when the origin cannot be reached.
+func WithServeStaleOnNetworkError() Option {
+	return func(t *Transport) {
+		t.ServeStaleOnNetworkError = true
+	}
+}
+
+// WithRetryOnNetworkError retries idempotent requests once when the origin cannot be
+// reached.
+func WithRetryOnNetworkError() Option {
+	return func(t *Transport) {
+		t.RetryOnNetworkError = true
+	}
+}
+
+// WithLimiter limits the rate of requests to the origin and serves stale responses
+// rather than waiting for the limiter for longer than maxDelay, if it is positive.
+func WithLimiter(limiter Limiter, maxDelay time.Duration) Option {
+	return func(t *Transport) {
+		t.Limiter = limiter
+		t.MaxLimiterDelay = maxDelay
+	}
+}
+
+// WithMaxIdle sets the duration after which unused entries are removed by EvictIdle.
+func WithMaxIdle(d time.Duration) Option {
+	return func(t *Transport) {
+		t.MaxIdle = d
+	}
+}
+
+// WithClock sets the clock used for freshness and age calculations.
+func WithClock(clock Clock) Option {
+	return func(t *Transport) {
+		t.Clock = clock
+	}
+}
+
+// WithStatusHeader reports how the cache handled each request in the named header.
+func WithStatusHeader(name string) Option {
+	return func(t *Transport) {
+		t.StatusHeader = name
+	}
+}
@@ -0,0 +1,108 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestNewTransportOptions(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	profile := &httpcache.Profile{TTL: time.Hour}
+	derivation := httpcache.Derivation{Name: "derived"}
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithTransport(http.DefaultTransport),
+		httpcache.WithSharedMode(),
+		httpcache.WithReplayCookies(),
+		httpcache.WithVersion("v2"),
+		httpcache.WithStatusCodes(http.StatusOK),
+		httpcache.WithNegativeCaching(time.Minute, http.StatusNotFound),
+		httpcache.WithRetryAfter(),
+		httpcache.WithLanguages("en", "fr"),
+		httpcache.WithWritePolicy(httpcache.WriteFreshestWins),
+		httpcache.WithDerivations(derivation),
+		httpcache.WithKeyHeaders("X-Tenant-ID"),
+		httpcache.WithTenantHeader("X-Tenant-ID"),
+		httpcache.WithUsageTracking(),
+		httpcache.WithIgnoredQueryParams("v"),
+		httpcache.WithCacheSelector(func(*http.Request) httpcache.Cache { return nil }),
+		httpcache.WithPOSTCaching(),
+		httpcache.WithCredentialPartitioning(),
+		httpcache.WithProfile("API.example.com", profile),
+		httpcache.WithRules(httpcache.Rule{Path: "/auth/*", Bypass: true}),
+		httpcache.WithBackgroundTasks(2, httpcache.BackpressureBlock),
+		httpcache.WithPersistentRevalidations(),
+		httpcache.WithKeyVerification(),
+		httpcache.WithShadowRate(0.5),
+		httpcache.WithRequestCollapsing(),
+		httpcache.WithDeduplication(time.Second),
+		httpcache.WithServeStaleOnNetworkError(),
+		httpcache.WithRetryOnNetworkError(),
+		httpcache.WithLimiter(nil, time.Second),
+		httpcache.WithMaxIdle(time.Hour),
+		httpcache.WithClock(clock),
+		httpcache.WithStatusHeader(httpcache.DefaultStatusHeader),
+		httpcache.WithShouldCache(func(*http.Request, *http.Response) bool { return true }),
+		httpcache.WithHooks(httpcache.Hooks{OnMiss: func(*http.Request) {}}),
+	)
+
+	require.Equal(t, http.DefaultTransport, transport.Transport)
+	require.True(t, transport.Shared)
+	require.True(t, transport.ReplayCookies)
+	require.Equal(t, "v2", transport.Version)
+	require.Equal(t, []int{http.StatusOK}, transport.StatusCodes)
+	require.Equal(t, time.Minute, transport.NegativeTTL)
+	require.Equal(t, []int{http.StatusNotFound}, transport.NegativeStatusCodes)
+	require.True(t, transport.RespectRetryAfter)
+	require.Equal(t, []string{"en", "fr"}, transport.Languages)
+	require.Equal(t, httpcache.WriteFreshestWins, transport.WritePolicy)
+	require.Len(t, transport.Derivations, 1)
+	require.Equal(t, []string{"X-Tenant-ID"}, transport.KeyHeaders)
+	require.NotNil(t, transport.CacheSelector)
+	require.Equal(t, "X-Tenant-ID", transport.TenantHeader)
+	require.True(t, transport.TrackUsage)
+	require.Equal(t, []string{"v"}, transport.IgnoredQueryParams)
+	require.True(t, transport.CachePOST)
+	require.True(t, transport.PartitionByCredential)
+	require.Equal(t, map[string]*httpcache.Profile{"api.example.com": profile}, transport.Profiles)
+	require.Equal(t, []httpcache.Rule{{Path: "/auth/*", Bypass: true}}, transport.Rules)
+	require.Equal(t, 2, transport.MaxBackgroundTasks)
+	require.Equal(t, httpcache.BackpressureBlock, transport.BackgroundPolicy)
+	require.True(t, transport.PersistRevalidations)
+	require.True(t, transport.VerifyKeys)
+	require.Equal(t, 0.5, transport.ShadowRate)
+	require.True(t, transport.CollapseRequests)
+	require.Equal(t, time.Second, transport.DeduplicateWindow)
+	require.True(t, transport.ServeStaleOnNetworkError)
+	require.True(t, transport.RetryOnNetworkError)
+	require.Equal(t, time.Second, transport.MaxLimiterDelay)
+	require.Equal(t, time.Hour, transport.MaxIdle)
+	require.Equal(t, clock, transport.Clock)
+	require.Equal(t, "X-Cache-Status", transport.StatusHeader)
+	require.NotNil(t, transport.ShouldCache)
+	require.NotNil(t, transport.Hooks.OnMiss)
+}
+
+func TestNewTransportWithTransport(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var forwarded int
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			forwarded++
+			return http.DefaultTransport.RoundTrip(req)
+		})),
+		httpcache.WithProfile("127.0.0.1", &httpcache.Profile{TTL: time.Hour}),
+	).Client()
+
+	Get(t, client, origin.URL)
+	Get(t, client, origin.URL)
+	require.Equal(t, 1, forwarded, "expected the configured transport and profile to be used")
+}
@@ -0,0 +1,192 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxCollectionPages bounds the number of pages fetched for a collection so that a
+// misbehaving origin cannot cause an unbounded crawl.
+const maxCollectionPages = 1000
+
+// collectionKey returns the key of the entry that records the pages of a collection.
+func collectionKey(prefix, first string) string {
+	return prefix + "collection:" + first
+}
+
+// NextPage returns the URL of the next page of a paginated response, resolved against
+// the URL of the request, or an empty string if it is the last page. The next page is
+// found in a Link header with rel="next" (RFC 8288), the links.next member of a JSON:API
+// document, or the @odata.nextLink (or OData v3 odata.nextLink) member of an OData
+// collection.
+func NextPage(rep *http.Response, body []byte) string {
+	next := nextLink(rep.Header)
+	if next == "" {
+		next = nextMember(body)
+	}
+
+	if next == "" || rep.Request == nil || rep.Request.URL == nil {
+		return next
+	}
+
+	ref, err := url.Parse(next)
+	if err != nil {
+		return ""
+	}
+	return rep.Request.URL.ResolveReference(ref).String()
+}
+
+// nextLink returns the target of the Link header field with the relation type next.
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return strings.Trim(target, "<>")
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// nextMember returns the next link of a JSON:API document or OData collection.
+func nextMember(body []byte) string {
+	var doc struct {
+		Links struct {
+			Next json.RawMessage `json:"next"`
+		} `json:"links"`
+		OData   string `json:"@odata.nextLink"`
+		ODataV3 string `json:"odata.nextLink"`
+	}
+
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ""
+	}
+
+	switch {
+	case doc.OData != "":
+		return doc.OData
+	case doc.ODataV3 != "":
+		return doc.ODataV3
+	}
+
+	// JSON:API links are either a string or a link object with an href member.
+	var next string
+	if err := json.Unmarshal(doc.Links.Next, &next); err == nil {
+		return next
+	}
+
+	var link struct {
+		Href string `json:"href"`
+	}
+	if err := json.Unmarshal(doc.Links.Next, &link); err == nil {
+		return link.Href
+	}
+	return ""
+}
+
+// FetchCollection requests the first page of a paginated collection and follows the
+// next page links (see NextPage) until the last page, returning the body of every page.
+// The pages are requested through the Transport so they are served from or stored in
+// the cache, and the URLs of the pages are recorded so that the collection can be
+// invalidated as a group. Subsequent pages are requested with the headers of the
+// request for the first page. If no cache is configured for the request, the pages are
+// fetched from the origin and the collection is not recorded.
+func (t *Transport) FetchCollection(req *http.Request) (pages [][]byte, err error) {
+	var (
+		urls    []string
+		visited = make(map[string]struct{})
+		client  = t.Client()
+	)
+
+	for next := req.URL.String(); next != "" && len(urls) < maxCollectionPages; {
+		if _, ok := visited[next]; ok {
+			break
+		}
+		visited[next] = struct{}{}
+
+		page := req.Clone(req.Context())
+		if page.URL, err = url.Parse(next); err != nil {
+			return nil, err
+		}
+		page.Host = page.URL.Host
+
+		var rep *http.Response
+		if rep, err = client.Do(page); err != nil {
+			return nil, err
+		}
+
+		var body []byte
+		body, err = io.ReadAll(rep.Body)
+		rep.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if rep.StatusCode < 200 || rep.StatusCode > 299 {
+			return nil, fmt.Errorf("could not fetch page %s: %s", next, rep.Status)
+		}
+
+		pages = append(pages, body)
+		urls = append(urls, next)
+		next = NextPage(rep, body)
+	}
+
+	if cache := t.selectCache(req); cache != nil {
+		cache.Put(collectionKey(versionPrefix(t.Version), req.URL.String()), []byte(strings.Join(urls, "\n")))
+	}
+	return pages, nil
+}
+
+// InvalidateCollection removes the stored pages of the collection whose first page is
+// requested by the request, as recorded by FetchCollection, and returns the number of
+// pages that were removed.
+func (t *Transport) InvalidateCollection(req *http.Request) int {
+	cache := t.selectCache(req)
+	if cache == nil {
+		return 0
+	}
+
+	key := collectionKey(versionPrefix(t.Version), req.URL.String())
+	val, ok := cache.Get(key)
+	if !ok {
+		return 0
+	}
+
+	var n int
+	for _, page := range strings.Split(string(val), "\n") {
+		target, err := url.Parse(page)
+		if err != nil {
+			continue
+		}
+		t.invalidateURL(req, target)
+		n++
+	}
+
+	t.remove(cache, key)
+	return n
+}
+
+// RefreshCollection invalidates the stored pages of the collection and fetches them
+// again from the origin, e.g. after an item was added that shifts the pagination.
+func (t *Transport) RefreshCollection(req *http.Request) ([][]byte, error) {
+	t.InvalidateCollection(req)
+	return t.FetchCollection(req)
+}
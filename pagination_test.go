@@ -0,0 +1,87 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestNextPage(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/items?page=1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		next   string
+	}{
+		{"Link", http.Header{"Link": {`<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=9>; rel="last"`}}, "", "https://api.example.com/items?page=2"},
+		{"Link Relative", http.Header{"Link": {`</items?page=2>; rel="prev next"`}}, "", "https://api.example.com/items?page=2"},
+		{"Link Last", http.Header{"Link": {`<https://api.example.com/items?page=1>; rel="prev"`}}, "", ""},
+		{"JSON:API", nil, `{"data":[],"links":{"next":"/items?page[number]=2"}}`, "https://api.example.com/items?page[number]=2"},
+		{"JSON:API Link Object", nil, `{"data":[],"links":{"next":{"href":"https://api.example.com/items?page=2"}}}`, "https://api.example.com/items?page=2"},
+		{"JSON:API Last", nil, `{"data":[],"links":{"next":null}}`, ""},
+		{"OData", nil, `{"value":[],"@odata.nextLink":"https://api.example.com/items?$skiptoken=2"}`, "https://api.example.com/items?$skiptoken=2"},
+		{"OData v3", nil, `{"value":[],"odata.nextLink":"items?$skip=20"}`, "https://api.example.com/items?$skip=20"},
+		{"Not JSON", nil, "page 1", ""},
+	}
+
+	for _, test := range tests {
+		rep := &http.Response{Header: test.header, Request: &http.Request{URL: base}}
+		require.Equal(t, test.next, httpcache.NextPage(rep, []byte(test.body)), "Test Case: %q", test.name)
+	}
+}
+
+func TestTransportCollection(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		fmt.Fprintf(w, `{"page":%d,"request":%d}`, page, n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	req, err := http.NewRequest(http.MethodGet, origin.URL+"/items?page=1", nil)
+	require.NoError(t, err)
+
+	pages, err := transport.FetchCollection(req)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(`{"page":1,"request":1}`), []byte(`{"page":2,"request":2}`), []byte(`{"page":3,"request":3}`)}, pages)
+
+	// The pages are served from the cache.
+	_, err = transport.FetchCollection(req)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), origin.Requests())
+
+	// Refreshing the collection fetches every page from the origin.
+	pages, err = transport.RefreshCollection(req)
+	require.NoError(t, err)
+	require.Len(t, pages, 3)
+	require.Equal(t, `{"page":3,"request":6}`, string(pages[2]))
+
+	require.Equal(t, 3, transport.InvalidateCollection(req))
+	require.Zero(t, transport.InvalidateCollection(req))
+
+	_, body := Get(t, transport.Client(), origin.URL+"/items?page=2")
+	require.Equal(t, `{"page":2,"request":7}`, body)
+
+	// Without a cache the pages are fetched from the origin and are not recorded.
+	uncached := httpcache.NewTransport(nil)
+	pages, err = uncached.FetchCollection(req)
+	require.NoError(t, err)
+	require.Len(t, pages, 3)
+	require.Equal(t, int64(10), origin.Requests())
+	require.Zero(t, uncached.InvalidateCollection(req))
+
+	pages, err = uncached.RefreshCollection(req)
+	require.NoError(t, err)
+	require.Len(t, pages, 3)
+}
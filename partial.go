@@ -0,0 +1,275 @@
+package httpcache
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerRanges records the byte ranges of the representation that are stored in a
+// partial entry and the complete length of the representation, e.g.
+// "bytes 0-99,200-299/1000".
+const headerRanges = "X-Httpcache-Ranges"
+
+// partialKey returns the key that the partial responses for the primary key are
+// combined and stored at until the complete response can be stored.
+func partialKey(primary string) string {
+	return primary + "|partial"
+}
+
+// segment is a contiguous range of bytes of a representation stored in a partial entry.
+type segment struct {
+	start int64
+	data  []byte
+}
+
+func (s segment) end() int64 {
+	return s.start + int64(len(s.data)) - 1
+}
+
+// partial is a representation of which only some ranges are stored. The body of the
+// stored response is the concatenation of the segments in order.
+type partial struct {
+	rep      *http.Response
+	size     int64
+	segments []segment
+}
+
+// complete returns true if the segments cover the entire representation.
+func (p *partial) complete() bool {
+	return len(p.segments) == 1 && p.segments[0].start == 0 && int64(len(p.segments[0].data)) == p.size
+}
+
+// covering returns the segment that contains the byte range, if any.
+func (p *partial) covering(r byteRange) (segment, bool) {
+	for _, s := range p.segments {
+		if s.start <= r.start && r.end <= s.end() {
+			return s, true
+		}
+	}
+	return segment{}, false
+}
+
+// merge adds the segment to the partial representation, combining it with the
+// segments that it overlaps or is adjacent to.
+func (p *partial) merge(add segment) {
+	segments := append(slices.Clone(p.segments), add)
+	slices.SortStableFunc(segments, func(a, b segment) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	merged := segments[:1]
+	for _, s := range segments[1:] {
+		last := &merged[len(merged)-1]
+		if s.start > last.end()+1 {
+			merged = append(merged, s)
+			continue
+		}
+
+		if s.end() > last.end() {
+			last.data = append(slices.Clone(last.data[:s.start-last.start]), s.data...)
+		}
+	}
+	p.segments = merged
+}
+
+// ranges formats the stored ranges and complete length as the value of headerRanges.
+func (p *partial) ranges() string {
+	ranges := make([]string, 0, len(p.segments))
+	for _, s := range p.segments {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", s.start, s.end()))
+	}
+	return fmt.Sprintf("bytes %s/%d", strings.Join(ranges, ","), p.size)
+}
+
+// body returns the concatenated bytes of the segments.
+func (p *partial) body() []byte {
+	var buf bytes.Buffer
+	for _, s := range p.segments {
+		buf.Write(s.data)
+	}
+	return buf.Bytes()
+}
+
+// parsePartial parses a stored partial entry, splitting its body into segments.
+func parsePartial(rep *http.Response) (_ *partial, err error) {
+	defer rep.Body.Close()
+
+	var body []byte
+	if body, err = io.ReadAll(rep.Body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+
+	spec, ok := strings.CutPrefix(rep.Header.Get(headerRanges), "bytes ")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing stored ranges", ErrEntryCorrupt)
+	}
+
+	ranges, size, _ := strings.Cut(spec, "/")
+	p := &partial{rep: rep}
+	if p.size, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+
+	var offset int64
+	for _, spec := range strings.Split(ranges, ",") {
+		var r byteRange
+		if _, err = fmt.Sscanf(spec, "%d-%d", &r.start, &r.end); err != nil || r.end < r.start || offset+r.length() > int64(len(body)) {
+			return nil, fmt.Errorf("%w: invalid stored range %q", ErrEntryCorrupt, spec)
+		}
+		p.segments = append(p.segments, segment{start: r.start, data: body[offset : offset+r.length()]})
+		offset += r.length()
+	}
+	return p, nil
+}
+
+// parseContentRange parses the Content-Range header of a 206 response containing a
+// single range of a representation whose complete length is known.
+func parseContentRange(header string) (r byteRange, size int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return r, 0, false
+	}
+
+	if _, err := fmt.Sscanf(spec, "%d-%d/%d", &r.start, &r.end, &size); err != nil {
+		return r, 0, false
+	}
+	return r, size, r.start >= 0 && r.start <= r.end && r.end < size
+}
+
+// strongValidator returns the validator that partial responses must share to be
+// combined (RFC 9111 §3.4): a strong entity tag or, without one, the modification date.
+func strongValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" {
+		if strings.HasPrefix(etag, "W/") {
+			return ""
+		}
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// storablePartial returns true if the 206 response to the request may be combined with
+// other partial responses in the cache. The response must contain a single range of a
+// representation with a strong validator that does not vary, the complete
+// representation must fit into the maximum body size, and it must be storable if it
+// were a complete response.
+func storablePartial(req *http.Request, rep *http.Response, policy policy) bool {
+	if rep.StatusCode != http.StatusPartialContent || strongValidator(rep.Header) == "" || len(varyHeaders(rep.Header)) > 0 || varyAll(rep.Header) {
+		return false
+	}
+
+	if _, size, ok := parseContentRange(rep.Header.Get("Content-Range")); !ok || !policy.fits(size) {
+		return false
+	}
+
+	complete := *rep
+	complete.StatusCode = http.StatusOK
+	return storableResponse(req, &complete, policy)
+}
+
+// lookupPartial returns the partial entry stored for the primary key, if any, and its
+// current freshness. Corrupt entries are removed from the cache and treated as a miss.
+func (t *Transport) lookupPartial(primary string, req *http.Request, policy policy) (*partial, freshness) {
+	key, cached, freshness := t.lookup(partialKey(primary), req, policy)
+	if cached == nil {
+		return nil, freshness
+	}
+
+	p, err := parsePartial(cached)
+	if err != nil {
+		t.failOpen.add(FailOpenCorruptEntry)
+		t.remove(policy.cache, key)
+		return nil, freshness
+	}
+	return p, freshness
+}
+
+// servePartial creates a 206 Partial Content response for the Range header of the
+// request if the range is stored in the partial entry. If the range is not stored or
+// the Range header is not supported, ok is false.
+func servePartial(p *partial, freshness freshness, header string) (_ *http.Response, ok bool) {
+	r, satisfiable, ok := parseRange(header, p.size)
+	if !ok || !satisfiable {
+		return nil, false
+	}
+
+	s, ok := p.covering(r)
+	if !ok {
+		return nil, false
+	}
+
+	rep := serve(p.rep, freshness)
+	rep.Header.Set("Content-Range", r.contentRange(p.size))
+	rep.Header.Set("Content-Length", strconv.FormatInt(r.length(), 10))
+	rep.Body = io.NopCloser(bytes.NewReader(s.data[r.start-s.start : r.end-s.start+1]))
+	rep.ContentLength = r.length()
+	return rep, true
+}
+
+// storePartialOnRead arranges for the 206 response to be combined with the stored
+// partial entry once its body has been completely read by the caller.
+func (t *Transport) storePartialOnRead(primary string, req *http.Request, rep *http.Response, policy policy, requestTime, responseTime time.Time) {
+	if !policy.fits(rep.ContentLength) {
+		t.failOpen.add(FailOpenTooLarge)
+		t.Hooks.failed(req, policy.tooLarge())
+		return
+	}
+
+	snapshot := *rep
+	snapshot.Header = rep.Header.Clone()
+	body := newCachingReadCloser(rep.Body, policy.maxBodySize, &t.buffered, func(body []byte) {
+		t.storePartial(primary, req, &snapshot, body, policy, requestTime, responseTime)
+	})
+	body.exceeded = func() {
+		t.failOpen.add(FailOpenTooLarge)
+		t.Hooks.failed(req, policy.tooLarge())
+	}
+	rep.Body = body
+}
+
+// storePartial combines the body of the 206 response with the ranges stored in the
+// partial entry for the primary key if both have the same validator, otherwise the
+// response replaces the partial entry. Once all of the ranges of the representation
+// are stored, it is promoted to a complete 200 response and the partial entry removed.
+func (t *Transport) storePartial(primary string, req *http.Request, rep *http.Response, body []byte, policy policy, requestTime, responseTime time.Time) {
+	r, size, _ := parseContentRange(rep.Header.Get("Content-Range"))
+	if r.length() != int64(len(body)) {
+		return
+	}
+
+	// Ranges are merged atomically with respect to other partial responses.
+	t.partials.Lock()
+	p, _ := t.lookupPartial(primary, req, policy)
+	if p == nil || p.size != size || strongValidator(p.rep.Header) != strongValidator(rep.Header) {
+		p = &partial{size: size}
+	}
+	p.rep = rep
+	p.merge(segment{start: r.start, data: body})
+
+	if !p.complete() {
+		rep.Header.Del("Content-Range")
+		rep.Header.Set(headerRanges, p.ranges())
+		t.sanitize(rep.Header, policy)
+		setStoredTimes(rep.Header, requestTime, responseTime)
+		rep.Header.Set(headerKey, partialKey(primary))
+		t.put(policy.cache, partialKey(primary), rep, p.body())
+		t.partials.Unlock()
+		return
+	}
+
+	t.remove(policy.cache, partialKey(primary))
+	t.partials.Unlock()
+
+	rep.StatusCode = http.StatusOK
+	rep.Status = "200 OK"
+	rep.Header.Del("Content-Range")
+	rep.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	t.store(primary, req, rep, p.body(), policy, requestTime, responseTime)
+}
@@ -0,0 +1,88 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+)
+
+// isQuery returns true for POST requests whose responses are cached by the digest of
+// their body because CachePOST is enabled.
+func (t *Transport) isQuery(req *http.Request) bool {
+	return t.CachePOST && req.Method == http.MethodPost && req.Header.Get("Range") == ""
+}
+
+// bodyKey returns the cache key of a POST request, which includes a digest of its body
+// so that only requests with identical bodies, e.g. the same GraphQL query and
+// variables, share a stored response.
+func bodyKey(primary string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return primary + "|body:" + hex.EncodeToString(sum[:])
+}
+
+// roundTripQuery serves a POST request that only queries the origin, e.g. a GraphQL
+// query or a search, from the cache or the origin. Responses are stored like responses
+// to GET requests, keyed by the digest of the request body. Since a conditional POST is
+// a precondition rather than a validation, stale responses are not revalidated but
+// fetched again, and since the request is treated as safe it does not invalidate the
+// stored responses for its target URI. The request body is buffered to compute its
+// digest.
+func (t *Transport) roundTripQuery(req *http.Request) (rep *http.Response, err error) {
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	policy := t.policy(req)
+	primary := bodyKey(policy.key(req), body)
+	reqcc := requestCacheControl(req.Header)
+	if reqcc.has("no-store") {
+		t.deleteEntry(policy.cache, primary)
+		return t.bypass(req)
+	}
+
+	key, cached, freshness := t.lookup(primary, req, policy)
+	freshness.request(reqcc)
+	if cached != nil {
+		if freshness.fresh() {
+			return t.annotate(serve(cached, freshness), CacheHit), nil
+		}
+		cached.Body.Close()
+	}
+
+	if reqcc.has("only-if-cached") {
+		t.Hooks.failed(req, ErrOnlyIfCachedMiss)
+		return t.annotate(gatewayTimeout(req), CacheMiss), nil
+	}
+
+	if _, err = t.limit(req, policy, false); err != nil {
+		return nil, err
+	}
+
+	requestTime := t.now()
+	if rep, err = t.forward(req); err != nil {
+		return nil, err
+	}
+	responseTime := t.now()
+
+	switch reason := storeDecision(req, rep, policy); {
+	case reason == notSkipped:
+		t.storeOnRead(primary, req, rep, policy, requestTime, responseTime)
+	case reason == SkipNoStore:
+		t.remove(policy.cache, key)
+		t.Hooks.skipped(req, rep, reason)
+	default:
+		t.Hooks.skipped(req, rep, reason)
+	}
+	return t.annotate(rep, CacheMiss), nil
+}
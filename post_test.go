@@ -0,0 +1,75 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func Post(t *testing.T, client *http.Client, url, body string, headers ...string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rep, err := client.Do(req)
+	require.NoError(t, err)
+	defer rep.Body.Close()
+
+	data, err := io.ReadAll(rep.Body)
+	require.NoError(t, err)
+	return rep, string(data)
+}
+
+func TestTransportCachePOST(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		query, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/no-store" {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		fmt.Fprintf(w, "response %d to %s %s", n, r.Method, query)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{}, httpcache.WithPOSTCaching(), httpcache.WithStatusHeader(httpcache.DefaultStatusHeader))
+	client := transport.Client()
+
+	rep, users := Post(t, client, origin.URL+"/graphql", `{"query":"{ users }"}`)
+	require.Equal(t, `response 1 to POST {"query":"{ users }"}`, users)
+	require.Equal(t, "MISS", rep.Header.Get(httpcache.DefaultStatusHeader))
+
+	rep, body := Post(t, client, origin.URL+"/graphql", `{"query":"{ users }"}`)
+	require.Equal(t, users, body)
+	require.Equal(t, "HIT", rep.Header.Get(httpcache.DefaultStatusHeader))
+
+	// A different body is a different query.
+	_, body = Post(t, client, origin.URL+"/graphql", `{"query":"{ posts }"}`)
+	require.Equal(t, `response 2 to POST {"query":"{ posts }"}`, body)
+
+	// Cached POST requests are queries that do not invalidate the GET response.
+	Get(t, client, origin.URL+"/graphql")
+	Post(t, client, origin.URL+"/graphql", `{"query":"{ comments }"}`)
+	_, body = Get(t, client, origin.URL+"/graphql")
+	require.Equal(t, "response 3 to GET ", body)
+
+	// Request directives are honored.
+	_, body = Post(t, client, origin.URL+"/graphql", `{"query":"{ users }"}`, "Cache-Control", "no-cache")
+	require.Equal(t, `response 5 to POST {"query":"{ users }"}`, body)
+
+	// Responses that may not be stored are not.
+	Post(t, client, origin.URL+"/no-store", `{}`)
+	Post(t, client, origin.URL+"/no-store", `{}`)
+	require.Equal(t, int64(7), origin.Requests())
+
+	// Without POST caching, POST requests are sent to the origin.
+	client = httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Post(t, client, origin.URL+"/graphql", `{"query":"{ users }"}`)
+	Post(t, client, origin.URL+"/graphql", `{"query":"{ users }"}`)
+	require.Equal(t, int64(9), origin.Requests())
+}
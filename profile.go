@@ -0,0 +1,276 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CacheMode determines whether a Profile caches responses as a shared or private cache.
+type CacheMode uint8
+
+const (
+	// ModeDefault uses the Shared setting of the Transport.
+	ModeDefault CacheMode = iota
+
+	// ModePrivate caches responses as a private (client-side) cache.
+	ModePrivate
+
+	// ModeShared caches responses as a shared cache, e.g. for a proxy.
+	ModeShared
+)
+
+// Profile configures how the Transport caches responses from a specific host so that
+// a single Transport can be used by clients of several APIs with different caching
+// requirements. The zero value of each field uses the default behavior.
+type Profile struct {
+	// Mode overrides the Shared setting of the Transport for the host.
+	Mode CacheMode
+
+	// TTL overrides the freshness lifetime of stored responses from the host. Responses
+	// that would otherwise not be stored because they lack explicit or heuristic
+	// freshness information are stored if their status code is cacheable by default or
+	// allowed by StatusCodes; server errors are never stored for the TTL. Responses with
+	// no-store or that require revalidation are still handled as specified by the origin.
+	TTL time.Duration
+
+	// StatusCodes overrides the StatusCodes of the Transport for the host.
+	StatusCodes []int
+
+	// MaxBodySize is the maximum size in bytes of a response body that is stored;
+	// larger responses are passed to the caller without being stored. Zero means that
+	// the size is not limited.
+	MaxBodySize int64
+
+	// KeyHeaders are the request headers whose values are included in the cache key,
+	// e.g. to store separate responses for each API key or tenant. If set, they replace
+	// the KeyHeaders of the Transport.
+	KeyHeaders []string
+
+	// Limiter limits the rate of requests to the host; if set, it replaces the Limiter
+	// of the Transport.
+	Limiter Limiter
+}
+
+// policy is the caching behavior that applies to a request after resolving the host
+// profile against the defaults of the Transport.
+type policy struct {
+	shared      bool
+	ttl         time.Duration
+	statusCodes []int
+	maxBodySize int64
+	keyHeaders  []string
+	ignored     []string
+	partition   string
+	version     string
+
+	negativeTTL    time.Duration
+	negativeStatus []int
+	retryAfter     bool
+	force          bool
+
+	// cache is the cache selected for the request and limiter limits the requests to
+	// its origin.
+	cache   Cache
+	limiter Limiter
+}
+
+// defaultNegativeStatus are the status codes of responses cached by negative caching.
+var defaultNegativeStatus = []int{http.StatusNotFound, http.StatusGone}
+
+// policy returns the caching behavior for the request. Profiles are matched by the host
+// of the request URL including the port, then by the hostname alone.
+func (t *Transport) policy(req *http.Request) policy {
+	config := t.currentConfig()
+	p := policy{
+		shared:         t.Shared,
+		version:        t.Version,
+		statusCodes:    config.StatusCodes,
+		keyHeaders:     t.KeyHeaders,
+		ignored:        t.IgnoredQueryParams,
+		negativeTTL:    config.NegativeTTL,
+		negativeStatus: config.NegativeStatusCodes,
+		retryAfter:     t.RespectRetryAfter,
+		cache:          t.selectCache(req),
+		limiter:        t.Limiter,
+	}
+	if len(p.negativeStatus) == 0 {
+		p.negativeStatus = defaultNegativeStatus
+	}
+
+	if profile := config.profile(req); profile != nil {
+		p.apply(profile)
+	}
+
+	if rule := config.rule(req); rule != nil {
+		p.applyRule(rule)
+	}
+
+	// The credential is hashed so that it is not exposed by the cache key.
+	if credential := req.Header.Get("Authorization"); t.PartitionByCredential && credential != "" {
+		sum := sha256.Sum256([]byte(credential))
+		p.partition = hex.EncodeToString(sum[:])
+		p.shared = false
+	}
+	return p
+}
+
+// selectCache returns the cache selected for the request by the CacheSelector or, if
+// none is selected, the Cache of the Transport.
+func (t *Transport) selectCache(req *http.Request) Cache {
+	if t.CacheSelector != nil {
+		if cache := t.CacheSelector(req); cache != nil {
+			return cache
+		}
+	}
+	return t.Cache
+}
+
+// apply overrides the defaults of the policy with those configured by the profile.
+func (p *policy) apply(profile *Profile) {
+	switch profile.Mode {
+	case ModePrivate:
+		p.shared = false
+	case ModeShared:
+		p.shared = true
+	}
+
+	if len(profile.StatusCodes) > 0 {
+		p.statusCodes = profile.StatusCodes
+	}
+
+	p.ttl = profile.TTL
+	p.maxBodySize = profile.MaxBodySize
+	if len(profile.KeyHeaders) > 0 {
+		p.keyHeaders = profile.KeyHeaders
+	}
+
+	if profile.Limiter != nil {
+		p.limiter = profile.Limiter
+	}
+}
+
+func (c *Config) profile(req *http.Request) *Profile {
+	if len(c.Profiles) == 0 || req.URL == nil {
+		return nil
+	}
+
+	if profile, ok := c.Profiles[strings.ToLower(req.URL.Host)]; ok {
+		return profile
+	}
+	return c.Profiles[strings.ToLower(req.URL.Hostname())]
+}
+
+// key returns the cache key for the request, including the credential partition and
+// the version prefix.
+func (p policy) key(req *http.Request) string {
+	if len(p.ignored) > 0 {
+		req = withoutQueryParams(req, p.ignored)
+	}
+
+	key := cacheKeyWithHeaders(req, p.keyHeaders)
+	if p.partition != "" {
+		key += "|credential:" + p.partition
+	}
+	return versionPrefix(p.version) + key
+}
+
+// withoutQueryParams returns a shallow copy of the request whose URL does not contain
+// the named query parameters. The order and encoding of the remaining parameters are
+// preserved so that the key does not change for URLs without ignored parameters.
+func withoutQueryParams(req *http.Request, names []string) *http.Request {
+	if req.URL == nil || req.URL.RawQuery == "" {
+		return req
+	}
+
+	params := strings.Split(req.URL.RawQuery, "&")
+	kept := params[:0:0]
+	for _, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !slices.Contains(names, name) {
+			kept = append(kept, param)
+		}
+	}
+
+	if len(kept) == len(params) {
+		return req
+	}
+
+	u := *req.URL
+	u.RawQuery = strings.Join(kept, "&")
+	r := *req
+	r.URL = &u
+	return &r
+}
+
+// freshness evaluates the stored response at the specified time, applying the TTL
+// override, Retry-After and negative caching unless the response has been purged.
+func (p policy) freshness(rep *http.Response, now time.Time) freshness {
+	f := evaluateFreshness(rep, p.shared, now)
+	if rep.Header.Get(headerPurged) != "" {
+		return f
+	}
+
+	if p.force && p.overridable(rep.StatusCode) {
+		f.noCache, f.mustRevalidate = false, false
+	}
+
+	delay, throttled := p.throttled(rep)
+	switch {
+	case throttled:
+		f.lifetime, f.heuristic = delay, false
+	case p.ttl > 0 && p.overridable(rep.StatusCode):
+		f.lifetime, f.heuristic = p.ttl, false
+	case p.negativeTTL > 0 && p.negative(rep.StatusCode) && !explicitExpiration(rep.Header, p.shared):
+		f.lifetime, f.heuristic = p.negativeTTL, false
+	}
+	return f
+}
+
+// negative returns true if responses with the status code are cached negatively.
+func (p policy) negative(status int) bool {
+	return slices.Contains(p.negativeStatus, status)
+}
+
+// allowedStatus returns true if responses with the status code may be stored. Unless
+// the status codes are restricted, all status codes are allowed.
+func (p policy) allowedStatus(status int) bool {
+	return p.statusCodes == nil || slices.Contains(p.statusCodes, status)
+}
+
+// cacheableByDefault returns true if responses with the status code may be stored
+// without explicit freshness information.
+func (p policy) cacheableByDefault(status int) bool {
+	if p.statusCodes == nil {
+		_, ok := heuristicStatus[status]
+		return ok
+	}
+	return p.allowedStatus(status)
+}
+
+// overridable returns true if the TTL of a profile or rule and ForceCache apply to
+// responses with the status code: those that are cacheable by default or explicitly
+// allowed by the status codes, but never server errors, which would otherwise be served
+// for the TTL.
+func (p policy) overridable(status int) bool {
+	return status < http.StatusInternalServerError && p.cacheableByDefault(status)
+}
+
+// fits returns true if a response body with the specified length may be stored. A
+// negative length is unknown and is checked as the body is read.
+func (p policy) fits(length int64) bool {
+	return p.maxBodySize <= 0 || length <= p.maxBodySize
+}
+
+// tooLarge returns the error reported for a body that exceeds the maximum body size.
+func (p policy) tooLarge() error {
+	return fmt.Errorf("%w: body exceeds %d bytes", ErrTooLarge, p.maxBodySize)
+}
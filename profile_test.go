@@ -0,0 +1,139 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportProfiles(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		case "/missing":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.WriteHeader(http.StatusNotFound)
+		case "/large":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			fmt.Fprint(w, strings.Repeat("x", 64))
+		case "/chunked":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			for i := 0; i < 8; i++ {
+				fmt.Fprint(w, strings.Repeat("x", 8))
+				w.(http.Flusher).Flush()
+			}
+		case "/tenant":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// The profile is matched by hostname since the origin URL includes a port.
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	transport := &httpcache.Transport{
+		Cache: &httpcache.InMemoryCache{},
+		Profiles: map[string]*httpcache.Profile{
+			u.Hostname(): {
+				Mode:        httpcache.ModeShared,
+				TTL:         time.Hour,
+				StatusCodes: []int{http.StatusOK},
+				MaxBodySize: 32,
+				KeyHeaders:  []string{"X-Tenant"},
+			},
+		},
+	}
+	client := transport.Client()
+
+	tests := []struct {
+		path    string
+		headers []string
+		cached  bool
+	}{
+		{"/ttl", nil, true},
+		{"/private", nil, false},
+		{"/missing", nil, false},
+		{"/large", nil, false},
+		{"/chunked", nil, false},
+		{"/tenant", []string{"X-Tenant", "a"}, true},
+	}
+
+	for _, test := range tests {
+		before := origin.Requests()
+		Get(t, client, origin.URL+test.path, test.headers...)
+		Get(t, client, origin.URL+test.path, test.headers...)
+		cached := origin.Requests()-before == 1
+		require.Equal(t, test.cached, cached, "Test Case: %q", test.path)
+	}
+
+	t.Run("KeyHeaders", func(t *testing.T) {
+		before := origin.Requests()
+		_, body := Get(t, client, origin.URL+"/tenant", "X-Tenant", "b")
+		require.Equal(t, before+1, origin.Requests(), "expected a separate entry per tenant")
+
+		_, cached := Get(t, client, origin.URL+"/tenant", "X-Tenant", "b")
+		require.Equal(t, body, cached)
+	})
+
+	t.Run("NoProfile", func(t *testing.T) {
+		other := strings.Replace(origin.URL, u.Hostname(), "localhost", 1)
+		before := origin.Requests()
+		Get(t, client, other+"/ttl")
+		Get(t, client, other+"/ttl")
+		require.Equal(t, before+2, origin.Requests(), "expected default behavior for other hosts")
+	})
+}
+
+func TestTransportProfileTTLStatus(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch r.URL.Path {
+		case "/error":
+			// The origin fails once and then recovers.
+			if n == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithProfile(u.Hostname(), &httpcache.Profile{TTL: time.Hour}),
+	)
+	client := transport.Client()
+
+	// Server errors are never stored for the TTL of a profile.
+	rep, _ := Get(t, client, origin.URL+"/error")
+	require.Equal(t, http.StatusInternalServerError, rep.StatusCode)
+	rep, body := Get(t, client, origin.URL+"/error")
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "response 2", body)
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/missing", true},
+		{"/teapot", false},
+	}
+
+	for _, test := range tests {
+		before := origin.Requests()
+		Get(t, client, origin.URL+test.path)
+		Get(t, client, origin.URL+test.path)
+		require.Equal(t, test.cached, origin.Requests()-before == 1, "Test Case: %q", test.path)
+	}
+}
@@ -0,0 +1,138 @@
+package httpcache
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SoftPurge marks the stored responses for the URL, including all of the variants of a
+// response that varies, as stale without removing them from the cache. The next request
+// for the URL revalidates the response with the origin (or serves it stale while
+// revalidating if permitted) rather than paying for a cold miss. If no response is
+// stored for the URL, SoftPurge does nothing.
+func (t *Transport) SoftPurge(url string) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodGet, url, nil); err != nil {
+		return err
+	}
+
+	policy := t.policy(req)
+	for _, key := range t.entryKeys(policy.cache, policy.key(req)) {
+		if err = t.softPurge(policy.cache, key, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// softPurge marks the response stored at the key as stale.
+func (t *Transport) softPurge(cache Cache, key string, req *http.Request) (err error) {
+	var cached *http.Response
+	if cached, err = cachedResponse(cache, key, req); err != nil || cached == nil {
+		return err
+	}
+	defer cached.Body.Close()
+
+	var body []byte
+	if body, err = io.ReadAll(cached.Body); err != nil {
+		return fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+
+	cached.Header.Set(headerPurged, "1")
+	t.put(cache, key, cached, body)
+	return nil
+}
+
+// Invalidate removes the stored response for the URL, including its partial and
+// derived variants, so that the next request for the URL is fetched from the origin.
+func (t *Transport) Invalidate(url string) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodGet, url, nil); err != nil {
+		return err
+	}
+
+	t.invalidateURL(req, req.URL)
+	return nil
+}
+
+// PurgeOrigin removes all entries stored for URLs with the same scheme, host and port
+// as the URL, regardless of the version they were stored under, and returns the number
+// of entries that were removed. The cache must implement KeyLister otherwise
+// ErrKeysUnsupported is returned.
+func (t *Transport) PurgeOrigin(origin string) (n int, err error) {
+	var target *url.URL
+	if target, err = url.Parse(origin); err != nil {
+		return 0, err
+	}
+
+	lister, ok := t.Cache.(KeyLister)
+	if !ok {
+		return 0, ErrKeysUnsupported
+	}
+
+	for _, key := range lister.Keys() {
+		if u, ok := keyOrigin(key); ok && IsSameOrigin(u, target) {
+			t.remove(t.Cache, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// keyOrigin returns the scheme and host of the URL that the key was derived from. Keys
+// may be prefixed with a version, method or index name and suffixed with header values.
+func keyOrigin(key string) (*url.URL, bool) {
+	i := strings.Index(key, "://")
+	if i <= 0 {
+		return nil, false
+	}
+
+	start := i
+	for start > 0 && isSchemeChar(key[start-1]) {
+		start--
+	}
+
+	host := key[i+3:]
+	if end := strings.IndexAny(host, "/?#| "); end >= 0 {
+		host = host[:end]
+	}
+
+	if start == i || host == "" {
+		return nil, false
+	}
+	return &url.URL{Scheme: key[start:i], Host: host}, true
+}
+
+func isSchemeChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'
+}
+
+// clearsSiteCache returns true if the Clear-Site-Data header of the response includes
+// the "cache" directive or the "*" wildcard.
+func clearsSiteCache(header http.Header) bool {
+	for _, value := range header.Values("Clear-Site-Data") {
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.Trim(strings.TrimSpace(directive), `"`) {
+			case "cache", "*":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clearSiteData purges all entries stored for the origin of the request if the origin
+// responded with Clear-Site-Data: "cache".
+func (t *Transport) clearSiteData(req *http.Request, rep *http.Response) {
+	if t.Cache == nil || !clearsSiteCache(rep.Header) {
+		return
+	}
+
+	if _, err := t.PurgeOrigin(req.URL.String()); err != nil {
+		GetLogger().Warn("could not clear site data", slog.String("url", req.URL.String()), slog.Any("error", err))
+	}
+}
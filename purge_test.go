@@ -0,0 +1,139 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestSoftPurge(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+
+	// Purging a missing entry is a no-op.
+	require.NoError(t, transport.SoftPurge(origin.URL))
+
+	Get(t, client, origin.URL)
+	Get(t, client, origin.URL)
+	require.Equal(t, int64(1), origin.Requests())
+
+	// After a soft purge the entry is revalidated rather than refetched.
+	require.NoError(t, transport.SoftPurge(origin.URL))
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Empty(t, rep.Header.Get("X-Httpcache-Purged"))
+	require.Equal(t, int64(2), origin.Requests())
+
+	require.Error(t, transport.SoftPurge("://invalid"))
+}
+
+func TestSoftPurgeVary(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept")
+		fmt.Fprintf(w, "%s response %d", r.Header.Get("Accept"), n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+
+	Get(t, client, origin.URL, "Accept", "json")
+	Get(t, client, origin.URL, "Accept", "xml")
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Every variant is marked as stale, not only the variant of a request without headers.
+	require.NoError(t, transport.SoftPurge(origin.URL))
+	_, body := Get(t, client, origin.URL, "Accept", "json")
+	require.Equal(t, "json response 3", body)
+	_, body = Get(t, client, origin.URL, "Accept", "xml")
+	require.Equal(t, "xml response 4", body)
+}
+
+func TestInvalidate(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+
+	Get(t, client, origin.URL)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+
+	require.NoError(t, transport.Invalidate(origin.URL))
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 2", body)
+
+	require.Error(t, transport.Invalidate("://invalid"))
+}
+
+func TestPurgeOrigin(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	keys := []string{
+		"http://example.com",
+		"http://example.com:80/a?b=c",
+		"[v2] http://example.com/a|vary:Accept:json",
+		"POST http://example.com/form",
+		"[v2] collection:http://example.com/items",
+		"https://example.com/a",
+		"http://example.com:8080/a",
+		"http://example.org/a",
+		"aside:token",
+	}
+	for _, key := range keys {
+		cache.Put(key, []byte("entry"))
+	}
+
+	transport := httpcache.NewTransport(cache)
+	n, err := transport.PurgeOrigin("http://EXAMPLE.com/anything")
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.ElementsMatch(t, keys[5:], cache.Keys())
+
+	_, err = transport.PurgeOrigin("://invalid")
+	require.Error(t, err)
+
+	_, err = httpcache.NewTransport(nopCache{}).PurgeOrigin("http://example.com")
+	require.ErrorIs(t, err, httpcache.ErrKeysUnsupported)
+}
+
+func TestTransportClearSiteData(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if r.URL.Path == "/logout" {
+			w.Header().Set("Clear-Site-Data", `"cookies", "cache"`)
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	cache.Put("http://example.com/other", []byte("entry"))
+	client := httpcache.NewTransport(cache).Client()
+
+	Get(t, client, origin.URL+"/a")
+	Get(t, client, origin.URL+"/b")
+	require.Len(t, cache.Keys(), 3)
+
+	// The entries are purged before the response that cleared them is stored.
+	Get(t, client, origin.URL+"/logout")
+	require.ElementsMatch(t, []string{"http://example.com/other", origin.URL + "/logout"}, cache.Keys(), "expected the origin's entries to be purged")
+
+	before := origin.Requests()
+	Get(t, client, origin.URL+"/a")
+	require.Equal(t, before+1, origin.Requests())
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package httpcache_test
+
+// raceEnabled is true if the tests are run with the race detector, which allocates
+// in code that otherwise does not, so allocation counts cannot be checked.
+const raceEnabled = true
@@ -0,0 +1,132 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// byteRange is an inclusive range of bytes within a representation.
+type byteRange struct {
+	start, end int64
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// parseRange parses a Range header containing a single byte range against a
+// representation of the specified size (RFC 9110 §14.1.2). Multiple ranges and other
+// range units are not supported and ok is false. If the range is valid but cannot be
+// satisfied by the representation, satisfiable is false.
+func parseRange(header string, size int64) (r byteRange, satisfiable, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return r, false, false
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return r, false, false
+	}
+
+	// Suffix range: the last N bytes of the representation.
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return r, false, false
+		}
+		if n == 0 || size == 0 {
+			return r, false, true
+		}
+		return byteRange{start: max(size-n, 0), end: size - 1}, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return r, false, false
+	}
+
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return r, false, false
+		}
+		end = min(end, size-1)
+	}
+
+	if start >= size {
+		return r, false, true
+	}
+	return byteRange{start: start, end: end}, true, true
+}
+
+// rangeResponse creates a 206 Partial Content response for the Range header from the
+// complete stored response, or a 416 Range Not Satisfiable response if the range is not
+// satisfiable. The body of the stored response is consumed. If the Range header is not
+// supported, ok is false and the request must be forwarded to the origin.
+func rangeResponse(cached *http.Response, header string) (_ *http.Response, ok bool, err error) {
+	defer cached.Body.Close()
+
+	var body []byte
+	if body, err = io.ReadAll(cached.Body); err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrEntryCorrupt, err)
+	}
+
+	size := int64(len(body))
+	r, satisfiable, ok := parseRange(header, size)
+	if !ok {
+		return nil, false, nil
+	}
+
+	partial := *cached
+	partial.Header = cached.Header.Clone()
+
+	if !satisfiable {
+		partial.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		partial.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		partial.Body = http.NoBody
+		partial.ContentLength = 0
+	} else {
+		partial.StatusCode = http.StatusPartialContent
+		partial.Header.Set("Content-Range", r.contentRange(size))
+		partial.Body = io.NopCloser(bytes.NewReader(body[r.start : r.end+1]))
+		partial.ContentLength = r.length()
+	}
+
+	partial.Status = fmt.Sprintf("%d %s", partial.StatusCode, http.StatusText(partial.StatusCode))
+	partial.Header.Set("Content-Length", strconv.FormatInt(partial.ContentLength, 10))
+	return &partial, true, nil
+}
+
+// rangeMatches returns true if a range of the stored response may be served for the
+// request, i.e. if the request has no If-Range precondition or the precondition matches.
+func rangeMatches(req *http.Request, stored http.Header) bool {
+	condition := req.Header.Get("If-Range")
+	return condition == "" || ifRangeMatches(condition, stored)
+}
+
+// ifRangeMatches returns true if the If-Range precondition matches the validators of
+// the stored response using the strong comparison (RFC 9110 §13.1.5).
+func ifRangeMatches(condition string, stored http.Header) bool {
+	condition = strings.TrimSpace(condition)
+	if strings.HasPrefix(condition, `"`) || strings.HasPrefix(condition, "W/") {
+		etag := stored.Get("ETag")
+		return etag != "" && !strings.HasPrefix(condition, "W/") && !strings.HasPrefix(etag, "W/") && etag == condition
+	}
+
+	date, err := http.ParseTime(condition)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(stored.Get("Last-Modified"))
+	return err == nil && modified.Equal(date)
+}
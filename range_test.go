@@ -0,0 +1,221 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header      string
+		start, end  int64
+		satisfiable bool
+		ok          bool
+	}{
+		{"bytes=0-4", 0, 4, true, true},
+		{"bytes=5-", 5, 9, true, true},
+		{"bytes=-3", 7, 9, true, true},
+		{"bytes=-30", 0, 9, true, true},
+		{"bytes=2-100", 2, 9, true, true},
+		{"bytes=10-", 0, 0, false, true},
+		{"bytes=-0", 0, 0, false, true},
+		{"bytes=0-1,4-5", 0, 0, false, false},
+		{"bytes=5-2", 0, 0, false, false},
+		{"bytes=a-b", 0, 0, false, false},
+		{"items=0-4", 0, 0, false, false},
+	}
+
+	for _, test := range tests {
+		start, end, satisfiable, ok := httpcache.ParseRange(test.header, 10)
+		require.Equal(t, test.ok, ok, "Test Case: %q", test.header)
+		require.Equal(t, test.satisfiable, satisfiable, "Test Case: %q", test.header)
+		if satisfiable {
+			require.Equal(t, test.start, start, "Test Case: %q", test.header)
+			require.Equal(t, test.end, end, "Test Case: %q", test.header)
+		}
+	}
+}
+
+func TestTransportIfRange(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", etag.Load().(string))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(fmt.Sprintf("response %d", n)))
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Get(t, client, origin.URL)
+
+	t.Run("Match", func(t *testing.T) {
+		rep, body := Get(t, client, origin.URL, "Range", "bytes=0-3", "If-Range", `"v1"`)
+		require.Equal(t, http.StatusPartialContent, rep.StatusCode)
+		require.Equal(t, "resp", body)
+		require.Equal(t, "bytes 0-3/10", rep.Header.Get("Content-Range"))
+		require.Equal(t, "4", rep.Header.Get("Content-Length"))
+		require.Equal(t, int64(1), origin.Requests())
+	})
+
+	t.Run("Not Satisfiable", func(t *testing.T) {
+		rep, _ := Get(t, client, origin.URL, "Range", "bytes=50-", "If-Range", `"v1"`)
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rep.StatusCode)
+		require.Equal(t, "bytes */10", rep.Header.Get("Content-Range"))
+		require.Equal(t, int64(1), origin.Requests())
+	})
+
+	t.Run("Multiple Ranges", func(t *testing.T) {
+		rep, _ := Get(t, client, origin.URL, "Range", "bytes=0-1,3-4", "If-Range", `"v1"`)
+		require.Equal(t, http.StatusPartialContent, rep.StatusCode)
+		require.Equal(t, int64(2), origin.Requests())
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		// The client holds an older representation than the one in the cache.
+		etag.Store(`"v2"`)
+		rep, body := Get(t, client, origin.URL, "Range", "bytes=0-3", "If-Range", `"v0"`)
+		require.Equal(t, http.StatusOK, rep.StatusCode)
+		require.Equal(t, "response 3", body)
+
+		// The complete response replaced the stored entry.
+		_, body = Get(t, client, origin.URL)
+		require.Equal(t, "response 3", body)
+		require.Equal(t, int64(3), origin.Requests())
+	})
+}
+
+func TestTransportRange(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(fmt.Sprintf("response %d", n)))
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache, httpcache.WithStatusHeader(httpcache.DefaultStatusHeader)).Client()
+
+	// Partial responses from the origin are not stored.
+	rep, body := Get(t, client, origin.URL, "Range", "bytes=0-3")
+	require.Equal(t, http.StatusPartialContent, rep.StatusCode)
+	require.Equal(t, "resp", body)
+	require.Zero(t, cache.Len())
+
+	// Once the complete response is stored, ranges are sliced from the stored body.
+	Get(t, client, origin.URL)
+	require.Equal(t, int64(2), origin.Requests())
+
+	tests := []struct {
+		header, body, contentRange string
+		status                     int
+	}{
+		{"bytes=0-3", "resp", "bytes 0-3/10", http.StatusPartialContent},
+		{"bytes=9-", "2", "bytes 9-9/10", http.StatusPartialContent},
+		{"bytes=-5", "nse 2", "bytes 5-9/10", http.StatusPartialContent},
+		{"bytes=20-", "", "bytes */10", http.StatusRequestedRangeNotSatisfiable},
+	}
+
+	for _, test := range tests {
+		rep, body := Get(t, client, origin.URL, "Range", test.header)
+		require.Equal(t, test.status, rep.StatusCode, "Test Case: %q", test.header)
+		require.Equal(t, test.body, body, "Test Case: %q", test.header)
+		require.Equal(t, test.contentRange, rep.Header.Get("Content-Range"), "Test Case: %q", test.header)
+	}
+	require.Equal(t, int64(2), origin.Requests())
+
+	// Multiple ranges are forwarded to the origin.
+	rep, _ = Get(t, client, origin.URL, "Range", "bytes=0-1,3-4")
+	require.Equal(t, http.StatusPartialContent, rep.StatusCode)
+	require.Equal(t, int64(3), origin.Requests())
+
+	// A request with no-store is not answered from the cache.
+	rep, body = Get(t, client, origin.URL, "Range", "bytes=0-3", "Cache-Control", "no-store")
+	require.Equal(t, http.StatusPartialContent, rep.StatusCode)
+	require.Equal(t, string(httpcache.CacheBypass), rep.Header.Get(httpcache.DefaultStatusHeader))
+	require.Equal(t, int64(4), origin.Requests())
+
+	// A request with only-if-cached that misses is not forwarded to the origin.
+	rep, _ = Get(t, client, origin.URL+"/missing", "Range", "bytes=0-3", "Cache-Control", "only-if-cached")
+	require.Equal(t, http.StatusGatewayTimeout, rep.StatusCode)
+	require.Equal(t, int64(4), origin.Requests())
+}
+
+func TestTransportPartialContent(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", etag.Load().(string))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(cache).Client()
+
+	steps := []struct {
+		header, body string
+		requests     int64
+	}{
+		{"bytes=0-3", "0123", 1},
+		{"bytes=1-2", "12", 1},
+		{"bytes=7-", "789", 2},
+		{"bytes=-2", "89", 2},
+		{"bytes=2-8", "2345678", 3},
+		{"bytes=3-5", "345", 3},
+		{"bytes=0-", "0123456789", 3},
+	}
+
+	for _, step := range steps {
+		rep, body := Get(t, client, origin.URL, "Range", step.header)
+		require.Equal(t, http.StatusPartialContent, rep.StatusCode, "Test Case: %q", step.header)
+		require.Equal(t, step.body, body, "Test Case: %q", step.header)
+		require.Equal(t, step.requests, origin.Requests(), "Test Case: %q", step.header)
+	}
+
+	// Once all ranges have been received, the complete response is stored.
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "0123456789", body)
+	require.Equal(t, int64(3), origin.Requests())
+	require.Equal(t, 1, cache.Len())
+}
+
+func TestTransportPartialContentValidator(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", etag.Load().(string))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	})
+
+	client := httpcache.NewTransport(&httpcache.InMemoryCache{}).Client()
+	Get(t, client, origin.URL, "Range", "bytes=0-4")
+
+	// A range of a different representation replaces the stored ranges.
+	etag.Store(`"v2"`)
+	Get(t, client, origin.URL, "Range", "bytes=5-9")
+	require.Equal(t, int64(2), origin.Requests())
+
+	_, body := Get(t, client, origin.URL, "Range", "bytes=6-7")
+	require.Equal(t, "67", body)
+	require.Equal(t, int64(2), origin.Requests())
+
+	_, body = Get(t, client, origin.URL, "Range", "bytes=0-1")
+	require.Equal(t, "01", body)
+	require.Equal(t, int64(3), origin.Requests())
+
+	// Ranges without a strong validator are not stored.
+	etag.Store(`W/"v3"`)
+	Get(t, client, origin.URL, "Range", "bytes=2-3")
+	Get(t, client, origin.URL, "Range", "bytes=2-3")
+	require.Equal(t, int64(5), origin.Requests())
+}
@@ -0,0 +1,67 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+)
+
+// cachingReadCloser wraps a response body, buffering the bytes as they are read by the
+// caller. When the body has been completely read, the buffered bytes are passed to the
+// store callback so that the response can be cached without delaying the caller. If
+// the limit is positive and the body exceeds it, buffering stops and the body is not
+// stored and the exceeded callback, if any, is called. The buffered bytes are tracked
+// by the gauge until they are released.
+type cachingReadCloser struct {
+	rc       io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	gauge    *bufferGauge
+	store    func(body []byte)
+	exceeded func()
+	done     bool
+}
+
+var _ io.ReadCloser = (*cachingReadCloser)(nil)
+
+func newCachingReadCloser(rc io.ReadCloser, limit int64, gauge *bufferGauge, store func([]byte)) *cachingReadCloser {
+	gauge.open()
+	return &cachingReadCloser{rc: rc, limit: limit, gauge: gauge, store: store}
+}
+
+func (r *cachingReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.rc.Read(p)
+	if r.done {
+		return n, err
+	}
+
+	r.buf.Write(p[:n])
+	r.gauge.add(n)
+
+	switch {
+	case r.limit > 0 && int64(r.buf.Len()) > r.limit:
+		r.finish(false)
+		if r.exceeded != nil {
+			r.exceeded()
+		}
+	case err == io.EOF:
+		r.finish(true)
+	}
+	return n, err
+}
+
+func (r *cachingReadCloser) Close() error {
+	if !r.done {
+		r.finish(false)
+	}
+	return r.rc.Close()
+}
+
+// finish stores the buffered body if requested and releases the buffer.
+func (r *cachingReadCloser) finish(store bool) {
+	r.done = true
+	if store {
+		r.store(r.buf.Bytes())
+	}
+	r.gauge.release(r.buf.Len())
+	r.buf = bytes.Buffer{}
+}
@@ -0,0 +1,143 @@
+package httpcache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Report summarizes the effectiveness of the cache since usage tracking started so that
+// caching policies can be tuned with data, e.g. by shortening the TTL of hosts whose
+// entries are rarely reused or by not caching endpoints whose entries are never read.
+type Report struct {
+	// Since is when usage tracking started and Until is when the report was created.
+	Since time.Time
+	Until time.Time
+
+	// Hosts are the hits, misses and stored bytes of the requests to each host.
+	Hosts map[string]HostReport
+
+	// Entries is the number of stored responses that were inspected. Untracked is the
+	// number of them that were not stored or read by the Transport since tracking
+	// started, e.g. because they were stored by another process, and are not included in
+	// the statistics below.
+	Entries   int
+	Untracked int
+
+	// NeverRead is the number of tracked entries that were not read since they were
+	// stored and NeverReadBytes is the storage they occupy (see EntrySize).
+	NeverRead      int
+	NeverReadBytes int64
+
+	// AverageTTL is the mean freshness lifetime of the tracked entries, AverageReads is
+	// the mean number of times they were read since they were stored, and AverageReuse
+	// is the mean time between storing and last reading the entries that were read. An
+	// AverageReuse much shorter than AverageTTL suggests that entries are kept longer
+	// than they are useful.
+	AverageTTL   time.Duration
+	AverageReads float64
+	AverageReuse time.Duration
+}
+
+// HostReport counts how the requests to a host were served.
+type HostReport struct {
+	Hits   int64
+	Misses int64
+	Bytes  int64
+}
+
+// HitRatio returns the fraction of the requests that were served from the cache.
+func (h HostReport) HitRatio() float64 {
+	if h.Hits+h.Misses == 0 {
+		return 0
+	}
+	return float64(h.Hits) / float64(h.Hits+h.Misses)
+}
+
+// Report analyzes the usage recorded by the Transport and the metadata of the entries
+// stored in the cache. Hosts are only reported if TrackUsage is set and entries are
+// only tracked if TrackUsage or MaxIdle is set. The cache must implement KeyLister
+// otherwise ErrKeysUnsupported is returned.
+func (t *Transport) Report() (*Report, error) {
+	lister, ok := t.Cache.(KeyLister)
+	if !ok {
+		return nil, ErrKeysUnsupported
+	}
+
+	now := t.now()
+	report := &Report{Since: t.accesses.since(now), Until: now}
+	if hosts := t.hosts.snapshot(); hosts != nil {
+		report.Hosts = make(map[string]HostReport, len(hosts))
+		for host, stats := range hosts {
+			report.Hosts[host] = HostReport(stats)
+		}
+	}
+
+	var tracked, reused int
+	var ttl, reuse time.Duration
+	var reads int64
+	for _, key := range lister.Keys() {
+		val, ok := t.Cache.Get(key)
+		if !ok {
+			continue
+		}
+
+		// Vary indexes and other internal entries do not store a response.
+		meta, err := t.entryMetadata(val, now)
+		if err != nil {
+			continue
+		}
+		report.Entries++
+
+		acc, ok, _ := t.accesses.get(now, key)
+		if !ok || !t.tracking() {
+			report.Untracked++
+			continue
+		}
+
+		tracked++
+		ttl += meta.Lifetime
+		reads += acc.reads
+		if acc.reads == 0 {
+			report.NeverRead++
+			report.NeverReadBytes += EntrySize(key, val)
+			continue
+		}
+
+		reused++
+		reuse += acc.lastRead.Sub(meta.Stored)
+	}
+
+	if tracked > 0 {
+		report.AverageTTL = ttl / time.Duration(tracked)
+		report.AverageReads = float64(reads) / float64(tracked)
+	}
+
+	if reused > 0 {
+		report.AverageReuse = reuse / time.Duration(reused)
+	}
+	return report, nil
+}
+
+// String returns a human readable summary of the report.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cache report from %s to %s\n", r.Since.Format(time.RFC3339), r.Until.Format(time.RFC3339))
+
+	hosts := make([]string, 0, len(r.Hosts))
+	for host := range r.Hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+
+	for _, host := range hosts {
+		h := r.Hosts[host]
+		fmt.Fprintf(&b, "host %s: %.1f%% hit ratio (%d hits, %d misses), %d bytes stored\n", host, 100*h.HitRatio(), h.Hits, h.Misses, h.Bytes)
+	}
+
+	fmt.Fprintf(&b, "entries: %d (%d untracked)\n", r.Entries, r.Untracked)
+	fmt.Fprintf(&b, "never read: %d entries, %d bytes\n", r.NeverRead, r.NeverReadBytes)
+	fmt.Fprintf(&b, "average ttl: %s, average reuse: %s, average reads: %.1f\n", r.AverageTTL, r.AverageReuse, r.AverageReads)
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportReport(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+
+	clock := &fakeClock{now: time.Now()}
+	cache := &httpcache.InMemoryCache{}
+	transport := httpcache.NewTransport(cache, httpcache.WithUsageTracking(), httpcache.WithClock(clock))
+	client := transport.Client()
+
+	// An entry stored by another transport is not tracked.
+	Get(t, httpcache.NewTransport(cache).Client(), origin.URL+"/other")
+
+	Get(t, client, origin.URL+"/popular")
+	clock.Advance(time.Minute)
+	Get(t, client, origin.URL+"/popular")
+	clock.Advance(time.Minute)
+	Get(t, client, origin.URL+"/popular")
+	Get(t, client, origin.URL+"/unused")
+
+	report, err := transport.Report()
+	require.NoError(t, err)
+
+	host := strings.TrimPrefix(origin.URL, "http://")
+	require.Equal(t, map[string]httpcache.HostReport{host: {Hits: 2, Misses: 2, Bytes: 200}}, report.Hosts)
+	require.Equal(t, 0.5, report.Hosts[host].HitRatio())
+	require.Equal(t, 3, report.Entries)
+	require.Equal(t, 1, report.Untracked)
+	require.Equal(t, 1, report.NeverRead)
+
+	val, ok := cache.Get(origin.URL + "/unused")
+	require.True(t, ok)
+	require.Equal(t, httpcache.EntrySize(origin.URL+"/unused", val), report.NeverReadBytes)
+
+	require.Equal(t, time.Hour, report.AverageTTL)
+	require.Equal(t, 1.0, report.AverageReads)
+	require.Equal(t, 2*time.Minute, report.AverageReuse)
+	require.Contains(t, report.String(), "host "+host+": 50.0% hit ratio (2 hits, 2 misses), 200 bytes stored")
+
+	_, err = httpcache.NewTransport(nopCache{}).Report()
+	require.ErrorIs(t, err, httpcache.ErrKeysUnsupported)
+}
@@ -0,0 +1,56 @@
+package httpcache
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// throttledStatus are the status codes of responses that ask the client to retry the
+// request later and are cached for the duration of their Retry-After header.
+var throttledStatus = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+
+// throttled returns the freshness lifetime of a 429 Too Many Requests or 503 Service
+// Unavailable response indicated by its Retry-After header if Retry-After is respected.
+// Responses with explicit expiration information use it instead.
+func (p policy) throttled(rep *http.Response) (time.Duration, bool) {
+	if !p.retryAfter || !slices.Contains(throttledStatus, rep.StatusCode) || explicitExpiration(rep.Header, p.shared) {
+		return 0, false
+	}
+
+	_, responseTime := storedTimes(rep.Header)
+	return retryAfter(rep.Header, dateOf(rep.Header, responseTime))
+}
+
+// retryAfter parses the Retry-After header, which is either a number of seconds or an
+// HTTP-date (RFC 9110 §10.2.3), and returns the delay relative to the date of the
+// response.
+func retryAfter(header http.Header, date time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if delay, ok := deltaSeconds(value); ok {
+		return delay, true
+	}
+
+	retry, err := http.ParseTime(value)
+	if err != nil || date.IsZero() {
+		return 0, false
+	}
+	return max(retry.Sub(date), 0), true
+}
+
+// retryable returns true if the request failed with a network error and may be sent
+// again: it is a GET or HEAD request without a body whose context is not done.
+func retryable(req *http.Request, err error) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	return req.Context().Err() == nil && networkError(err)
+}
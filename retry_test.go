@@ -0,0 +1,137 @@
+package httpcache_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportRetryAfter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/limited":
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/maintenance":
+			w.Header().Set("Retry-After", clock.Now().Add(2*time.Minute).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/explicit":
+			w.Header().Set("Retry-After", "3600")
+			w.Header().Set("Cache-Control", "max-age=10")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/no-store":
+			w.Header().Set("Retry-After", "60")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := &httpcache.Transport{Cache: &httpcache.InMemoryCache{}, Clock: clock, RespectRetryAfter: true}
+	client := transport.Client()
+
+	tests := []struct {
+		path     string
+		status   int
+		lifetime time.Duration
+	}{
+		{"/limited", http.StatusTooManyRequests, time.Minute},
+		{"/maintenance", http.StatusServiceUnavailable, 2 * time.Minute},
+		{"/explicit", http.StatusTooManyRequests, 10 * time.Second},
+		{"/no-store", http.StatusTooManyRequests, 0},
+		{"/unavailable", http.StatusServiceUnavailable, 0},
+	}
+
+	for _, test := range tests {
+		before := origin.Requests()
+		rep, body := Get(t, client, origin.URL+test.path)
+		require.Equal(t, test.status, rep.StatusCode, "Test Case: %q", test.path)
+
+		// The response is served from the cache until the lifetime has passed.
+		if test.lifetime > 0 {
+			clock.Advance(test.lifetime - time.Second)
+			_, cached := Get(t, client, origin.URL+test.path)
+			require.Equal(t, body, cached, "Test Case: %q", test.path)
+			require.Equal(t, before+1, origin.Requests(), "Test Case: %q", test.path)
+			clock.Advance(2 * time.Second)
+		}
+
+		Get(t, client, origin.URL+test.path)
+		require.Equal(t, before+2, origin.Requests(), "Test Case: %q", test.path)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		transport.RespectRetryAfter = false
+		before := origin.Requests()
+		Get(t, client, origin.URL+"/limited?disabled")
+		Get(t, client, origin.URL+"/limited?disabled")
+		require.Equal(t, before+2, origin.Requests())
+	})
+}
+
+func TestTransportRetryOnNetworkError(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// The upstream fails the number of times set by failures before reaching the origin.
+	var attempts, failures atomic.Int64
+	upstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		if failures.Add(-1) >= 0 {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	clock := &fakeClock{now: time.Now()}
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithClock(clock),
+		httpcache.WithRetryOnNetworkError(),
+	)
+	transport.Transport = upstream
+	client := transport.Client()
+
+	// A single failure is retried transparently.
+	failures.Store(1)
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(2), attempts.Load())
+
+	// If the retry fails too, the error is returned unless stale responses may be served.
+	clock.Advance(time.Hour)
+	attempts.Store(0)
+	failures.Store(2)
+	_, err := client.Get(origin.URL)
+	require.Error(t, err)
+	require.Equal(t, int64(2), attempts.Load())
+
+	transport.ServeStaleOnNetworkError = true
+	attempts.Store(0)
+	failures.Store(2)
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "3600", rep.Header.Get("Age"))
+	require.Equal(t, int64(2), attempts.Load())
+
+	// Unsafe requests are never retried.
+	attempts.Store(0)
+	failures.Store(1)
+	req, _ := http.NewRequest(http.MethodPost, origin.URL, strings.NewReader("body"))
+	_, err = client.Do(req)
+	require.Error(t, err)
+	require.Equal(t, int64(1), attempts.Load())
+}
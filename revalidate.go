@@ -0,0 +1,72 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// conditionalRequest returns a copy of the request with preconditions derived from the
+// validators of the stored response (RFC 9111 §4.3.1). If the stored response has no
+// validators or the request already has its own preconditions, nil is returned.
+func conditionalRequest(req *http.Request, stored http.Header) *http.Request {
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return nil
+	}
+
+	// Entity tags are preferred; Last-Modified is only used when there is no ETag.
+	var name, value string
+	switch {
+	case stored.Get("ETag") != "":
+		name, value = "If-None-Match", stored.Get("ETag")
+	case stored.Get("Last-Modified") != "":
+		name, value = "If-Modified-Since", stored.Get("Last-Modified")
+	default:
+		return nil
+	}
+
+	outreq := req.Clone(req.Context())
+	outreq.Header.Set(name, value)
+	return outreq
+}
+
+// hasValidators returns true if the stored response can be revalidated with a
+// conditional request.
+func hasValidators(stored http.Header) bool {
+	return stored.Get("ETag") != "" || stored.Get("Last-Modified") != ""
+}
+
+// revalidated updates the stored response with the header of the 304 Not Modified
+// response from the origin (RFC 9111 §3.2), stores it so that its freshness is reset
+// without refetching the body, and returns it to be served to the caller.
+func (t *Transport) revalidated(primary string, req *http.Request, cached, notModified *http.Response, policy policy, requestTime, responseTime time.Time) *http.Response {
+	io.Copy(io.Discard, notModified.Body)
+	notModified.Body.Close()
+
+	updateHeaders(cached.Header, notModified.Header)
+	body, err := io.ReadAll(cached.Body)
+	cached.Body.Close()
+	if err == nil && !parseCacheControl(cached.Header).has("no-store") {
+		snapshot := *cached
+		snapshot.Header = cached.Header.Clone()
+		t.store(primary, req, &snapshot, body, policy, requestTime, responseTime)
+	}
+
+	setStoredTimes(cached.Header, requestTime, responseTime)
+	cached.Body = io.NopCloser(bytes.NewReader(body))
+	cached.ContentLength = int64(len(body))
+	return serve(cached, policy.freshness(cached, t.now()))
+}
+
+// updateHeaders replaces the stored header fields with those of a newer response as
+// described by RFC 9111 §3.2, except for hop-by-hop fields and Content-Length.
+func updateHeaders(stored, fresh http.Header) {
+	fresh = fresh.Clone()
+	removeHopByHop(fresh)
+	fresh.Del("Content-Length")
+
+	for name, values := range fresh {
+		stored[name] = values
+	}
+}
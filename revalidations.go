@@ -0,0 +1,187 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// revalidationsKey returns the key of the entry that records the pending background
+// revalidations of the Transport.
+func revalidationsKey(prefix string) string {
+	return prefix + "revalidations:pending"
+}
+
+// pendingRevalidation is a background revalidation that has been scheduled but has not
+// completed. The request headers are limited to those needed to find the stored
+// response again; credentials are never recorded.
+type pendingRevalidation struct {
+	Key    string      `json:"key"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Due    time.Time   `json:"due"`
+}
+
+// revalidations serializes the updates of the pending revalidations in the cache.
+type revalidations struct {
+	mu sync.Mutex
+}
+
+// persistRevalidation records that the stored response for the key is due to be
+// revalidated so that the revalidation can be resumed if the process exits first.
+// Revalidations of responses partitioned by credential are not recorded because they
+// cannot be resumed without storing the credentials.
+func (t *Transport) persistRevalidation(key string, req *http.Request, stored http.Header, due time.Time) {
+	if !t.PersistRevalidations || t.Cache == nil {
+		return
+	}
+
+	policy := t.policy(req)
+	if policy.partition != "" {
+		return
+	}
+
+	header := make(http.Header)
+	for _, name := range append(varyHeaders(stored), policy.keyHeaders...) {
+		name = http.CanonicalHeaderKey(name)
+		if values := req.Header.Values(name); len(values) > 0 && !slices.Contains(credentialHeaders, name) {
+			header[name] = values
+		}
+	}
+
+	pending := pendingRevalidation{Key: key, URL: req.URL.String(), Header: header, Due: due}
+	t.updateRevalidations(func(pendings map[string]pendingRevalidation) {
+		if _, ok := pendings[key]; !ok {
+			pendings[key] = pending
+		}
+	})
+}
+
+// completeRevalidations removes the revalidations of the keys from the pending
+// revalidations recorded in the cache.
+func (t *Transport) completeRevalidations(keys ...string) {
+	if !t.PersistRevalidations || t.Cache == nil {
+		return
+	}
+
+	t.updateRevalidations(func(pendings map[string]pendingRevalidation) {
+		for _, key := range keys {
+			delete(pendings, key)
+		}
+	})
+}
+
+// updateRevalidations applies the update to the pending revalidations recorded in the
+// cache and stores the result, removing the entry if none are pending.
+func (t *Transport) updateRevalidations(update func(map[string]pendingRevalidation)) {
+	t.revalidations.mu.Lock()
+	defer t.revalidations.mu.Unlock()
+
+	key := revalidationsKey(versionPrefix(t.Version))
+	pendings := t.pendingRevalidations()
+	n := len(pendings)
+	update(pendings)
+
+	if len(pendings) == 0 {
+		if n > 0 {
+			t.Cache.Del(key)
+		}
+		return
+	}
+
+	records := make([]pendingRevalidation, 0, len(pendings))
+	for _, pending := range pendings {
+		records = append(records, pending)
+	}
+	slices.SortFunc(records, func(a, b pendingRevalidation) int { return a.Due.Compare(b.Due) })
+
+	val, err := json.Marshal(records)
+	if err != nil {
+		GetLogger().Warn("could not record pending revalidations", slog.Any("error", err))
+		return
+	}
+	t.Cache.Put(key, val)
+}
+
+// pendingRevalidations returns the pending revalidations recorded in the cache by key.
+func (t *Transport) pendingRevalidations() map[string]pendingRevalidation {
+	pendings := make(map[string]pendingRevalidation)
+	val, ok := t.Cache.Get(revalidationsKey(versionPrefix(t.Version)))
+	if !ok {
+		return pendings
+	}
+
+	var records []pendingRevalidation
+	if err := json.Unmarshal(val, &records); err != nil {
+		GetLogger().Warn("ignoring corrupt pending revalidations", slog.Any("error", err))
+		return pendings
+	}
+
+	for _, pending := range records {
+		pendings[pending.Key] = pending
+	}
+	return pendings
+}
+
+// ResumeRevalidations schedules the background revalidations that were pending in the
+// cache when a previous Transport using it stopped, e.g. because the process exited
+// before a stale-while-revalidate response or a batch was revalidated. Each starts at
+// its due time, or immediately if that has passed. It returns the number of
+// revalidations that were scheduled. NewTransport calls it when PersistRevalidations is
+// set; Transports that are constructed directly must call it themselves.
+func (t *Transport) ResumeRevalidations() (n int) {
+	if !t.PersistRevalidations || t.Cache == nil {
+		return 0
+	}
+
+	t.revalidations.mu.Lock()
+	pendings := t.pendingRevalidations()
+	t.revalidations.mu.Unlock()
+
+	now := t.now()
+	for _, pending := range pendings {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, pending.URL, nil)
+		if err != nil {
+			GetLogger().Warn("ignoring pending revalidation", slog.String("key", pending.Key), slog.Any("error", err))
+			t.completeRevalidations(pending.Key)
+			continue
+		}
+
+		if pending.Header != nil {
+			req.Header = pending.Header
+		}
+
+		key := pending.Key
+		t.tasks.start()
+		time.AfterFunc(max(pending.Due.Sub(now), 0), func() {
+			defer t.tasks.done()
+			t.resumeRevalidation(key, req)
+		})
+		n++
+	}
+	return n
+}
+
+// resumeRevalidation revalidates the stored response for the key if it is still stored.
+func (t *Transport) resumeRevalidation(key string, req *http.Request) {
+	policy := t.policy(req)
+	found, cached, _ := t.lookup(policy.key(req), req, policy)
+	if cached == nil || found != key {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		t.completeRevalidations(key)
+		return
+	}
+
+	stored := cached.Header
+	cached.Body.Close()
+	if t.revalidate(key, req, stored) {
+		t.refresh(key, req)
+		t.completeRevalidations(key)
+	}
+}
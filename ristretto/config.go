@@ -1,6 +1,9 @@
 package ristretto
 
-import "github.com/dgraph-io/ristretto/v2"
+import (
+	"github.com/dgraph-io/ristretto/v2"
+	"go.rtnl.ai/httpcache"
+)
 
 // Config is copied from ristretto.Config and uses the httpcache key and value types.
 // It allows users to configure the Ristretto cache used by the Ristretto-backed with
@@ -57,6 +60,13 @@ type Config struct {
 	// OnEvict is called for every eviction with the evicted item.
 	OnEvict func(item *ristretto.Item[[]byte])
 
+	// OnEvictEntry is called for every eviction with the metadata of the evicted
+	// response, including the httpcache key it was stored with. Unlike OnEvict, which
+	// only receives the hash of the key, this allows applications to react to the
+	// eviction of specific entries (e.g. to re-warm critical keys). Evicted values that
+	// cannot be parsed as a stored response are ignored.
+	OnEvictEntry func(meta *httpcache.Metadata)
+
 	// OnReject is called for every rejection done via the policy.
 	OnReject func(item *ristretto.Item[[]byte])
 
@@ -65,6 +75,12 @@ type Config struct {
 	// as well as on rejection of the value.
 	OnExit func(val []byte)
 
+	// OnExitEntry is called whenever a value is removed from the cache, like OnExit,
+	// with the metadata of the removed response, including the httpcache key it was
+	// stored with. Removed values that cannot be parsed as a stored response, e.g. vary
+	// indexes, are ignored.
+	OnExitEntry func(meta *httpcache.Metadata)
+
 	// ShouldUpdate is called when a value already exists in cache and is being updated.
 	// If ShouldUpdate returns true, the cache continues with the update (Set). If the
 	// function returns false, no changes are made in the cache. If the value doesn't
@@ -77,10 +93,8 @@ type Config struct {
 
 	// KeyToHash function is used to customize the key hashing algorithm.
 	// Each key will be hashed using the provided function. If keyToHash value
-	// is not set, the default keyToHash function is used.
-	//
-	// Ristretto has a variety of defaults depending on the underlying interface type
-	// https://github.com/dgraph-io/ristretto/blob/main/z/z.go#L19-L41).
+	// is not set, httpcache.DefaultKeyHasher.KeyToHash is used so that keys are hashed
+	// identically to the other backends and tools of this module.
 	//
 	// Note that if you want 128bit hashes you should use the both the values
 	// in the return of the function. If you want to use 64bit hashes, you can
@@ -95,9 +109,9 @@ type Config struct {
 	// is useful if calculating item cost is particularly expensive and you don't want to
 	// waste time on items that will be dropped anyways.
 	//
-	// To signal to Ristretto that you'd like to use this Cost function:
-	//   1. Set the Cost field to a non-nil function.
-	//   2. When calling Set for new items or item updates, use a `cost` of 0.
+	// If Cost is nil, the cost of an item is the length of its key and value in bytes
+	// and, unless IgnoreInternalCost is set, the cost of storing it internally, so that
+	// MaxCost is a memory budget that includes keys and metadata.
 	Cost func(value []byte) int64
 
 	// IgnoreInternalCost set to true indicates to the cache that the cost of
@@ -116,13 +130,71 @@ func (c *Config) convert() *ristretto.Config[string, []byte] {
 		MaxCost:                c.MaxCost,
 		BufferItems:            c.BufferItems,
 		Metrics:                c.Metrics,
-		OnEvict:                c.OnEvict,
+		OnEvict:                c.onEvict(),
 		OnReject:               c.OnReject,
-		OnExit:                 c.OnExit,
+		OnExit:                 c.onExit(),
 		ShouldUpdate:           c.ShouldUpdate,
-		KeyToHash:              c.KeyToHash,
+		KeyToHash:              c.keyToHash(),
 		Cost:                   c.Cost,
 		IgnoreInternalCost:     c.IgnoreInternalCost,
 		TtlTickerDurationInSec: c.TtlTickerDurationInSec,
 	}
 }
+
+// cost returns the function that computes the cost of an item when it is put, or nil if
+// the Cost function of the Config determines the cost. The per-entry overhead is only
+// estimated with httpcache.EntryOverhead if ristretto does not add its internal cost.
+func (c *Config) cost() func(string, []byte) int64 {
+	switch {
+	case c.Cost != nil:
+		return nil
+	case c.IgnoreInternalCost:
+		return httpcache.EntrySize
+	default:
+		return func(key string, value []byte) int64 {
+			return int64(len(key) + len(value))
+		}
+	}
+}
+
+// keyToHash returns the configured KeyToHash function or the default key hasher.
+func (c *Config) keyToHash() func(string) (uint64, uint64) {
+	if c.KeyToHash != nil {
+		return c.KeyToHash
+	}
+	return httpcache.DefaultKeyHasher.KeyToHash
+}
+
+// onEvict combines the OnEvict and OnEvictEntry callbacks.
+func (c *Config) onEvict() func(item *ristretto.Item[[]byte]) {
+	if c.OnEvictEntry == nil {
+		return c.OnEvict
+	}
+
+	return func(item *ristretto.Item[[]byte]) {
+		if c.OnEvict != nil {
+			c.OnEvict(item)
+		}
+
+		if meta, err := httpcache.ParseMetadata(item.Value); err == nil {
+			c.OnEvictEntry(meta)
+		}
+	}
+}
+
+// onExit combines the OnExit and OnExitEntry callbacks.
+func (c *Config) onExit() func(val []byte) {
+	if c.OnExitEntry == nil {
+		return c.OnExit
+	}
+
+	return func(val []byte) {
+		if c.OnExit != nil {
+			c.OnExit(val)
+		}
+
+		if meta, err := httpcache.ParseMetadata(val); err == nil {
+			c.OnExitEntry(meta)
+		}
+	}
+}
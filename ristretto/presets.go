@@ -0,0 +1,62 @@
+package ristretto
+
+import (
+	"net/http"
+
+	"go.rtnl.ai/httpcache"
+)
+
+const (
+	// DefaultAPIBudget is the memory budget used by NewForAPIResponses (128MB).
+	DefaultAPIBudget = 128 << 20
+
+	// Average sizes used to estimate the number of items a budget will hold.
+	apiResponseSize = 4 << 10
+	assetSize       = 256 << 10
+
+	// Bounds on the number of counters so that tiny and huge budgets remain sane.
+	minCounters = 1e4
+	maxCounters = 1e8
+
+	defaultBufferItems = 64
+)
+
+// NewForAPIResponses creates a cache tuned for many small, frequently accessed API
+// responses (e.g. JSON documents of a few kilobytes) with a memory budget of 128MB.
+func NewForAPIResponses() (*Cache, error) {
+	return New(budgetConfig(DefaultAPIBudget, apiResponseSize))
+}
+
+// NewClient returns an *http.Client that caches responses in a cache created by
+// NewForAPIResponses. The cache is returned so that it can be closed when the client
+// is no longer used.
+func NewClient() (_ *http.Client, _ *Cache, err error) {
+	var cache *Cache
+	if cache, err = NewForAPIResponses(); err != nil {
+		return nil, nil, err
+	}
+	return httpcache.NewTransport(cache).Client(), cache, nil
+}
+
+// NewForAssets creates a cache tuned for fewer, larger responses such as images,
+// scripts, and downloads that will use at most sizeBytes of memory for values.
+func NewForAssets(sizeBytes int64) (*Cache, error) {
+	return New(budgetConfig(sizeBytes, assetSize))
+}
+
+// budgetConfig computes a configuration from a memory budget and the expected average
+// size of a cached value. Ristretto recommends 10x as many counters as the number of
+// items expected in the cache when full. The cost of each item is the size of its key
+// and value in bytes plus the internal cost of storing it so that MaxCost is a true
+// memory budget.
+func budgetConfig(budget, itemSize int64) *Config {
+	counters := 10 * (budget / itemSize)
+	counters = max(counters, minCounters)
+	counters = min(counters, maxCounters)
+
+	return &Config{
+		NumCounters: counters,
+		MaxCost:     budget,
+		BufferItems: defaultBufferItems,
+	}
+}
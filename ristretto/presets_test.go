@@ -0,0 +1,50 @@
+package ristretto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/ristretto"
+)
+
+func TestPresets(t *testing.T) {
+	t.Run("APIResponses", func(t *testing.T) {
+		cache, err := ristretto.NewForAPIResponses()
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Put("foo", []byte(`{"hello":"world"}`))
+		cache.Wait()
+
+		val, ok := cache.Get("foo")
+		require.True(t, ok)
+		require.Equal(t, []byte(`{"hello":"world"}`), val)
+	})
+
+	t.Run("Assets", func(t *testing.T) {
+		cache, err := ristretto.NewForAssets(1 << 20)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Put("small", make([]byte, 512<<10))
+		cache.Wait()
+		_, ok := cache.Get("small")
+		require.True(t, ok)
+
+		// Values larger than the budget are rejected.
+		cache.Put("large", make([]byte, 2<<20))
+		cache.Wait()
+		_, ok = cache.Get("large")
+		require.False(t, ok)
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	client, cache, err := ristretto.NewClient()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.IsType(t, &httpcache.Transport{}, client.Transport)
+	require.Same(t, cache, client.Transport.(*httpcache.Transport).Cache)
+}
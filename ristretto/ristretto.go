@@ -23,6 +23,7 @@ Example Usage:
 package ristretto
 
 import (
+	"context"
 	"io"
 
 	"github.com/dgraph-io/ristretto/v2"
@@ -31,14 +32,17 @@ import (
 
 type Cache struct {
 	cache *ristretto.Cache[string, []byte]
+	cost  func(key string, value []byte) int64
 }
 
 var _ httpcache.Cache = (*Cache)(nil)
 var _ io.Closer = (*Cache)(nil)
+var _ httpcache.Flusher = (*Cache)(nil)
+var _ httpcache.Sizer = (*Cache)(nil)
 
 // Create a new Ristretto-backed httpcache.Cache with the specified configuration.
 func New(config *Config) (_ *Cache, err error) {
-	cache := &Cache{}
+	cache := &Cache{cost: config.cost()}
 	if cache.cache, err = ristretto.NewCache(config.convert()); err != nil {
 		return nil, err
 	}
@@ -54,16 +58,21 @@ func (c *Cache) Get(key string) ([]byte, bool) {
 }
 
 // Put attempts to add the key-value item to the cache. If the cache has reached the
-// maximum size, it may evict other items to make room for the new item. Put does not
-// set an explicitly cost for the item; instead, it relies on the Cost function defined
-// in the Config to determine the cost of the item. If using a dynamic Cost function,
-// it is possible that the item may be dropped and not cached rather than evicting other
-// higher value items.
+// maximum size, it may evict other items to make room for the new item. If the Config
+// defines a Cost function it determines the cost of the item, otherwise the cost is the
+// size of the key and value in bytes (see httpcache.EntrySize) so that MaxCost is a
+// memory budget. If using a dynamic Cost function, it is possible that the item may be
+// dropped and not cached rather than evicting other higher value items.
 //
 // Be careful when modifying the value byte slice after calling Put, calling `append`
 // may update the underlying array pointer which will not be reflected in the cache.
+// Wrap the cache with httpcache.CopyValues if callers may modify stored values.
 func (c *Cache) Put(key string, value []byte) {
-	c.cache.Set(key, value, 0)
+	var cost int64
+	if c.cost != nil {
+		cost = c.cost(key, value)
+	}
+	c.cache.Set(key, value, cost)
 }
 
 // Del deletes the key-value item from the cache if it exists.
@@ -71,6 +80,14 @@ func (c *Cache) Del(key string) {
 	c.cache.Del(key)
 }
 
+// Size returns the total cost of the items in the cache, which is their size in bytes
+// unless the Config defines a Cost function. Writes are applied asynchronously, so
+// items that have just been put may not be included until Wait returns. Implements
+// httpcache.Sizer.
+func (c *Cache) Size() (int64, error) {
+	return c.cache.MaxCost() - c.cache.RemainingCost(), nil
+}
+
 // Close stops all goroutines and closes all channels.
 // Implements io.Closer.
 func (c *Cache) Close() error {
@@ -78,6 +95,23 @@ func (c *Cache) Close() error {
 	return nil
 }
 
+// Flush blocks until all buffered writes have been applied or the context is done.
+// Implements httpcache.Flusher.
+func (c *Cache) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.cache.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Wait blocks until all buffered writes have been applied.
 // This ensures a call to Put() will be visible to future calls to Get().
 func (c *Cache) Wait() {
@@ -1,11 +1,15 @@
 package ristretto_test
 
 import (
+	"context"
 	"math/rand/v2"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
 	"go.rtnl.ai/httpcache/ristretto"
 )
 
@@ -30,6 +34,45 @@ func TestRistrettoCache(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestRistrettoFlush(t *testing.T) {
+	cache, err := ristretto.New(&ristretto.Config{NumCounters: 1e4, MaxCost: 1 << 20, BufferItems: 64})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Put("foo", []byte("bar"))
+	require.NoError(t, httpcache.FlushCache(context.Background(), cache))
+
+	val, ok := cache.Get("foo")
+	require.True(t, ok)
+	require.Equal(t, []byte("bar"), val)
+}
+
+func TestRistrettoSize(t *testing.T) {
+	cache, err := ristretto.New(&ristretto.Config{NumCounters: 1e4, MaxCost: 1 << 20, BufferItems: 64, IgnoreInternalCost: true})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	size, err := cache.Size()
+	require.NoError(t, err)
+	require.Zero(t, size)
+
+	// The cost of an item includes its key and overhead, not only its value.
+	key, val := "http://example.com/"+strings.Repeat("k", 256), []byte("bar")
+	cache.Put(key, val)
+	cache.Wait()
+
+	size, err = cache.Size()
+	require.NoError(t, err)
+	require.Equal(t, httpcache.EntrySize(key, val), size)
+
+	cache.Del(key)
+	cache.Wait()
+
+	size, err = cache.Size()
+	require.NoError(t, err)
+	require.Zero(t, size)
+}
+
 func TestRistrettoRace(t *testing.T) {
 	// Ensures no race conditions occur during concurrent access.
 	cache, err := ristretto.New(&ristretto.Config{
@@ -63,3 +106,67 @@ func TestRistrettoRace(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestRistrettoOnEvictEntry(t *testing.T) {
+	evicted := make(chan *httpcache.Metadata, 1)
+	cache, err := ristretto.New(&ristretto.Config{
+		NumCounters:  100,
+		MaxCost:      1024,
+		BufferItems:  64,
+		Cost:         func(value []byte) int64 { return int64(len(value)) },
+		OnEvictEntry: func(meta *httpcache.Metadata) { evicted <- meta },
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	entry := func(key string) []byte {
+		return []byte("HTTP/1.1 200 OK\r\nX-Httpcache-Key: " + key + "\r\nContent-Length: 600\r\n\r\n" + strings.Repeat("a", 600))
+	}
+
+	cache.Put("first", entry("first"))
+	cache.Wait()
+
+	// Increase the access frequency of the second key so that it is admitted.
+	for i := 0; i < 10; i++ {
+		cache.Get("second")
+	}
+
+	cache.Put("second", entry("second"))
+	cache.Wait()
+
+	select {
+	case meta := <-evicted:
+		require.Equal(t, "first", meta.Key)
+		require.Equal(t, 200, meta.StatusCode)
+	case <-time.After(time.Second):
+		t.Fatal("expected an eviction")
+	}
+}
+
+func TestRistrettoOnExitEntry(t *testing.T) {
+	exited := make(chan *httpcache.Metadata, 1)
+	cache, err := ristretto.New(&ristretto.Config{
+		NumCounters: 100,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+		OnExitEntry: func(meta *httpcache.Metadata) { exited <- meta },
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Put("index", []byte("vary-index:Accept\nkey"))
+	cache.Put("entry", []byte("HTTP/1.1 200 OK\r\nX-Httpcache-Key: entry\r\nContent-Length: 2\r\n\r\nok"))
+	cache.Wait()
+
+	// Values that are not stored responses are not reported.
+	cache.Del("index")
+	cache.Del("entry")
+
+	select {
+	case meta := <-exited:
+		require.Equal(t, "entry", meta.Key)
+		require.Equal(t, 200, meta.StatusCode)
+	case <-time.After(time.Second):
+		t.Fatal("expected the deleted entry to be reported")
+	}
+}
@@ -0,0 +1,126 @@
+package httpcache
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rule overrides the caching policy of the requests whose URL matches it, e.g. to cache
+// the responses for /static/* for a day or to never cache the responses for /auth/*.
+// Rules are evaluated in order after the host profiles and the first matching rule
+// applies. The zero value of each policy field uses the default behavior.
+type Rule struct {
+	// Host restricts the rule to requests for the host, matched like the keys of
+	// Profiles. If empty, the rule applies to requests for any host.
+	Host string
+
+	// Path is a glob that the path of the request URL must match: '*' matches any
+	// sequence of characters, including '/', and '?' matches any single character.
+	Path string
+
+	// Pattern is a regular expression that the request URL must match. If both Path and
+	// Pattern are set, both must match; if neither is set, every request matches.
+	Pattern *regexp.Regexp
+
+	// Bypass passes matching requests to the origin without serving them from or
+	// storing their responses in the cache.
+	Bypass bool
+
+	// ForceCache stores responses even if the origin forbids or restricts caching them
+	// with no-store, no-cache, private or must-revalidate, e.g. for static assets of an
+	// origin that does not send caching headers. It should be combined with TTL since
+	// responses without freshness information are otherwise stale when stored. Only
+	// responses whose status code is cacheable by default or allowed by StatusCodes are
+	// forced, never server errors, and shared caches still do not store responses to
+	// requests with an Authorization header.
+	ForceCache bool
+
+	// TTL overrides the freshness lifetime of matching responses, see Profile.TTL.
+	TTL time.Duration
+
+	// MaxBodySize overrides the maximum size of matching response bodies that are
+	// stored, see Profile.MaxBodySize.
+	MaxBodySize int64
+}
+
+// matches returns true if the rule applies to the request.
+func (r *Rule) matches(req *http.Request) bool {
+	if req.URL == nil {
+		return false
+	}
+
+	if r.Host != "" && !strings.EqualFold(r.Host, req.URL.Host) && !strings.EqualFold(r.Host, req.URL.Hostname()) {
+		return false
+	}
+
+	if r.Path != "" && !matchGlob(r.Path, req.URL.Path) {
+		return false
+	}
+	return r.Pattern == nil || r.Pattern.MatchString(req.URL.String())
+}
+
+// rule returns the first rule that applies to the request or nil if none applies.
+func (c *Config) rule(req *http.Request) *Rule {
+	for i := range c.Rules {
+		if c.Rules[i].matches(req) {
+			return &c.Rules[i]
+		}
+	}
+	return nil
+}
+
+// applyRule overrides the policy with the rule.
+func (p *policy) applyRule(rule *Rule) {
+	p.force = rule.ForceCache
+	if rule.TTL > 0 {
+		p.ttl = rule.TTL
+	}
+
+	if rule.MaxBodySize > 0 {
+		p.maxBodySize = rule.MaxBodySize
+	}
+}
+
+// bypassed returns true if a rule requires the request to bypass the cache.
+func (t *Transport) bypassed(req *http.Request) bool {
+	config := t.currentConfig()
+	if len(config.Rules) == 0 {
+		return false
+	}
+
+	rule := config.rule(req)
+	return rule != nil && rule.Bypass
+}
+
+// matchGlob returns true if the name matches the glob pattern, where '*' matches any
+// sequence of characters and '?' matches any single character.
+func matchGlob(pattern, name string) bool {
+	// The last '*' is backtracked to when the remainder of the pattern does not match.
+	var (
+		p, n        int
+		star, match = -1, 0
+	)
+
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, n
+			p++
+		case star >= 0:
+			match++
+			p, n = star+1, match
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
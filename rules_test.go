@@ -0,0 +1,140 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"/static/*", "/static/app.js", true},
+		{"/static/*", "/static/css/app.css", true},
+		{"/static/*", "/static/", true},
+		{"/static/*", "/static", false},
+		{"/static/*", "/api/static/app.js", false},
+		{"*.js", "/static/app.js", true},
+		{"*.js", "/static/app.json", false},
+		{"/v?/users", "/v1/users", true},
+		{"/v?/users", "/v10/users", false},
+		{"/api/*/comments", "/api/posts/42/comments", true},
+		{"/api/*/comments", "/api/posts/42/likes", false},
+		{"*", "", true},
+		{"", "", true},
+		{"", "/", false},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.match, httpcache.MatchGlob(tc.pattern, tc.name), "Test Case: %q %q", tc.pattern, tc.name)
+	}
+}
+
+func TestTransportRules(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		switch {
+		case r.URL.Path == "/static/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(r.URL.Path, "/static/"):
+			w.Header().Set("Cache-Control", "no-store")
+		case strings.HasPrefix(r.URL.Path, "/large/"):
+			w.Header().Set("Cache-Control", "max-age=3600")
+			fmt.Fprint(w, strings.Repeat("x", 64))
+		case r.URL.Path == "/private":
+			w.Header().Set("Cache-Control", "private, no-cache, max-age=3600")
+		default:
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	u, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	cache := &httpcache.InMemoryCache{}
+	transport := &httpcache.Transport{
+		Cache:        cache,
+		Clock:        clock,
+		StatusHeader: httpcache.DefaultStatusHeader,
+		Rules: []httpcache.Rule{
+			{Path: "/auth/*", Bypass: true},
+			{Path: "/static/*", ForceCache: true, TTL: 24 * time.Hour},
+			{Path: "/large/*", MaxBodySize: 32},
+			{Pattern: regexp.MustCompile(`[?&]nocache=1`), Bypass: true},
+			{Host: "localhost", Path: "/private", ForceCache: true},
+			{Host: u.Hostname(), Path: "/private", Bypass: true},
+		},
+	}
+	client := transport.Client()
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/api/users", true},
+		{"/auth/login", false},
+		{"/static/app.js", true},
+		{"/static/error", false},
+		{"/large/file", false},
+		{"/api/users?nocache=1", false},
+		{"/private", false},
+	}
+
+	for _, tc := range tests {
+		before := origin.Requests()
+		Get(t, client, origin.URL+tc.path)
+		Get(t, client, origin.URL+tc.path)
+		cached := origin.Requests()-before == 1
+		require.Equal(t, tc.cached, cached, "Test Case: %q", tc.path)
+	}
+
+	t.Run("ServerError", func(t *testing.T) {
+		_, ok := cache.Get(origin.URL + "/static/error")
+		require.False(t, ok, "expected server errors not to be forced into the cache")
+	})
+
+	t.Run("Bypass", func(t *testing.T) {
+		rep, _ := Get(t, client, origin.URL+"/auth/login")
+		require.Equal(t, string(httpcache.CacheBypass), rep.Header.Get(httpcache.DefaultStatusHeader))
+	})
+
+	t.Run("Host", func(t *testing.T) {
+		other := strings.Replace(origin.URL, u.Hostname(), "localhost", 1)
+		before := origin.Requests()
+		Get(t, client, other+"/private")
+		Get(t, client, other+"/private")
+		require.Equal(t, before+1, origin.Requests(), "expected the rule for localhost to apply")
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		clock.Advance(12 * time.Hour)
+		before := origin.Requests()
+		Get(t, client, origin.URL+"/static/app.js")
+		require.Equal(t, before, origin.Requests(), "expected static response to be fresh for a day")
+
+		clock.Advance(13 * time.Hour)
+		Get(t, client, origin.URL+"/static/app.js")
+		require.Equal(t, before+1, origin.Requests(), "expected static response to expire after a day")
+	})
+
+	t.Run("UpdateConfig", func(t *testing.T) {
+		transport.UpdateConfig(httpcache.Config{
+			Rules: []httpcache.Rule{{Path: "/api/*", Bypass: true}},
+		})
+
+		before := origin.Requests()
+		Get(t, client, origin.URL+"/api/users")
+		require.Equal(t, before+1, origin.Requests(), "expected updated rules to apply")
+	})
+}
@@ -0,0 +1,151 @@
+package httpcache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BackendKey is the attribute that identifies the cache backend in log records so that
+// the failures of each backend are sampled separately.
+const BackendKey = "backend"
+
+// SampledHandler is a slog.Handler that limits the number of warn and debug records
+// written by the wrapped handler, so that a degraded backend failing on every request
+// does not flood the application logs. At most Limit records with the same message and
+// backend are written per interval; info and error records are always written. The
+// first record written after records were dropped has a suppressed attribute with the
+// number of records that were dropped.
+type SampledHandler struct {
+	handler  slog.Handler
+	limit    int
+	interval time.Duration
+	backend  string
+	samples  *samples
+}
+
+var _ slog.Handler = (*SampledHandler)(nil)
+
+// samples counts the records written for each message and backend in the current
+// interval; it is shared by the handlers derived with WithAttrs and WithGroup.
+type samples struct {
+	mu      sync.Mutex
+	windows map[sampleKey]*sampleWindow
+}
+
+type sampleKey struct {
+	message string
+	backend string
+}
+
+type sampleWindow struct {
+	start      time.Time
+	written    int
+	suppressed int
+}
+
+// Sample returns a handler that writes at most limit warn and debug records with the
+// same message and backend per interval to the handler, e.g. to log at most 10 failures
+// per minute for each backend:
+//
+//	httpcache.SetLogger(slog.New(httpcache.Sample(handler, 10, time.Minute)))
+func Sample(handler slog.Handler, limit int, interval time.Duration) *SampledHandler {
+	return &SampledHandler{
+		handler:  handler,
+		limit:    limit,
+		interval: interval,
+		samples:  &samples{windows: make(map[sampleKey]*sampleWindow)},
+	}
+}
+
+// sampled returns true for the warn and debug levels, including the levels between warn
+// and error and below debug.
+func sampled(level slog.Level) bool {
+	return level < slog.LevelInfo || (level >= slog.LevelWarn && level < slog.LevelError)
+}
+
+// Enabled reports whether the wrapped handler handles records at the level.
+func (h *SampledHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle writes the record to the wrapped handler unless the limit for its message and
+// backend has been reached in the current interval. Info and error records are always
+// written.
+func (h *SampledHandler) Handle(ctx context.Context, record slog.Record) error {
+	if !sampled(record.Level) || h.limit <= 0 || h.interval <= 0 {
+		return h.handler.Handle(ctx, record)
+	}
+
+	backend := h.backend
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == BackendKey {
+			backend = attr.Value.String()
+			return false
+		}
+		return true
+	})
+
+	now := record.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	suppressed, ok := h.samples.allow(sampleKey{record.Message, backend}, now, h.limit, h.interval)
+	if !ok {
+		return nil
+	}
+
+	if suppressed > 0 {
+		record = record.Clone()
+		record.AddAttrs(slog.Int("suppressed", suppressed))
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a sampled handler that wraps the handler with the attributes; if
+// the attributes identify the backend, its records are sampled separately.
+func (h *SampledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithAttrs(attrs)
+	for _, attr := range attrs {
+		if attr.Key == BackendKey {
+			clone.backend = attr.Value.String()
+		}
+	}
+	return &clone
+}
+
+// WithGroup returns a sampled handler that wraps the handler with the group.
+func (h *SampledHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithGroup(name)
+	return &clone
+}
+
+// allow returns true if a record may be written for the key, and the number of records
+// that were dropped since the last record that was written.
+func (s *samples) allow(key sampleKey, now time.Time, limit int, interval time.Duration) (suppressed int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window, found := s.windows[key]
+	if !found {
+		window = &sampleWindow{start: now}
+		s.windows[key] = window
+	}
+
+	if now.Sub(window.start) >= interval {
+		window.start, window.written = now, 0
+	}
+
+	if window.written >= limit {
+		window.suppressed++
+		return 0, false
+	}
+
+	window.written++
+	suppressed, window.suppressed = window.suppressed, 0
+	return suppressed, true
+}
@@ -0,0 +1,68 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestSample(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := httpcache.Sample(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), 2, time.Minute)
+	leveldb := handler.WithAttrs([]slog.Attr{slog.String(httpcache.BackendKey, "leveldb")})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	log := func(h slog.Handler, offset time.Duration, level slog.Level, msg string, attrs ...slog.Attr) {
+		record := slog.NewRecord(start.Add(offset), level, msg, 0)
+		record.AddAttrs(attrs...)
+		require.NoError(t, h.Handle(context.Background(), record))
+	}
+
+	for i := 0; i < 5; i++ {
+		log(leveldb, time.Duration(i)*time.Second, slog.LevelWarn, "failed to read")
+		log(handler, time.Duration(i)*time.Second, slog.LevelWarn, "failed to read", slog.String(httpcache.BackendKey, "redis"))
+		log(handler, time.Duration(i)*time.Second, slog.LevelDebug, "removing expired cache entry")
+		log(handler, time.Duration(i)*time.Second, slog.LevelError, "failed to read")
+		log(handler, time.Duration(i)*time.Second, slog.LevelInfo, "flushed cache")
+	}
+	log(leveldb, time.Minute, slog.LevelWarn, "failed to read")
+
+	type line struct {
+		Msg        string `json:"msg"`
+		Level      string `json:"level"`
+		Backend    string `json:"backend"`
+		Suppressed int    `json:"suppressed"`
+	}
+
+	counts := make(map[line]int)
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var l line
+		require.NoError(t, json.Unmarshal([]byte(raw), &l))
+		counts[l]++
+	}
+
+	require.Equal(t, map[line]int{
+		{Msg: "failed to read", Level: "WARN", Backend: "leveldb"}:                2,
+		{Msg: "failed to read", Level: "WARN", Backend: "leveldb", Suppressed: 3}: 1,
+		{Msg: "failed to read", Level: "WARN", Backend: "redis"}:                  2,
+		{Msg: "removing expired cache entry", Level: "DEBUG"}:                     2,
+		{Msg: "failed to read", Level: "ERROR"}:                                   5,
+		{Msg: "flushed cache", Level: "INFO"}:                                     5,
+	}, counts)
+}
+
+func TestSampleDisabled(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(httpcache.Sample(slog.NewTextHandler(buf, nil), 0, time.Minute).WithGroup("cache"))
+	for i := 0; i < 5; i++ {
+		logger.Warn("failed to read")
+	}
+	require.Equal(t, 5, strings.Count(buf.String(), "failed to read"))
+}
@@ -0,0 +1,108 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+)
+
+// ShadowStats count the comparisons of cache hits with the origin made in shadow mode.
+type ShadowStats struct {
+	// Compared is the number of cache hits that were compared with the origin.
+	Compared int64
+
+	// BodyDivergent is the number of compared hits whose body differed from the body of
+	// the origin response.
+	BodyDivergent int64
+
+	// ValidatorDivergent is the number of compared hits whose ETag or Last-Modified
+	// differed from the validators of the origin response.
+	ValidatorDivergent int64
+
+	// Errors is the number of shadow requests that failed, e.g. because the origin
+	// could not be reached or responded with a different status code.
+	Errors int64
+}
+
+// shadowCounters are the counters of ShadowStats.
+type shadowCounters struct {
+	compared           atomic.Int64
+	bodyDivergent      atomic.Int64
+	validatorDivergent atomic.Int64
+	errors             atomic.Int64
+}
+
+func (c *shadowCounters) snapshot() ShadowStats {
+	return ShadowStats{
+		Compared:           c.compared.Load(),
+		BodyDivergent:      c.bodyDivergent.Load(),
+		ValidatorDivergent: c.validatorDivergent.Load(),
+		Errors:             c.errors.Load(),
+	}
+}
+
+// sampleShadow returns true if the cache hit should be compared with the origin.
+func (t *Transport) sampleShadow() bool {
+	return t.ShadowRate > 0 && rand.Float64() < t.ShadowRate
+}
+
+// shadow reads the body of the stored response that is served for a cache hit and
+// starts a background request to the origin to compare it with. The body of the stored
+// response is replaced so that it can still be served. The comparison is skipped if
+// the maximum number of background tasks are running.
+func (t *Transport) shadow(key string, req *http.Request, cached *http.Response) {
+	body, err := io.ReadAll(cached.Body)
+	cached.Body.Close()
+	cached.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	header := cached.Header.Clone()
+	status := cached.StatusCode
+
+	// The shadow request must not be canceled when the caller's request completes.
+	bgreq := req.Clone(context.WithoutCancel(req.Context()))
+	bgreq.Body = nil
+	t.goBackground(req.Context(), "shadow:"+key, func() {
+		t.compareShadow(key, bgreq, status, header, body)
+	})
+}
+
+// compareShadow fetches the response for the request from the origin and compares it
+// with the stored response, logging and counting any divergence. The origin response is
+// not stored.
+func (t *Transport) compareShadow(key string, req *http.Request, status int, header http.Header, body []byte) {
+	rep, err := t.forward(req)
+	if err != nil {
+		GetLogger().Debug("shadow request failed", slog.String("key", key), slog.Any("error", err))
+		t.shadows.errors.Add(1)
+		return
+	}
+	defer rep.Body.Close()
+
+	var origin []byte
+	if origin, err = io.ReadAll(rep.Body); err != nil || rep.StatusCode != status {
+		GetLogger().Debug("shadow request failed", slog.String("key", key), slog.Int("status", rep.StatusCode), slog.Any("error", err))
+		t.shadows.errors.Add(1)
+		return
+	}
+
+	t.shadows.compared.Add(1)
+	if !bytes.Equal(body, origin) {
+		GetLogger().Info("cached body diverges from origin", slog.String("key", key), slog.Int("cached", len(body)), slog.Int("origin", len(origin)))
+		t.shadows.bodyDivergent.Add(1)
+	}
+
+	for _, name := range []string{"ETag", "Last-Modified"} {
+		if header.Get(name) != rep.Header.Get(name) {
+			GetLogger().Info("cached validators diverge from origin", slog.String("key", key), slog.String("cached", header.Get(name)), slog.String("origin", rep.Header.Get(name)))
+			t.shadows.validatorDivergent.Add(1)
+			break
+		}
+	}
+}
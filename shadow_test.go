@@ -0,0 +1,77 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportShadow(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", etag.Load().(string))
+		if r.URL.Path == "/static" {
+			fmt.Fprint(w, "static")
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.ShadowRate = 1
+	client := transport.Client()
+
+	// Hits that match the origin are compared without divergence.
+	Get(t, client, origin.URL+"/static")
+	_, body := Get(t, client, origin.URL+"/static")
+	require.Equal(t, "static", body)
+	require.Eventually(t, func() bool {
+		return transport.Stats().Shadow == httpcache.ShadowStats{Compared: 1}
+	}, time.Second, time.Millisecond)
+
+	// The cached response is served even though the origin body has changed.
+	Get(t, client, origin.URL)
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 3", body)
+	require.Eventually(t, func() bool {
+		return transport.Stats().Shadow == httpcache.ShadowStats{Compared: 2, BodyDivergent: 1}
+	}, time.Second, time.Millisecond)
+
+	etag.Store(`"v2"`)
+	_, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 3", body)
+	require.Eventually(t, func() bool {
+		return transport.Stats().Shadow == httpcache.ShadowStats{Compared: 3, BodyDivergent: 2, ValidatorDivergent: 1}
+	}, time.Second, time.Millisecond)
+
+	// Shadow requests that fail are counted as errors.
+	origin.Close()
+	Get(t, client, origin.URL)
+	require.Eventually(t, func() bool {
+		return transport.Stats().Shadow.Errors == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestTransportShadowDisabled(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	client := transport.Client()
+	Get(t, client, origin.URL)
+	Get(t, client, origin.URL)
+
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int64(1), origin.Requests())
+	require.Zero(t, transport.Stats().Shadow)
+}
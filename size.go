@@ -0,0 +1,51 @@
+package httpcache
+
+import "log/slog"
+
+// EntryOverhead is the estimated number of bytes that an in-memory cache uses for each
+// entry in addition to its key and value, e.g. for the string and slice headers of the
+// key and value and the bookkeeping of the map or index that holds them.
+const EntryOverhead = 64
+
+// Sizer is implemented by caches that report the number of bytes their entries occupy
+// in memory or on disk, including their keys and per-entry overhead, so that the size
+// reported by Stats matches the actual usage of the cache.
+type Sizer interface {
+	Size() (int64, error)
+}
+
+// EntrySize returns the number of bytes that an in-memory cache accounts for an entry:
+// the length of the key and of the value, which contains the serialized response
+// including its header and the metadata stored with it, and EntryOverhead. Caches
+// should use it rather than the length of the response body when enforcing a size
+// budget so that many small responses with long keys do not exceed the budget.
+func EntrySize(key string, val []byte) int64 {
+	return int64(len(key)+len(val)) + EntryOverhead
+}
+
+// CacheSize returns the size of the cache if it implements Sizer and false otherwise.
+// Wrappers of other caches should implement Sizer by calling CacheSize with the
+// wrapped cache.
+func CacheSize(cache Cache) (int64, bool, error) {
+	sizer, ok := cache.(Sizer)
+	if !ok {
+		return 0, false, nil
+	}
+
+	size, err := sizer.Size()
+	return size, true, err
+}
+
+// cacheBytes returns the size of the cache for Stats, or zero if it is unknown.
+func (t *Transport) cacheBytes() int64 {
+	if t.Cache == nil {
+		return 0
+	}
+
+	size, _, err := CacheSize(t.Cache)
+	if err != nil {
+		GetLogger().Warn("could not determine cache size", slog.Any("error", err))
+		return 0
+	}
+	return size
+}
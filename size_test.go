@@ -0,0 +1,72 @@
+package httpcache_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestEntrySize(t *testing.T) {
+	require.Equal(t, int64(httpcache.EntryOverhead), httpcache.EntrySize("", nil))
+	require.Equal(t, int64(3+5+httpcache.EntryOverhead), httpcache.EntrySize("key", []byte("value")))
+}
+
+func TestInMemoryCacheSize(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	requireSize := func(expected int64) {
+		t.Helper()
+		size, err := cache.Size()
+		require.NoError(t, err)
+		require.Equal(t, expected, size)
+	}
+
+	requireSize(0)
+
+	long := strings.Repeat("k", 512)
+	cache.Put("a", []byte("1234"))
+	cache.Put(long, []byte("5"))
+	requireSize(httpcache.EntrySize("a", []byte("1234")) + httpcache.EntrySize(long, []byte("5")))
+
+	// Replacing a value only accounts for the new value.
+	cache.Put("a", []byte("12"))
+	requireSize(httpcache.EntrySize("a", []byte("12")) + httpcache.EntrySize(long, []byte("5")))
+
+	cache.Del("a")
+	cache.Del("missing")
+	requireSize(httpcache.EntrySize(long, []byte("5")))
+
+	cache.Put("b", []byte("67"))
+	require.Equal(t, 1, cache.EvictOldest(1))
+	requireSize(httpcache.EntrySize("b", []byte("67")))
+
+	require.Equal(t, 1, cache.EvictOldest(10))
+	requireSize(0)
+}
+
+func TestCacheSize(t *testing.T) {
+	cache := &httpcache.InMemoryCache{}
+	cache.Put("key", []byte("value"))
+	expected := httpcache.EntrySize("key", []byte("value"))
+
+	tests := []struct {
+		name  string
+		cache httpcache.Cache
+		size  int64
+		ok    bool
+	}{
+		{"memory", cache, expected, true},
+		{"copy", httpcache.CopyValues(cache), expected, true},
+		{"overlay", httpcache.Overlay(cache, &httpcache.InMemoryCache{}), expected, true},
+		{"escape", httpcache.EscapeKeys(&httpcache.InMemoryCache{}), 0, true},
+		{"unsized", nopCache{}, 0, false},
+	}
+
+	for _, tc := range tests {
+		size, ok, err := httpcache.CacheSize(tc.cache)
+		require.NoError(t, err, "Test Case: %q", tc.name)
+		require.Equal(t, tc.ok, ok, "Test Case: %q", tc.name)
+		require.Equal(t, tc.size, size, "Test Case: %q", tc.name)
+	}
+}
@@ -0,0 +1,154 @@
+package httpcache
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// ExportSnapshot writes every entry of the cache to a file in the directory, which is
+// created if necessary, so that the entries can be embedded in a binary with go:embed
+// and read with OpenSnapshot. Files are named with EscapeKey so that any key can be
+// used as a file name. The cache must implement KeyLister otherwise ErrKeysUnsupported
+// is returned.
+func ExportSnapshot(cache Cache, dir string) (err error) {
+	lister, ok := cache.(KeyLister)
+	if !ok {
+		return ErrKeysUnsupported
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	writer := &snapshotWriter{dir: dir}
+	snapshot := EscapeKeys(writer)
+	for _, key := range lister.Keys() {
+		if val, ok := cache.Get(key); ok {
+			snapshot.Put(key, val)
+			if writer.err != nil {
+				return writer.err
+			}
+		}
+	}
+	return nil
+}
+
+// OpenSnapshot returns a read-only cache of the entries written by ExportSnapshot to
+// the directory of the file system, e.g. an embed.FS, so that command line tools can
+// ship with responses such as API schemas or discovery documents and work on first run
+// without network access:
+//
+//	//go:embed snapshot
+//	var snapshotFS embed.FS
+//
+//	snapshot, err := httpcache.OpenSnapshot(snapshotFS, "snapshot")
+//	client := httpcache.NewTransport(httpcache.Overlay(cache, snapshot)).Client()
+//
+// Put and Del do nothing. The returned cache implements KeyLister.
+func OpenSnapshot(fsys fs.FS, dir string) (_ Cache, err error) {
+	if fsys, err = fs.Sub(fsys, dir); err != nil {
+		return nil, err
+	}
+
+	if _, err = fs.ReadDir(fsys, "."); err != nil {
+		return nil, err
+	}
+	return EscapeKeys(snapshotFS{fsys: fsys}), nil
+}
+
+// Overlay returns a cache that stores entries in the cache and serves the entries of
+// the snapshot, e.g. one returned by OpenSnapshot, that are not stored in the cache.
+// Entries deleted from the cache are served from the snapshot again, so stale entries
+// of the snapshot are revalidated rather than removed by the Transport.
+func Overlay(cache, snapshot Cache) Cache {
+	return &overlayCache{cache: cache, snapshot: snapshot}
+}
+
+// snapshotWriter writes entries to files in a directory; the first error is recorded.
+type snapshotWriter struct {
+	dir string
+	err error
+}
+
+func (w *snapshotWriter) Get(string) ([]byte, bool) {
+	return nil, false
+}
+
+func (w *snapshotWriter) Put(name string, val []byte) {
+	if err := os.WriteFile(filepath.Join(w.dir, name), val, 0o644); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *snapshotWriter) Del(string) {}
+
+// snapshotFS is a read-only cache of the files in a file system named by their
+// escaped keys.
+type snapshotFS struct {
+	fsys fs.FS
+}
+
+func (s snapshotFS) Get(name string) ([]byte, bool) {
+	if !fs.ValidPath(name) {
+		return nil, false
+	}
+
+	val, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+func (s snapshotFS) Put(string, []byte) {}
+
+func (s snapshotFS) Del(string) {}
+
+func (s snapshotFS) Keys() []string {
+	entries, err := fs.ReadDir(s.fsys, ".")
+	if err != nil {
+		GetLogger().Warn("could not list snapshot entries", slog.Any("error", err))
+		return nil
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys
+}
+
+type overlayCache struct {
+	cache    Cache
+	snapshot Cache
+}
+
+func (c *overlayCache) Get(key string) ([]byte, bool) {
+	if val, ok := c.cache.Get(key); ok {
+		return val, true
+	}
+	return c.snapshot.Get(key)
+}
+
+func (c *overlayCache) Put(key string, val []byte) {
+	c.cache.Put(key, val)
+}
+
+func (c *overlayCache) Del(key string) {
+	c.cache.Del(key)
+}
+
+func (c *overlayCache) Flush(ctx context.Context) error {
+	return FlushCache(ctx, c.cache)
+}
+
+// Size returns the size of the cache; the entries of the snapshot are not included.
+func (c *overlayCache) Size() (int64, error) {
+	size, _, err := CacheSize(c.cache)
+	return size, err
+}
@@ -0,0 +1,99 @@
+package httpcache_test
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+//go:embed testdata/snapshot
+var snapshotFS embed.FS
+
+func TestSnapshot(t *testing.T) {
+	long := "http://example.com/" + strings.Repeat("a", 300)
+	cache := &httpcache.InMemoryCache{}
+	cache.Put("http://example.com/a", []byte("entry a"))
+	cache.Put(long, []byte("entry long"))
+
+	dir := t.TempDir() + "/snapshot"
+	require.NoError(t, httpcache.ExportSnapshot(cache, dir))
+
+	snapshot, err := httpcache.OpenSnapshot(os.DirFS(dir), ".")
+	require.NoError(t, err)
+
+	val, ok := snapshot.Get("http://example.com/a")
+	require.True(t, ok)
+	require.Equal(t, []byte("entry a"), val)
+
+	val, ok = snapshot.Get(long)
+	require.True(t, ok)
+	require.Equal(t, []byte("entry long"), val)
+
+	_, ok = snapshot.Get("http://example.com/missing")
+	require.False(t, ok)
+
+	require.Implements(t, (*httpcache.KeyLister)(nil), snapshot)
+	require.ElementsMatch(t, []string{"http://example.com/a", long}, snapshot.(httpcache.KeyLister).Keys())
+
+	// The snapshot is read-only.
+	snapshot.Put("http://example.com/b", []byte("entry b"))
+	snapshot.Del("http://example.com/a")
+	_, ok = snapshot.Get("http://example.com/b")
+	require.False(t, ok)
+	_, ok = snapshot.Get("http://example.com/a")
+	require.True(t, ok)
+
+	require.ErrorIs(t, httpcache.ExportSnapshot(nopCache{}, dir), httpcache.ErrKeysUnsupported)
+
+	_, err = httpcache.OpenSnapshot(os.DirFS(dir), "missing")
+	require.Error(t, err)
+}
+
+func TestEmbeddedSnapshot(t *testing.T) {
+	snapshot, err := httpcache.OpenSnapshot(snapshotFS, "testdata/snapshot")
+	require.NoError(t, err)
+
+	rep, body := Get(t, httpcache.NewOfflineClient(snapshot), "http://api.example.com/openapi.json")
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "application/json", rep.Header.Get("Content-Type"))
+	require.Contains(t, body, `"openapi":"3.1.0"`)
+}
+
+func TestOverlay(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// Record a snapshot of a response from the origin.
+	recorded := &httpcache.InMemoryCache{}
+	Get(t, httpcache.NewTransport(recorded).Client(), origin.URL)
+
+	dir := t.TempDir()
+	require.NoError(t, httpcache.ExportSnapshot(recorded, dir))
+	snapshot, err := httpcache.OpenSnapshot(os.DirFS(dir), ".")
+	require.NoError(t, err)
+
+	cache := &httpcache.InMemoryCache{}
+	client := httpcache.NewTransport(httpcache.Overlay(cache, snapshot)).Client()
+
+	// The snapshot is served without contacting the origin.
+	_, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, int64(1), origin.Requests())
+
+	// Other responses are stored in the cache.
+	_, body = Get(t, client, origin.URL+"/other")
+	require.Equal(t, "response 2", body)
+	require.Len(t, cache.Keys(), 1)
+
+	_, body = Get(t, client, origin.URL+"/other")
+	require.Equal(t, "response 2", body)
+	require.Equal(t, int64(2), origin.Requests())
+}
@@ -0,0 +1,247 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats are gauges that describe the current state of a Transport.
+type Stats struct {
+	// BufferedBytes is the number of response body bytes that are buffered in memory
+	// until the response has been completely read and can be stored in the cache. A
+	// growing value indicates that callers are slow to read responses or that the
+	// cache backend is slow to store them.
+	BufferedBytes int64
+
+	// BufferedResponses is the number of responses whose bodies are being buffered.
+	BufferedResponses int64
+
+	// FailOpen counts the events, by reason, where the Transport continued without the
+	// cache after an error, e.g. by fetching a response from the origin because the
+	// stored entry was corrupt. Only reasons that have occurred are included.
+	FailOpen map[FailOpenReason]int64
+
+	// Shadow counts the comparisons of cache hits with the origin in shadow mode.
+	Shadow ShadowStats
+
+	// CacheBytes is the number of bytes the entries of the cache occupy, including
+	// their keys and per-entry overhead (see EntrySize), if the cache implements Sizer.
+	CacheBytes int64
+
+	// Tenants counts the hits, misses and stored bytes of each tenant identified by the
+	// TenantHeader of the requests. It is nil if TenantHeader is not set.
+	Tenants map[string]TenantStats
+}
+
+// Stats returns the current values of the gauges and counters of the Transport.
+func (t *Transport) Stats() Stats {
+	return Stats{
+		BufferedBytes:     t.buffered.bytes.Load(),
+		BufferedResponses: t.buffered.responses.Load(),
+		FailOpen:          t.failOpen.snapshot(),
+		Shadow:            t.shadows.snapshot(),
+		CacheBytes:        t.cacheBytes(),
+		Tenants:           t.tenants.snapshot(),
+	}
+}
+
+// FailOpenReason describes why the Transport continued without the cache.
+type FailOpenReason uint8
+
+const (
+	// FailOpenCorruptEntry is a stored entry that could not be parsed and was removed.
+	FailOpenCorruptEntry FailOpenReason = iota
+
+	// FailOpenSerialize is a response that could not be serialized to be stored.
+	FailOpenSerialize
+
+	// FailOpenTooLarge is a response body that exceeded the maximum body size and was
+	// not stored.
+	FailOpenTooLarge
+
+	// FailOpenRevalidation is a background revalidation that failed, leaving the stale
+	// response in the cache.
+	FailOpenRevalidation
+
+	// FailOpenBackpressure is background work that was dropped because too many
+	// background tasks were running.
+	FailOpenBackpressure
+
+	numFailOpenReasons
+)
+
+var failOpenLabels = [numFailOpenReasons]string{
+	FailOpenCorruptEntry: "corrupt_entry",
+	FailOpenSerialize:    "serialize",
+	FailOpenTooLarge:     "too_large",
+	FailOpenRevalidation: "revalidation",
+	FailOpenBackpressure: "backpressure",
+}
+
+// String returns the label of the reason, e.g. for use as a metrics label.
+func (r FailOpenReason) String() string {
+	if r < numFailOpenReasons {
+		return failOpenLabels[r]
+	}
+	return "unknown"
+}
+
+// failOpenCounters counts fail-open events by reason.
+type failOpenCounters [numFailOpenReasons]atomic.Int64
+
+func (c *failOpenCounters) add(reason FailOpenReason) {
+	c[reason].Add(1)
+}
+
+func (c *failOpenCounters) snapshot() map[FailOpenReason]int64 {
+	var counts map[FailOpenReason]int64
+	for reason := range numFailOpenReasons {
+		if n := c[reason].Load(); n > 0 {
+			if counts == nil {
+				counts = make(map[FailOpenReason]int64)
+			}
+			counts[reason] = n
+		}
+	}
+	return counts
+}
+
+// bufferGauge tracks the response bodies being buffered before they are stored. The
+// methods of a nil gauge do nothing.
+type bufferGauge struct {
+	bytes     atomic.Int64
+	responses atomic.Int64
+}
+
+func (g *bufferGauge) open() {
+	if g != nil {
+		g.responses.Add(1)
+	}
+}
+
+func (g *bufferGauge) add(n int) {
+	if g != nil {
+		g.bytes.Add(int64(n))
+	}
+}
+
+func (g *bufferGauge) release(n int) {
+	if g != nil {
+		g.bytes.Add(-int64(n))
+		g.responses.Add(-1)
+	}
+}
+
+// maxUsageKeys bounds the number of tenants or hosts that are counted separately so
+// that requests with arbitrary header values or hosts cannot grow the counters without
+// limit. Further tenants or hosts are counted under the empty name.
+const maxUsageKeys = 1024
+
+// TenantStats counts how the requests of a tenant were served.
+type TenantStats struct {
+	// Hits is the number of requests served from the cache, including stale responses
+	// and responses that were revalidated with the origin.
+	Hits int64
+
+	// Misses is the number of requests for which the response was fetched from the
+	// origin because no usable response was stored.
+	Misses int64
+
+	// Bytes is the number of response body bytes stored in the cache.
+	Bytes int64
+}
+
+// usageCounters counts the hits, misses and stored bytes by name, e.g. by tenant.
+type usageCounters struct {
+	mu     sync.Mutex
+	counts map[string]*usageCounter
+}
+
+type usageCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	bytes  atomic.Int64
+}
+
+// get returns the counter of the name, creating it if needed.
+func (c *usageCounters) get(name string) *usageCounter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]*usageCounter)
+	}
+
+	counter, ok := c.counts[name]
+	if !ok {
+		if len(c.counts) >= maxUsageKeys {
+			name = ""
+			if counter, ok = c.counts[name]; ok {
+				return counter
+			}
+		}
+		counter = &usageCounter{}
+		c.counts[name] = counter
+	}
+	return counter
+}
+
+// tenant returns the counter of the tenant of the request, or nil if TenantHeader is
+// not set.
+func (t *Transport) tenant(req *http.Request) *usageCounter {
+	if t.TenantHeader == "" || req == nil {
+		return nil
+	}
+	return t.tenants.get(normalize(req.Header.Get(t.TenantHeader)))
+}
+
+// host returns the counter of the host of the request, or nil if TrackUsage is not set.
+func (t *Transport) host(req *http.Request) *usageCounter {
+	if !t.TrackUsage || req == nil || req.URL == nil {
+		return nil
+	}
+	return t.hosts.get(strings.ToLower(req.URL.Host))
+}
+
+// observe counts the request as a hit or miss according to the status with which the
+// response was served.
+func (c *usageCounter) observe(status CacheStatus) {
+	if c == nil {
+		return
+	}
+
+	switch status {
+	case CacheHit, CacheStale, CacheRevalidated:
+		c.hits.Add(1)
+	case CacheMiss:
+		c.misses.Add(1)
+	}
+}
+
+// stored counts the body bytes of a stored response.
+func (c *usageCounter) stored(n int) {
+	if c != nil {
+		c.bytes.Add(int64(n))
+	}
+}
+
+func (c *usageCounters) snapshot() map[string]TenantStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		return nil
+	}
+
+	stats := make(map[string]TenantStats, len(c.counts))
+	for name, counter := range c.counts {
+		stats[name] = TenantStats{
+			Hits:   counter.hits.Load(),
+			Misses: counter.misses.Load(),
+			Bytes:  counter.bytes.Load(),
+		}
+	}
+	return stats
+}
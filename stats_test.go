@@ -0,0 +1,112 @@
+package httpcache_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportStats(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(strings.Repeat("x", 1024)))
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	transport := httpcache.NewTransport(cache)
+	client := transport.Client()
+	require.Equal(t, httpcache.Stats{}, transport.Stats())
+
+	// Only the completely read response is stored; its key and metadata are accounted.
+	var size int64
+	for _, path := range []string{"/complete", "/closed"} {
+		rep, err := client.Get(origin.URL + path)
+		require.NoError(t, err)
+
+		buf := make([]byte, 100)
+		_, err = io.ReadFull(rep.Body, buf)
+		require.NoError(t, err)
+		require.Equal(t, httpcache.Stats{BufferedBytes: 100, BufferedResponses: 1, CacheBytes: size}, transport.Stats())
+
+		if path == "/complete" {
+			_, err = io.Copy(io.Discard, rep.Body)
+			require.NoError(t, err)
+		}
+
+		rep.Body.Close()
+		if path == "/complete" {
+			val, ok := cache.Get(origin.URL + path)
+			require.True(t, ok)
+			size = httpcache.EntrySize(origin.URL+path, val)
+			require.Greater(t, size, int64(1024+len(origin.URL+path)))
+		}
+		require.Equal(t, httpcache.Stats{CacheBytes: size}, transport.Stats(), "Test Case: %q", path)
+	}
+}
+
+func TestTransportFailOpenStats(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("x", 1024)))
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	cache.Put(origin.URL+"/corrupt", []byte("not a response"))
+
+	transport := &httpcache.Transport{
+		Cache:    cache,
+		Profiles: map[string]*httpcache.Profile{"127.0.0.1": {MaxBodySize: 512}},
+	}
+	client := transport.Client()
+
+	Get(t, client, origin.URL+"/corrupt")
+	Get(t, client, origin.URL+"/large")
+	Get(t, client, origin.URL+"/chunked")
+
+	// Every response, including the refetch of the corrupt entry, is too large.
+	expected := map[httpcache.FailOpenReason]int64{
+		httpcache.FailOpenCorruptEntry: 1,
+		httpcache.FailOpenTooLarge:     3,
+	}
+	require.Equal(t, expected, transport.Stats().FailOpen)
+}
+
+func TestFailOpenReasonString(t *testing.T) {
+	require.Equal(t, "corrupt_entry", httpcache.FailOpenCorruptEntry.String())
+	require.Equal(t, "backpressure", httpcache.FailOpenBackpressure.String())
+	require.Equal(t, "unknown", httpcache.FailOpenReason(255).String())
+}
+
+func TestTransportTenantStats(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithKeyHeaders("X-Tenant-ID"),
+		httpcache.WithTenantHeader("X-Tenant-ID"),
+	)
+	client := transport.Client()
+
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme")
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme")
+	Get(t, client, origin.URL, "X-Tenant-ID", "acme")
+	Get(t, client, origin.URL, "X-Tenant-ID", "globex")
+	Get(t, client, origin.URL)
+
+	expected := map[string]httpcache.TenantStats{
+		"acme":   {Hits: 2, Misses: 1, Bytes: 100},
+		"globex": {Misses: 1, Bytes: 100},
+		"":       {Misses: 1, Bytes: 100},
+	}
+	require.Equal(t, expected, transport.Stats().Tenants)
+	require.Equal(t, int64(3), origin.Requests())
+}
@@ -0,0 +1,57 @@
+package httpcache
+
+import "net/http"
+
+// DefaultStatusHeader is the conventional name of the header that reports how the cache
+// handled a request; see StatusHeader.
+const DefaultStatusHeader = "X-Cache-Status"
+
+// CacheStatus describes how the Transport handled a request.
+type CacheStatus string
+
+const (
+	// CacheHit is a fresh response served from the cache without contacting the origin.
+	CacheHit CacheStatus = "HIT"
+
+	// CacheMiss is a response fetched from the origin because no usable response was
+	// stored, or a response received from a concurrent fetch of the same miss.
+	CacheMiss CacheStatus = "MISS"
+
+	// CacheStale is a stale stored response served without revalidation, e.g. while
+	// it is revalidated in the background or because the origin failed.
+	CacheStale CacheStatus = "STALE"
+
+	// CacheRevalidated is a stored response served after the origin confirmed that it
+	// is still current with 304 Not Modified.
+	CacheRevalidated CacheStatus = "REVALIDATED"
+
+	// CacheBypass is a response to a request that the cache does not handle, e.g. an
+	// unsafe or no-store request, that was passed through to the origin.
+	CacheBypass CacheStatus = "BYPASS"
+)
+
+// annotate sets the StatusHeader of the response, if configured, to the status, invokes
+// the hook for the status and returns the response.
+func (t *Transport) annotate(rep *http.Response, status CacheStatus) *http.Response {
+	if rep == nil {
+		return nil
+	}
+
+	if t.StatusHeader != "" {
+		if rep.Header == nil {
+			rep.Header = make(http.Header)
+		}
+		rep.Header.Set(t.StatusHeader, string(status))
+	}
+
+	t.Hooks.observe(rep, status)
+	t.tenant(rep.Request).observe(status)
+	t.host(rep.Request).observe(status)
+	return rep
+}
+
+// bypass forwards a request that the cache does not handle to the origin.
+func (t *Transport) bypass(req *http.Request) (*http.Response, error) {
+	rep, err := t.forward(req)
+	return t.annotate(rep, CacheBypass), err
+}
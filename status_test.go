@@ -0,0 +1,71 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportStatusHeader(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Date", clock.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"v1"`)
+		switch r.URL.Path {
+		case "/swr":
+			w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=3600")
+		default:
+			w.Header().Set("Cache-Control", "max-age=1")
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{},
+		httpcache.WithClock(clock),
+		httpcache.WithStatusHeader(httpcache.DefaultStatusHeader),
+	)
+	client := transport.Client()
+
+	status := func(method, path string, headers ...string) string {
+		req, err := http.NewRequest(method, origin.URL+path, nil)
+		require.NoError(t, err)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		rep, err := client.Do(req)
+		require.NoError(t, err)
+		defer rep.Body.Close()
+		_, err = io.ReadAll(rep.Body)
+		require.NoError(t, err)
+		return rep.Header.Get("X-Cache-Status")
+	}
+
+	require.Equal(t, "MISS", status(http.MethodGet, "/"))
+	require.Equal(t, "HIT", status(http.MethodGet, "/"))
+	require.Equal(t, "HIT", status(http.MethodHead, "/"))
+	require.Equal(t, "HIT", status(http.MethodGet, "/", "Range", "bytes=0-3"))
+	require.Equal(t, "BYPASS", status(http.MethodGet, "/", "Cache-Control", "no-store"))
+	require.Equal(t, "BYPASS", status(http.MethodPost, "/"))
+	require.Equal(t, "MISS", status(http.MethodGet, "/"), "expected the post to invalidate the response")
+
+	require.Equal(t, "MISS", status(http.MethodGet, "/swr"))
+	clock.Advance(2 * time.Second)
+	require.Equal(t, "REVALIDATED", status(http.MethodGet, "/"))
+	require.Equal(t, "STALE", status(http.MethodGet, "/swr"))
+
+	t.Run("Disabled", func(t *testing.T) {
+		transport.StatusHeader = ""
+		require.Empty(t, status(http.MethodGet, "/"))
+	})
+}
@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// credentialHeaders contain credentials that must not be stored in cache keys.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Strict checks the configuration of the Transport for settings that are known to be
+// dangerous, e.g. because they may serve one client's responses or credentials to
+// another, and returns an error wrapping ErrInsecureConfig that describes each problem.
+// Compliance-sensitive deployments should create the Transport with
+// NewStrictTransport, which refuses insecure configurations when the Transport is
+// created and when a Config is applied with UpdateConfig.
+func (t *Transport) Strict() error {
+	return t.strictConfig(t.currentConfig())
+}
+
+// NewStrictTransport returns a Transport like NewTransport if its configuration passes
+// Strict, otherwise the error of Strict is returned. Configs applied to the Transport
+// with UpdateConfig are checked as well; insecure configs are logged and not applied.
+func NewStrictTransport(cache Cache, opts ...Option) (*Transport, error) {
+	t := NewTransport(cache, opts...)
+	if err := t.Strict(); err != nil {
+		return nil, err
+	}
+	t.strict = true
+	return t, nil
+}
+
+// strictConfig checks the Transport with the config in place of its current config.
+func (t *Transport) strictConfig(config *Config) error {
+	var errs []error
+	shared := t.anyShared(config)
+	if t.ReplayCookies && shared {
+		errs = append(errs, fmt.Errorf("%w: shared cache replays Set-Cookie headers to every client (ReplayCookies)", ErrInsecureConfig))
+	}
+
+	// Responses to authorized requests that allow shared caching are served to every
+	// client unless they are stored in a partition for the credential.
+	if shared && !t.PartitionByCredential {
+		errs = append(errs, fmt.Errorf("%w: shared cache serves responses to requests with an Authorization header to every client; use PartitionByCredential", ErrInsecureConfig))
+	}
+
+	hosts := make([]string, 0, len(config.Profiles))
+	for host := range config.Profiles {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, header := range credentialKeyHeaders(t.KeyHeaders) {
+		errs = append(errs, fmt.Errorf("%w: cache keys contain credentials from the %s header; use PartitionByCredential instead", ErrInsecureConfig, header))
+	}
+
+	for _, host := range hosts {
+		for _, header := range credentialKeyHeaders(config.Profiles[host].KeyHeaders) {
+			errs = append(errs, fmt.Errorf("%w: cache keys for host %q contain credentials from the %s header; use PartitionByCredential instead", ErrInsecureConfig, host, header))
+		}
+	}
+
+	// Cached POST requests are keyed by the digest of their body, but forcing their
+	// responses to be stored also stores responses to requests that change state.
+	if t.CachePOST {
+		for i, rule := range config.Rules {
+			if rule.ForceCache {
+				errs = append(errs, fmt.Errorf("%w: rule %d forces responses to POST requests to be stored (CachePOST with ForceCache)", ErrInsecureConfig, i))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// credentialKeyHeaders returns the canonical names of the key headers that contain
+// credentials.
+func credentialKeyHeaders(headers []string) (credentials []string) {
+	for _, header := range headers {
+		if canonical := http.CanonicalHeaderKey(header); slices.Contains(credentialHeaders, canonical) {
+			credentials = append(credentials, canonical)
+		}
+	}
+	return credentials
+}
+
+// anyShared returns true if the Transport or any of the profiles of the config is a
+// shared cache.
+func (t *Transport) anyShared(config *Config) bool {
+	if t.Shared {
+		return true
+	}
+
+	for _, profile := range config.Profiles {
+		if profile.Mode == ModeShared {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestStrict(t *testing.T) {
+	testCases := []struct {
+		transport *httpcache.Transport
+		errs      []string
+	}{
+		{
+			transport: &httpcache.Transport{},
+		},
+		{
+			transport: &httpcache.Transport{Shared: true, PartitionByCredential: true},
+		},
+		{
+			transport: &httpcache.Transport{ReplayCookies: true},
+		},
+		{
+			transport: &httpcache.Transport{Shared: true},
+			errs:      []string{"insecure cache configuration: shared cache serves responses to requests with an Authorization header to every client; use PartitionByCredential"},
+		},
+		{
+			transport: &httpcache.Transport{
+				Profiles: map[string]*httpcache.Profile{
+					"api.example.com": {Mode: httpcache.ModeShared},
+				},
+			},
+			errs: []string{"insecure cache configuration: shared cache serves responses to requests with an Authorization header to every client; use PartitionByCredential"},
+		},
+		{
+			transport: &httpcache.Transport{CachePOST: true, Rules: []httpcache.Rule{{Path: "/static/*", TTL: time.Hour}}},
+		},
+		{
+			transport: &httpcache.Transport{CachePOST: true, Rules: []httpcache.Rule{{Path: "/static/*"}, {Path: "/search", ForceCache: true}}},
+			errs:      []string{"insecure cache configuration: rule 1 forces responses to POST requests to be stored (CachePOST with ForceCache)"},
+		},
+		{
+			transport: &httpcache.Transport{Shared: true, ReplayCookies: true},
+			errs:      []string{"insecure cache configuration: shared cache replays Set-Cookie headers to every client (ReplayCookies)"},
+		},
+		{
+			transport: &httpcache.Transport{
+				ReplayCookies: true,
+				Profiles: map[string]*httpcache.Profile{
+					"api.example.com": {Mode: httpcache.ModeShared},
+				},
+			},
+			errs: []string{"insecure cache configuration: shared cache replays Set-Cookie headers to every client (ReplayCookies)"},
+		},
+		{
+			transport: &httpcache.Transport{KeyHeaders: []string{"X-Tenant-ID"}},
+		},
+		{
+			transport: &httpcache.Transport{KeyHeaders: []string{"X-Tenant-ID", "proxy-authorization"}},
+			errs:      []string{"insecure cache configuration: cache keys contain credentials from the Proxy-Authorization header; use PartitionByCredential instead"},
+		},
+		{
+			transport: &httpcache.Transport{
+				Profiles: map[string]*httpcache.Profile{
+					"b.example.com": {KeyHeaders: []string{"authorization"}},
+					"a.example.com": {KeyHeaders: []string{"X-Tenant", "Cookie"}},
+				},
+			},
+			errs: []string{
+				`insecure cache configuration: cache keys for host "a.example.com" contain credentials from the Cookie header; use PartitionByCredential instead`,
+				`insecure cache configuration: cache keys for host "b.example.com" contain credentials from the Authorization header; use PartitionByCredential instead`,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		err := tc.transport.Strict()
+		if len(tc.errs) == 0 {
+			require.NoError(t, err, "Test Case: %d", i)
+			continue
+		}
+
+		require.ErrorIs(t, err, httpcache.ErrInsecureConfig, "Test Case: %d", i)
+		for _, msg := range tc.errs {
+			require.Contains(t, err.Error(), msg, "Test Case: %d", i)
+		}
+	}
+}
+
+func TestNewStrictTransport(t *testing.T) {
+	_, err := httpcache.NewStrictTransport(&httpcache.InMemoryCache{}, httpcache.WithSharedMode())
+	require.ErrorIs(t, err, httpcache.ErrInsecureConfig)
+
+	transport, err := httpcache.NewStrictTransport(&httpcache.InMemoryCache{}, httpcache.WithSharedMode(), httpcache.WithCredentialPartitioning())
+	require.NoError(t, err)
+
+	// Insecure configs are not applied at runtime.
+	secure := httpcache.Config{Profiles: map[string]*httpcache.Profile{"api.example.com": {TTL: time.Minute}}}
+	transport.UpdateConfig(secure)
+	require.NoError(t, transport.Strict())
+
+	transport.UpdateConfig(httpcache.Config{Profiles: map[string]*httpcache.Profile{"api.example.com": {KeyHeaders: []string{"Cookie"}}}})
+	require.NoError(t, transport.Strict(), "expected the insecure config not to be applied")
+
+	// Transports that are not strict apply any config.
+	transport = httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.UpdateConfig(httpcache.Config{Profiles: map[string]*httpcache.Profile{"api.example.com": {KeyHeaders: []string{"Cookie"}}}})
+	require.ErrorIs(t, transport.Strict(), httpcache.ErrInsecureConfig)
+}
+
+func TestTransportReplayCookies(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Set-Cookie", fmt.Sprintf("session=%d", n))
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	// A shared cache does not replay cookies to other clients by default.
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Shared = true
+	client := transport.Client()
+
+	rep, body := Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Equal(t, "session=1", rep.Header.Get("Set-Cookie"))
+
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 1", body)
+	require.Empty(t, rep.Header.Get("Set-Cookie"))
+
+	// Cookies are replayed if explicitly enabled.
+	transport = httpcache.NewTransport(&httpcache.InMemoryCache{})
+	transport.Shared, transport.ReplayCookies = true, true
+	client = transport.Client()
+
+	Get(t, client, origin.URL)
+	rep, body = Get(t, client, origin.URL)
+	require.Equal(t, "response 2", body)
+	require.Equal(t, "session=2", rep.Header.Get("Set-Cookie"))
+}
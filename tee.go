@@ -0,0 +1,159 @@
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// teeChunkSize is the size of the reads from the body of a teed response.
+	teeChunkSize = 32 << 10
+
+	// defaultTeeLimit is the maximum number of bytes of a teed body that are buffered;
+	// the limit is lower if the policy limits the size of stored bodies further.
+	defaultTeeLimit = 8 << 20
+)
+
+// errTeeStopped is returned to readers attached to a tee that stopped reading the body
+// because all of its readers were closed.
+var errTeeStopped = errors.New("response body is no longer streamed")
+
+// tee reads the body of a response from the origin once and streams it to any number of
+// readers as it arrives. The body is read independently of the readers so that a closed
+// reader does not hold back the others; reading stops early only if every reader has
+// been closed. The body read by the tee is typically a caching body so that the
+// response is stored once it has been completely read.
+//
+// At most limit bytes are buffered: once the body exceeds the limit, the bytes that
+// every reader has read are discarded, no more readers can be attached, and reading
+// from the body waits for the slowest reader to catch up.
+type tee struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	src     io.ReadCloser
+	buf     []byte
+	base    int
+	limit   int
+	err     error
+	readers map[*teeReader]struct{}
+	done    func()
+}
+
+func newTee(src io.ReadCloser, limit int64, done func()) *tee {
+	if limit <= 0 || limit > defaultTeeLimit {
+		limit = defaultTeeLimit
+	}
+
+	t := &tee{src: src, limit: int(limit), readers: make(map[*teeReader]struct{}), done: done}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// pump reads the body until it is exhausted, fails or has no readers, then calls done.
+func (t *tee) pump() {
+	defer t.done()
+	defer t.src.Close()
+
+	chunk := make([]byte, teeChunkSize)
+	for {
+		n, err := t.src.Read(chunk)
+
+		t.mu.Lock()
+		t.buf = append(t.buf, chunk[:n]...)
+		switch {
+		case err != nil:
+			t.err = err
+		case len(t.readers) == 0:
+			t.err = errTeeStopped
+		default:
+			t.wait()
+		}
+		stopped := t.err != nil
+		t.cond.Broadcast()
+		t.mu.Unlock()
+
+		if stopped {
+			return
+		}
+	}
+}
+
+// wait discards the bytes that every reader has read once the buffer exceeds the limit
+// and blocks until the buffer fits within the limit or every reader has been closed.
+// The lock must be held.
+func (t *tee) wait() {
+	for len(t.buf) > t.limit {
+		if len(t.readers) == 0 {
+			t.err = errTeeStopped
+			return
+		}
+
+		read := t.base + len(t.buf)
+		for r := range t.readers {
+			read = min(read, r.off)
+		}
+
+		if discard := read - t.base; discard > 0 {
+			t.buf = t.buf[:copy(t.buf, t.buf[discard:])]
+			t.base = read
+			continue
+		}
+		t.cond.Wait()
+	}
+}
+
+// attach returns a reader of the body from the beginning, or nil if the tee has stopped
+// before the body was completely read or has discarded the beginning of the body.
+func (t *tee) attach() io.ReadCloser {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if (t.err != nil && t.err != io.EOF) || t.base > 0 {
+		return nil
+	}
+
+	r := &teeReader{tee: t}
+	t.readers[r] = struct{}{}
+	return r
+}
+
+type teeReader struct {
+	tee    *tee
+	off    int
+	closed bool
+}
+
+func (r *teeReader) Read(p []byte) (n int, err error) {
+	t := r.tee
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for !r.closed && r.off >= t.base+len(t.buf) && t.err == nil {
+		t.cond.Wait()
+	}
+
+	switch {
+	case r.closed:
+		return 0, errTeeStopped
+	case r.off < t.base+len(t.buf):
+		n = copy(p, t.buf[r.off-t.base:])
+		r.off += n
+		t.cond.Broadcast()
+		return n, nil
+	default:
+		return 0, t.err
+	}
+}
+
+func (r *teeReader) Close() error {
+	r.tee.mu.Lock()
+	defer r.tee.mu.Unlock()
+
+	if !r.closed {
+		r.closed = true
+		delete(r.tee.readers, r)
+		r.tee.cond.Broadcast()
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// varyIndexPrefix identifies a vary index stored in place of a response. Serialized
+// responses begin with the HTTP version so the prefix cannot be mistaken for one.
+const varyIndexPrefix = "vary-index:"
+
+// varyHeaders returns the sorted, canonical names of the request headers listed by the
+// Vary header of the response, or nil if the response does not vary.
+func varyHeaders(header http.Header) (names []string) {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && name != "*" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// varyAll returns true if the response varies on aspects of the request other than its
+// headers (Vary: *) and therefore can never be served from the cache.
+func varyAll(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// varyIndex serializes the names of the headers that the responses for a key vary on,
+// followed by the keys that the variants are stored at, one per line, so that all of
+// the variants can be found when the responses for the key are invalidated.
+func varyIndex(names, keys []string) []byte {
+	index := varyIndexPrefix + strings.Join(names, ",")
+	for _, key := range keys {
+		index += "\n" + key
+	}
+	return []byte(index)
+}
+
+// parseVaryIndex returns the header names and variant keys of a vary index and true if
+// the value is a vary index rather than a serialized response.
+func parseVaryIndex(val []byte) (names, keys []string, ok bool) {
+	index, ok := bytes.CutPrefix(val, []byte(varyIndexPrefix))
+	if !ok {
+		return nil, nil, false
+	}
+
+	lines := strings.Split(string(index), "\n")
+	return strings.Split(lines[0], ","), lines[1:], true
+}
+
+// indexVariant stores a vary index at the primary key that includes the key of a
+// variant. Variants listed by a previous index that vary on other headers can no longer
+// be found by requests and are removed.
+func (t *Transport) indexVariant(cache Cache, primary string, names []string, key string) {
+	t.indexes.Lock()
+	defer t.indexes.Unlock()
+
+	var keys []string
+	if val, ok := cache.Get(primary); ok {
+		if prev, prevKeys, isIndex := parseVaryIndex(val); isIndex {
+			if slices.Equal(prev, names) {
+				keys = prevKeys
+			} else {
+				for _, prevKey := range prevKeys {
+					if prevKey != key {
+						t.remove(cache, prevKey)
+					}
+				}
+			}
+		}
+	}
+
+	if slices.Contains(keys, key) {
+		return
+	}
+	cache.Put(primary, varyIndex(names, append(keys, key)))
+}
+
+// deleteEntry removes the entry stored at the key and, if it is a vary index, all of
+// the variants that it lists.
+func (t *Transport) deleteEntry(cache Cache, key string) {
+	if val, ok := cache.Get(key); ok {
+		if _, keys, isIndex := parseVaryIndex(val); isIndex {
+			for _, variant := range keys {
+				t.remove(cache, variant)
+			}
+		}
+	}
+	t.remove(cache, key)
+}
+
+// entryKeys returns the key and, if a vary index is stored at the key, the keys of the
+// variants that it lists instead.
+func (t *Transport) entryKeys(cache Cache, key string) []string {
+	val, ok := cache.Get(key)
+	if !ok {
+		return nil
+	}
+
+	if _, keys, isIndex := parseVaryIndex(val); isIndex {
+		return keys
+	}
+	return []string{key}
+}
@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// recordRequest records the method of the request and the values of the headers that
+// the response varies on in the internal headers of the response if VerifyKeys is set,
+// so that hits can be verified against the request.
+func (t *Transport) recordRequest(header http.Header, req *http.Request, vary []string) {
+	header.Del(headerMethod)
+	header.Del(headerVary)
+	if !t.VerifyKeys {
+		return
+	}
+
+	header.Set(headerMethod, req.Method)
+	if len(vary) > 0 {
+		values := make(url.Values, len(vary))
+		for _, name := range vary {
+			values.Set(name, normalize(req.Header.Get(name)))
+		}
+		header.Set(headerVary, values.Encode())
+	}
+}
+
+// verifyEntry returns an error wrapping ErrKeyMismatch if the stored response at the
+// key was not stored for a request that matches the request: it must have been stored
+// at the same key, for the same method and URL, apart from ignored query parameters,
+// and with the same values of the headers it varies on. Responses stored without
+// VerifyKeys are only verified by their key and URL.
+func verifyEntry(key string, req *http.Request, cached *http.Response, policy policy) error {
+	header := cached.Header
+	if stored := header.Get(headerKey); stored != "" && stored != key {
+		return fmt.Errorf("%w: entry at %q was stored at %q", ErrKeyMismatch, key, stored)
+	}
+
+	if stored := header.Get(headerURL); stored != "" {
+		u, err := url.Parse(stored)
+		if err != nil {
+			return fmt.Errorf("%w: entry at %q has invalid url: %w", ErrKeyMismatch, key, err)
+		}
+
+		if stored, requested := keyURL(u, policy), keyURL(req.URL, policy); stored != requested {
+			return fmt.Errorf("%w: entry at %q was stored for %q, not %q", ErrKeyMismatch, key, stored, requested)
+		}
+	}
+
+	method := header.Get(headerMethod)
+	if method == "" {
+		return nil
+	}
+
+	if method != req.Method {
+		return fmt.Errorf("%w: entry at %q was stored for %s, not %s", ErrKeyMismatch, key, method, req.Method)
+	}
+
+	values, err := url.ParseQuery(header.Get(headerVary))
+	if err != nil {
+		return fmt.Errorf("%w: entry at %q has invalid vary values: %w", ErrKeyMismatch, key, err)
+	}
+
+	for _, name := range varyHeaders(header) {
+		if stored, requested := values.Get(name), normalize(req.Header.Get(name)); stored != requested {
+			return fmt.Errorf("%w: entry at %q was stored for %s %q, not %q", ErrKeyMismatch, key, name, stored, requested)
+		}
+	}
+	return nil
+}
+
+// keyURL returns the URL without the query parameters that the policy ignores.
+func keyURL(u *url.URL, policy policy) string {
+	if len(policy.ignored) == 0 {
+		return u.String()
+	}
+	return withoutQueryParams(&http.Request{URL: u}, policy.ignored).URL.String()
+}
@@ -0,0 +1,88 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportVerifyKeys(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/vary" {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		fmt.Fprintf(w, "response %d for %s %s", n, r.URL.Path, r.Header.Get("Accept-Language"))
+	})
+
+	setup := func(t *testing.T, verify bool) (*httpcache.InMemoryCache, *http.Client, *[]error) {
+		errs := &[]error{}
+		cache := &httpcache.InMemoryCache{}
+		transport := &httpcache.Transport{Cache: cache, VerifyKeys: verify, Hooks: httpcache.Hooks{
+			OnError: func(_ *http.Request, err error) { *errs = append(*errs, err) },
+		}}
+		return cache, transport.Client(), errs
+	}
+
+	// collide copies the entry at one key to another, as a hash collision would.
+	collide := func(t *testing.T, cache *httpcache.InMemoryCache, from, to string) {
+		val, ok := cache.Get(from)
+		require.True(t, ok)
+		cache.Put(to, val)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		cache, client, errs := setup(t, false)
+		Get(t, client, origin.URL+"/a")
+		Get(t, client, origin.URL+"/b")
+		collide(t, cache, origin.URL+"/a", origin.URL+"/b")
+
+		_, body := Get(t, client, origin.URL+"/b")
+		require.Contains(t, body, "for /a", "expected the colliding entry to be served")
+		require.Empty(t, *errs)
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		cache, client, errs := setup(t, true)
+		Get(t, client, origin.URL+"/a")
+		Get(t, client, origin.URL+"/b")
+		collide(t, cache, origin.URL+"/a", origin.URL+"/b")
+
+		_, body := Get(t, client, origin.URL+"/b")
+		require.Contains(t, body, "for /b")
+		require.Len(t, *errs, 1)
+		require.ErrorIs(t, (*errs)[0], httpcache.ErrKeyMismatch)
+
+		// The entry is replaced by the response to the request and served again.
+		_, again := Get(t, client, origin.URL+"/b")
+		require.Equal(t, body, again)
+		require.Len(t, *errs, 1)
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		cache, client, errs := setup(t, true)
+		_, en := Get(t, client, origin.URL+"/vary", "Accept-Language", "en")
+		_, fr := Get(t, client, origin.URL+"/vary", "Accept-Language", "fr")
+
+		_, body := Get(t, client, origin.URL+"/vary", "Accept-Language", "fr")
+		require.Equal(t, fr, body)
+		require.Empty(t, *errs)
+
+		// Copy the entry and its recorded key so that only the vary values differ.
+		enKey, frKey := origin.URL+"/vary|vary:Accept-Language:en", origin.URL+"/vary|vary:Accept-Language:fr"
+		val, ok := cache.Get(enKey)
+		require.True(t, ok)
+		cache.Put(frKey, bytes.Replace(val, []byte(enKey), []byte(frKey), 1))
+		require.NotEqual(t, en, fr)
+
+		_, body = Get(t, client, origin.URL+"/vary", "Accept-Language", "fr")
+		require.Contains(t, body, "for /vary fr")
+		require.NotEqual(t, fr, body, "expected the response to be fetched again")
+		require.Len(t, *errs, 1)
+		require.ErrorIs(t, (*errs)[0], httpcache.ErrKeyMismatch)
+	})
+}
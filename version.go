@@ -0,0 +1,45 @@
+package httpcache
+
+import "strings"
+
+// KeyLister is implemented by caches that can enumerate the keys of their entries so
+// that entries can be removed in bulk, e.g. by Transport.CollectGarbage.
+type KeyLister interface {
+	// Keys returns the keys of all entries in the cache.
+	Keys() []string
+}
+
+// versionPrefix returns the prefix of the cache keys stored under the version.
+func versionPrefix(version string) string {
+	if version == "" {
+		return ""
+	}
+	return "[" + version + "] "
+}
+
+// CollectGarbage removes all entries from the cache that were not stored under the
+// current Version of the Transport, including entries stored without a version, and
+// returns the number of entries that were removed. The cache must implement KeyLister
+// otherwise ErrKeysUnsupported is returned. The cache should not be shared with other
+// transports that use a different version.
+func (t *Transport) CollectGarbage() (n int, err error) {
+	lister, ok := t.Cache.(KeyLister)
+	if !ok {
+		return 0, ErrKeysUnsupported
+	}
+
+	prefix := versionPrefix(t.Version)
+	for _, key := range lister.Keys() {
+		// Unversioned keys are URLs or methods and never start with a version prefix.
+		current := strings.HasPrefix(key, prefix)
+		if prefix == "" {
+			current = !strings.HasPrefix(key, "[")
+		}
+
+		if !current {
+			t.remove(t.Cache, key)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestTransportVersion(t *testing.T) {
+	origin := NewOrigin(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	cache := &httpcache.InMemoryCache{}
+	unversioned := &httpcache.Transport{Cache: cache}
+	blue := &httpcache.Transport{Cache: cache, Version: "blue"}
+	green := &httpcache.Transport{Cache: cache, Version: "green"}
+
+	// Each version stores its own response.
+	for _, transport := range []*httpcache.Transport{unversioned, blue, green} {
+		Get(t, transport.Client(), origin.URL)
+		Get(t, transport.Client(), origin.URL)
+	}
+	require.Equal(t, int64(3), origin.Requests())
+	require.Equal(t, 3, cache.Len())
+
+	n, err := green.CollectGarbage()
+	require.NoError(t, err)
+	require.Equal(t, 2, n, "expected unversioned and blue entries to be removed")
+	require.Equal(t, []string{"[green] " + origin.URL}, cache.Keys())
+
+	Get(t, green.Client(), origin.URL)
+	require.Equal(t, int64(3), origin.Requests(), "expected current version to be served from cache")
+
+	n, err = unversioned.CollectGarbage()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Zero(t, cache.Len())
+}
+
+func TestCollectGarbageUnsupported(t *testing.T) {
+	transport := &httpcache.Transport{Cache: nopCache{}, Version: "v1"}
+	_, err := transport.CollectGarbage()
+	require.ErrorIs(t, err, httpcache.ErrKeysUnsupported)
+}
+
+// nopCache is a Cache that does not implement KeyLister.
+type nopCache struct{}
+
+func (nopCache) Get(string) ([]byte, bool) { return nil, false }
+func (nopCache) Put(string, []byte)        {}
+func (nopCache) Del(string)                {}
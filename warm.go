@@ -0,0 +1,257 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWarmConcurrency = 4
+	maxSitemapDepth        = 4
+)
+
+// WarmOptions configures how cache warming helpers fetch URLs from the origin.
+type WarmOptions struct {
+	// Concurrency is the maximum number of requests in flight at once (default 4).
+	Concurrency int
+
+	// Delay is a politeness delay that each worker waits between requests so that
+	// warming the cache does not overwhelm the origin server.
+	Delay time.Duration
+
+	// Progress is called after each URL has been fetched so that long running warmups
+	// can be monitored. Calls are serialized. The URLs of successful fetches can be
+	// recorded as a checkpoint and passed to Skip to resume an interrupted warmup.
+	Progress func(WarmProgress)
+
+	// Skip lists URLs that have already been warmed, e.g. by a previous warmup that was
+	// interrupted, and should not be fetched again.
+	Skip []string
+}
+
+// WarmProgress reports the outcome of fetching a URL while warming the cache.
+type WarmProgress struct {
+	// URL that was fetched.
+	URL string
+
+	// Err is the error that occurred fetching the URL, if any, including unsuccessful
+	// responses such as 404 Not Found or 500 Internal Server Error.
+	Err error
+
+	// Completed is the number of URLs that have been fetched so far, including this one.
+	Completed int
+
+	// Total is the number of URLs to fetch, excluding those that were skipped.
+	Total int
+}
+
+// WarmFromSitemap fetches the XML sitemap at the specified URL and requests every URL
+// it contains using the client so that the responses are stored in the cache. The
+// client should use a Transport as its RoundTripper. Sitemap index files are followed
+// recursively. Failures to fetch individual URLs are logged and do not stop warming;
+// an error is only returned if the sitemap itself cannot be fetched or parsed, or if
+// the context is canceled.
+func WarmFromSitemap(ctx context.Context, client *http.Client, sitemapURL string, opts *WarmOptions) (err error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if opts == nil {
+		opts = &WarmOptions{}
+	}
+
+	var urls []string
+	if urls, err = sitemapURLs(ctx, client, sitemapURL, 0, make(map[string]struct{})); err != nil {
+		return err
+	}
+
+	return warm(ctx, client, urls, opts)
+}
+
+// Warm requests each of the URLs using the client so that the responses are stored in
+// the cache. The client should use a Transport as its RoundTripper. Failures to fetch
+// individual URLs are logged and do not stop warming; an error is only returned if the
+// context is canceled.
+func Warm(ctx context.Context, client *http.Client, urls []string, opts *WarmOptions) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if opts == nil {
+		opts = &WarmOptions{}
+	}
+	return warm(ctx, client, urls, opts)
+}
+
+// warm fetches the urls concurrently, discarding the response bodies once they have
+// been fully read (and therefore stored by the cache). URLs listed by opts.Skip are
+// not fetched.
+func warm(ctx context.Context, client *http.Client, urls []string, opts *WarmOptions) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWarmConcurrency
+	}
+
+	if len(opts.Skip) > 0 {
+		skip := make(map[string]struct{}, len(opts.Skip))
+		for _, url := range opts.Skip {
+			skip[url] = struct{}{}
+		}
+
+		remaining := make([]string, 0, len(urls))
+		for _, url := range urls {
+			if _, ok := skip[url]; !ok {
+				remaining = append(remaining, url)
+			}
+		}
+		urls = remaining
+	}
+
+	var (
+		mu        sync.Mutex
+		completed int
+	)
+
+	report := func(url string, err error) {
+		if opts.Progress == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		completed++
+		opts.Progress(WarmProgress{URL: url, Err: err, Completed: completed, Total: len(urls)})
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range queue {
+				err := fetch(ctx, client, url)
+				if err != nil {
+					GetLogger().Warn("could not warm cache", slog.String("url", url), slog.Any("error", err))
+				}
+				report(url, err)
+
+				if opts.Delay > 0 {
+					select {
+					case <-time.After(opts.Delay):
+					case <-ctx.Done():
+					}
+				}
+			}
+		}()
+	}
+
+	defer wg.Wait()
+	defer close(queue)
+
+	for _, url := range urls {
+		select {
+		case queue <- url:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// fetch performs a GET request for the url and reads the entire body. An error is
+// returned if the response is not successful since it does not warm the cache.
+func fetch(ctx context.Context, client *http.Client, url string) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err != nil {
+		return err
+	}
+
+	var rep *http.Response
+	if rep, err = client.Do(req); err != nil {
+		return err
+	}
+	defer rep.Body.Close()
+
+	if _, err = io.Copy(io.Discard, rep.Body); err != nil {
+		return err
+	}
+
+	if rep.StatusCode < 200 || rep.StatusCode > 299 {
+		return fmt.Errorf("could not fetch %s: %s", url, rep.Status)
+	}
+	return nil
+}
+
+//===========================================================================
+// Sitemap Parsing
+//===========================================================================
+
+// sitemap can be decoded from both a urlset and a sitemapindex document.
+type sitemap struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs fetches and parses the sitemap, following sitemap indices up to the
+// maximum depth, and returns all of the page URLs that were found.
+func sitemapURLs(ctx context.Context, client *http.Client, url string, depth int, seen map[string]struct{}) (urls []string, err error) {
+	if _, ok := seen[url]; ok {
+		return nil, nil
+	}
+	seen[url] = struct{}{}
+
+	if depth > maxSitemapDepth {
+		return nil, fmt.Errorf("sitemap index nesting exceeds maximum depth of %d", maxSitemapDepth)
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err != nil {
+		return nil, err
+	}
+
+	var rep *http.Response
+	if rep, err = client.Do(req); err != nil {
+		return nil, err
+	}
+	defer rep.Body.Close()
+
+	if rep.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch sitemap %s: %s", url, rep.Status)
+	}
+
+	var doc sitemap
+	if err = xml.NewDecoder(rep.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse sitemap %s: %w", url, err)
+	}
+
+	for _, loc := range doc.URLs {
+		if loc := strings.TrimSpace(loc.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+
+	for _, loc := range doc.Sitemaps {
+		if loc := strings.TrimSpace(loc.Loc); loc != "" {
+			var nested []string
+			if nested, err = sitemapURLs(ctx, client, loc, depth+1, seen); err != nil {
+				return nil, err
+			}
+			urls = append(urls, nested...)
+		}
+	}
+
+	return urls, nil
+}
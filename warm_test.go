@@ -0,0 +1,139 @@
+package httpcache_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestWarmFromSitemap(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = make(map[string]int)
+	)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+	<sitemap><loc>%[1]s/sitemap.xml</loc></sitemap>
+</sitemapindex>`, srv.URL)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%[1]s/a</loc></url>
+	<url><loc> %[1]s/b </loc></url>
+	<url><loc>%[1]s/c</loc></url>
+</urlset>`, srv.URL)
+		default:
+			w.Write([]byte("page"))
+		}
+	}))
+	defer srv.Close()
+
+	err := httpcache.WarmFromSitemap(context.Background(), srv.Client(), srv.URL+"/sitemap.xml", &httpcache.WarmOptions{Concurrency: 2})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"/sitemap.xml": 1, "/pages.xml": 1, "/a": 1, "/b": 1, "/c": 1}, hits)
+}
+
+func TestWarmFromSitemapError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid.xml":
+			w.Write([]byte("<urlset><url>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	err := httpcache.WarmFromSitemap(context.Background(), srv.Client(), srv.URL+"/missing.xml", nil)
+	require.EqualError(t, err, "could not fetch sitemap "+srv.URL+"/missing.xml: 404 Not Found")
+
+	err = httpcache.WarmFromSitemap(context.Background(), srv.Client(), srv.URL+"/invalid.xml", nil)
+	require.ErrorContains(t, err, "could not parse sitemap")
+}
+
+func TestWarmProgress(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%[1]s/a</loc></url>
+	<url><loc>%[1]s/b</loc></url>
+	<url><loc>%[1]s/c</loc></url>
+</urlset>`, srv.URL)
+		case "/a":
+			t.Errorf("skipped url %q was fetched", r.URL.Path)
+		case "/c":
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("page"))
+		}
+	}))
+	defer srv.Close()
+
+	var reports []httpcache.WarmProgress
+	opts := &httpcache.WarmOptions{
+		Concurrency: 2,
+		Skip:        []string{srv.URL + "/a"},
+		Progress:    func(p httpcache.WarmProgress) { reports = append(reports, p) },
+	}
+
+	err := httpcache.WarmFromSitemap(context.Background(), srv.Client(), srv.URL+"/sitemap.xml", opts)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	// Only successful fetches may be recorded as a checkpoint.
+	var succeeded, failed []string
+	for i, report := range reports {
+		require.Equal(t, i+1, report.Completed)
+		require.Equal(t, 2, report.Total)
+		if report.Err != nil {
+			require.EqualError(t, report.Err, "could not fetch "+report.URL+": 500 Internal Server Error")
+			failed = append(failed, report.URL)
+			continue
+		}
+		succeeded = append(succeeded, report.URL)
+	}
+	require.Equal(t, []string{srv.URL + "/b"}, succeeded)
+	require.Equal(t, []string{srv.URL + "/c"}, failed)
+}
+
+func TestWarm(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = make(map[string]int)
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	urls := []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+	require.NoError(t, httpcache.Warm(context.Background(), srv.Client(), urls, &httpcache.WarmOptions{Skip: urls[2:]}))
+	require.Equal(t, map[string]int{"/a": 1, "/b": 1}, hits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, httpcache.Warm(ctx, srv.Client(), urls, nil), context.Canceled)
+}
@@ -0,0 +1,250 @@
+/*
+Package webhook provides an HTTP handler that accepts authenticated webhook calls from
+upstream systems, such as a CMS publishing "object X changed" or a deploy pipeline
+asking to "warm these URLs", and translates them into invalidations and prefetches on a
+local httpcache.Transport.
+
+Calls are POST requests with a JSON body that lists the URLs to act on:
+
+	{
+		"invalidate": ["https://api.example.com/articles/42"],
+		"purge": ["https://api.example.com/articles"],
+		"purge_origins": ["https://cdn.example.com"],
+		"warm": ["https://api.example.com/articles/42"]
+	}
+
+URLs listed in invalidate are removed from the cache, URLs listed in purge are marked as
+stale so that they are revalidated on their next request (see Transport.SoftPurge), and
+all entries of the origins listed in purge_origins are removed. Invalidations are
+applied before the handler responds; URLs listed in warm are then fetched in the
+background through the Transport so that their responses are stored in the cache.
+
+Every call must be signed with a secret shared with the sender: the Signature header
+contains "sha256=" followed by the hex encoded HMAC-SHA256 of the request body (see
+Sign). The signature does not protect against replayed calls, so the handler should
+only be served over TLS.
+
+Example Usage:
+
+	transport := httpcache.NewTransport(cache)
+	hooks := webhook.New(transport, []byte(os.Getenv("HTTPCACHE_WEBHOOK_SECRET")))
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /_cache/webhook", hooks)
+*/
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.rtnl.ai/httpcache"
+)
+
+const (
+	// Signature is the header that contains the signature of the request body.
+	Signature = "X-Httpcache-Signature"
+
+	// MaxBodySize is the maximum size of the body of a webhook call.
+	MaxBodySize = 1 << 20
+
+	signaturePrefix = "sha256="
+)
+
+// Call is the body of a webhook call.
+type Call struct {
+	// Invalidate lists URLs whose stored responses are removed.
+	Invalidate []string `json:"invalidate,omitempty"`
+
+	// Purge lists URLs whose stored responses are marked as stale.
+	Purge []string `json:"purge,omitempty"`
+
+	// PurgeOrigins lists origins whose stored responses are all removed.
+	PurgeOrigins []string `json:"purge_origins,omitempty"`
+
+	// Warm lists URLs that are fetched in the background to store their responses.
+	Warm []string `json:"warm,omitempty"`
+}
+
+// Reply is the body of the response to a successful webhook call.
+type Reply struct {
+	// Invalidated is the number of URLs that were invalidated or purged.
+	Invalidated int `json:"invalidated"`
+
+	// Removed is the number of entries removed by purging origins.
+	Removed int `json:"removed"`
+
+	// Warming is the number of URLs that are being fetched in the background.
+	Warming int `json:"warming"`
+}
+
+// Handler is an http.Handler that applies signed webhook calls to a Transport.
+type Handler struct {
+	// Transport whose cache is invalidated and warmed.
+	Transport *httpcache.Transport
+
+	// Secret is shared with the sender to sign calls. If it is empty every call is
+	// rejected.
+	Secret []byte
+
+	// Client is used to warm URLs (default is a client that uses the Transport).
+	Client *http.Client
+
+	// WarmOptions configures how URLs are fetched when warming the cache.
+	WarmOptions *httpcache.WarmOptions
+
+	warming sync.WaitGroup
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// New returns a Handler that applies calls signed with the secret to the Transport.
+func New(transport *httpcache.Transport, secret []byte) *Handler {
+	return &Handler{Transport: transport, Secret: secret}
+}
+
+// Sign returns the value of the Signature header for the body signed with the secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP verifies the signature of the call, applies its invalidations and starts
+// warming its URLs in the background. It responds 202 Accepted with a Reply once the
+// invalidations have been applied.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		reply(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	if !h.verify(r.Header.Get(Signature), body) {
+		reply(w, http.StatusUnauthorized, errors.New("invalid signature"))
+		return
+	}
+
+	var call Call
+	if err = json.Unmarshal(body, &call); err != nil {
+		reply(w, http.StatusBadRequest, fmt.Errorf("could not parse call: %w", err))
+		return
+	}
+
+	if err = call.validate(); err != nil {
+		reply(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var out *Reply
+	if out, err = h.apply(&call); err != nil {
+		reply(w, http.StatusInternalServerError, err)
+		return
+	}
+	reply(w, http.StatusAccepted, out)
+}
+
+// Wait blocks until the URLs of all accepted calls have been warmed, e.g. so that
+// warming completes before the server shuts down.
+func (h *Handler) Wait() {
+	h.warming.Wait()
+}
+
+// verify returns true if the signature is the signature of the body.
+func (h *Handler) verify(signature string, body []byte) bool {
+	if len(h.Secret) == 0 || !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(h.Secret, body)))
+}
+
+// apply invalidates the URLs of the call and starts warming its URLs.
+func (h *Handler) apply(call *Call) (out *Reply, err error) {
+	out = &Reply{}
+	for _, target := range call.Invalidate {
+		if err = h.Transport.Invalidate(target); err != nil {
+			return nil, err
+		}
+		out.Invalidated++
+	}
+
+	for _, target := range call.Purge {
+		if err = h.Transport.SoftPurge(target); err != nil {
+			return nil, err
+		}
+		out.Invalidated++
+	}
+
+	for _, origin := range call.PurgeOrigins {
+		var n int
+		if n, err = h.Transport.PurgeOrigin(origin); err != nil {
+			return nil, err
+		}
+		out.Removed += n
+	}
+
+	if len(call.Warm) > 0 {
+		out.Warming = len(call.Warm)
+		h.warm(call.Warm)
+	}
+	return out, nil
+}
+
+// warm fetches the URLs in the background; the call has already been answered so the
+// fetches are not bound to the context of its request.
+func (h *Handler) warm(urls []string) {
+	client := h.Client
+	if client == nil {
+		client = h.Transport.Client()
+	}
+
+	h.warming.Add(1)
+	go func() {
+		defer h.warming.Done()
+		if err := httpcache.Warm(context.Background(), client, urls, h.WarmOptions); err != nil {
+			httpcache.GetLogger().Warn("could not warm webhook urls", slog.Any("error", err))
+		}
+	}()
+}
+
+// validate returns an error if any of the URLs of the call is not an absolute http or
+// https URL, so that a malformed call is rejected before any of it is applied.
+func (c *Call) validate() error {
+	for _, urls := range [][]string{c.Invalidate, c.Purge, c.PurgeOrigins, c.Warm} {
+		for _, target := range urls {
+			u, err := url.Parse(target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid url %q", target)
+			}
+		}
+	}
+	return nil
+}
+
+// reply writes the value, or the error as an error member, as the JSON response body.
+func reply(w http.ResponseWriter, status int, v any) {
+	if err, ok := v.(error); ok {
+		v = map[string]string{"error": err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,124 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/webhook"
+)
+
+var secret = []byte("supersecretsquirrel")
+
+func call(t *testing.T, handler http.Handler, body string, sign []byte) (int, map[string]any) {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewBufferString(body))
+	if sign != nil {
+		req.Header.Set(webhook.Signature, webhook.Sign(sign, []byte(body)))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	out := make(map[string]any)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	return w.Code, out
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	rep, err := client.Get(url)
+	require.NoError(t, err)
+	defer rep.Body.Close()
+
+	body, err := io.ReadAll(rep.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestHandler(t *testing.T) {
+	var requests atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "%s %d", r.URL.Path, requests.Add(1))
+	}))
+	defer origin.Close()
+
+	cache := &httpcache.InMemoryCache{}
+	transport := httpcache.NewTransport(cache)
+	client := transport.Client()
+	handler := webhook.New(transport, secret)
+
+	require.Equal(t, "/a 1", get(t, client, origin.URL+"/a"))
+	require.Equal(t, "/b 2", get(t, client, origin.URL+"/b"))
+	require.Equal(t, "/a 1", get(t, client, origin.URL+"/a"))
+
+	body := fmt.Sprintf(`{"invalidate":[%q],"warm":[%q,%q]}`, origin.URL+"/a", origin.URL+"/a", origin.URL+"/c")
+	code, out := call(t, handler, body, secret)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, map[string]any{"invalidated": float64(1), "removed": float64(0), "warming": float64(2)}, out)
+
+	handler.Wait()
+	require.Equal(t, int64(4), requests.Load())
+	require.Equal(t, "/a 3", get(t, client, origin.URL+"/a"))
+	require.Equal(t, "/b 2", get(t, client, origin.URL+"/b"))
+	require.Equal(t, "/c 4", get(t, client, origin.URL+"/c"))
+	require.Equal(t, int64(4), requests.Load())
+
+	code, out = call(t, handler, fmt.Sprintf(`{"purge_origins":[%q]}`, origin.URL), secret)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, float64(3), out["removed"])
+	require.Empty(t, cache.Keys())
+}
+
+func TestHandlerErrors(t *testing.T) {
+	transport := httpcache.NewTransport(&httpcache.InMemoryCache{})
+	handler := webhook.New(transport, secret)
+
+	tests := []struct {
+		body   string
+		sign   []byte
+		status int
+	}{
+		{`{}`, nil, http.StatusUnauthorized},
+		{`{}`, []byte("wrong"), http.StatusUnauthorized},
+		{`{"invalidate":`, secret, http.StatusBadRequest},
+		{`{"invalidate":["/relative"]}`, secret, http.StatusBadRequest},
+		{`{"warm":["ftp://example.com/a"]}`, secret, http.StatusBadRequest},
+		{`{"invalidate":["http://example.com/a"]}`, secret, http.StatusAccepted},
+	}
+
+	for _, tc := range tests {
+		code, out := call(t, handler, tc.body, tc.sign)
+		require.Equal(t, tc.status, code, "Test Case: %q", tc.body)
+		if tc.status != http.StatusAccepted {
+			require.NotEmpty(t, out["error"], "Test Case: %q", tc.body)
+		}
+	}
+
+	// Calls are rejected if no secret is configured, even if they are signed with it.
+	code, _ := call(t, webhook.New(transport, nil), `{}`, []byte{})
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+
+	// Purging origins requires a cache that can list its keys.
+	nolist := webhook.New(httpcache.NewTransport(nopCache{}), secret)
+	code, _ = call(t, nolist, `{"purge_origins":["http://example.com"]}`, secret)
+	require.Equal(t, http.StatusInternalServerError, code)
+}
+
+type nopCache struct{}
+
+func (nopCache) Get(string) ([]byte, bool) { return nil, false }
+func (nopCache) Put(string, []byte)        {}
+func (nopCache) Del(string)                {}
@@ -0,0 +1,57 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WritePolicy determines which response is kept when concurrent requests for the same
+// key race to store their responses in the cache.
+type WritePolicy uint8
+
+const (
+	// WriteLastWins stores every response so the response that finishes last replaces
+	// the others, even if it was generated earlier. This is the default policy.
+	WriteLastWins WritePolicy = iota
+
+	// WriteFreshestWins only replaces a stored response with one that was generated at
+	// the same time or later by the origin, as determined by the Date and Age headers,
+	// so that a slow response that was in flight cannot replace a newer response.
+	WriteFreshestWins
+)
+
+// supersedes returns true if the response with the header may replace the response
+// stored at the key according to the write policy.
+func (t *Transport) supersedes(cache Cache, key string, header http.Header, responseTime time.Time) bool {
+	if t.WritePolicy != WriteFreshestWins {
+		return true
+	}
+
+	val, ok := cache.Get(key)
+	if !ok {
+		return true
+	}
+
+	stored, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(val)), nil)
+	if err != nil {
+		return true
+	}
+	stored.Body.Close()
+
+	_, storedResponseTime := storedTimes(stored.Header)
+	if generated(header, responseTime).Before(generated(stored.Header, storedResponseTime)) {
+		GetLogger().Debug("not replacing newer stored response", slog.String("key", key))
+		return false
+	}
+	return true
+}
+
+// generated estimates when the response was generated by the origin from its Date and
+// Age headers. The fallback is used if the response has no valid Date.
+func generated(header http.Header, fallback time.Time) time.Time {
+	age, _ := deltaSeconds(header.Get("Age"))
+	return dateOf(header, fallback).Add(-age)
+}
@@ -0,0 +1,53 @@
+package httpcache_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+)
+
+func TestWritePolicy(t *testing.T) {
+	// The origin responds with the response generated at the requested time, allowing
+	// an older response to arrive after a newer one.
+	origin := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		generated := req.Header.Get("X-Generated")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Cache-Control": {"max-age=3600"},
+				"Date":          {generated},
+			},
+			Body:          io.NopCloser(strings.NewReader("generated " + generated)),
+			ContentLength: -1,
+			Request:       req,
+		}, nil
+	})
+
+	now := time.Now().UTC()
+	newer := now.Format(http.TimeFormat)
+	older := now.Add(-time.Minute).Format(http.TimeFormat)
+
+	tests := []struct {
+		policy   httpcache.WritePolicy
+		expected string
+	}{
+		{httpcache.WriteLastWins, older},
+		{httpcache.WriteFreshestWins, newer},
+	}
+
+	for _, test := range tests {
+		transport := &httpcache.Transport{Transport: origin, Cache: &httpcache.InMemoryCache{}, WritePolicy: test.policy}
+		client := transport.Client()
+
+		Get(t, client, "http://example.com/", "X-Generated", newer)
+		Get(t, client, "http://example.com/", "X-Generated", older, "Cache-Control", "no-cache")
+
+		_, body := Get(t, client, "http://example.com/")
+		require.Equal(t, "generated "+test.expected, body, "Test Case: policy %d", test.policy)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Package zlog provides a zerolog-backed slog.Handler so that the log lines emitted by
+httpcache are written by the same structured logger as the rest of a Rotational app.
+
+Every line written through the handler is tagged with a component field so that cache
+logs can be filtered, errors are written to zerolog's error field, and durations and
+times use zerolog's configured formats.
+
+Example Usage:
+
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	zlog.Install(logger)
+*/
+package zlog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+	"go.rtnl.ai/httpcache"
+)
+
+const (
+	// ComponentField is the name of the field that identifies httpcache log lines.
+	ComponentField = "component"
+
+	// Component is the value of the component field for httpcache log lines.
+	Component = "httpcache"
+)
+
+// Install sets the httpcache logger to a slog.Logger that writes to the zerolog logger
+// with the httpcache component field added to every line.
+func Install(logger zerolog.Logger) {
+	logger = logger.With().Str(ComponentField, Component).Logger()
+	httpcache.SetLogger(slog.New(New(logger)))
+}
+
+// Handler implements slog.Handler by writing records to a zerolog.Logger.
+type Handler struct {
+	logger zerolog.Logger
+	prefix string
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+// New returns a slog.Handler that writes records to the zerolog logger.
+func New(logger zerolog.Logger) *Handler {
+	return &Handler{logger: logger}
+}
+
+// Enabled reports whether the zerolog logger writes records at the level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.GetLevel() <= zerologLevel(level) && zerolog.GlobalLevel() <= zerologLevel(level)
+}
+
+// Handle writes the record and its attributes as a zerolog event.
+func (h *Handler) Handle(_ context.Context, record slog.Record) error {
+	event := h.logger.WithLevel(zerologLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		event = appendAttr(event, h.prefix, attr)
+		return true
+	})
+
+	event.Msg(record.Message)
+	return nil
+}
+
+// WithAttrs returns a handler whose logger includes the attributes on every line.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ctx := h.logger.With()
+	for _, attr := range attrs {
+		ctx = appendContext(ctx, h.prefix, attr)
+	}
+	return &Handler{logger: ctx.Logger(), prefix: h.prefix}
+}
+
+// WithGroup returns a handler that prefixes the keys of subsequent attributes with
+// the group name separated by a period.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	case level >= slog.LevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+// appendAttr adds the attribute to the event using the most specific zerolog type.
+func appendAttr(event *zerolog.Event, prefix string, attr slog.Attr) *zerolog.Event {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return event
+	}
+
+	key := prefix + attr.Key
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := prefix
+		if attr.Key != "" {
+			group = key + "."
+		}
+		for _, child := range attr.Value.Group() {
+			event = appendAttr(event, group, child)
+		}
+		return event
+	case slog.KindString:
+		return event.Str(key, attr.Value.String())
+	case slog.KindInt64:
+		return event.Int64(key, attr.Value.Int64())
+	case slog.KindUint64:
+		return event.Uint64(key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		return event.Float64(key, attr.Value.Float64())
+	case slog.KindBool:
+		return event.Bool(key, attr.Value.Bool())
+	case slog.KindDuration:
+		return event.Dur(key, attr.Value.Duration())
+	case slog.KindTime:
+		return event.Time(key, attr.Value.Time())
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			if attr.Key == "error" && prefix == "" {
+				return event.Err(err)
+			}
+			return event.AnErr(key, err)
+		}
+		return event.Interface(key, attr.Value.Any())
+	}
+}
+
+// appendContext adds the attribute to the logger context.
+func appendContext(ctx zerolog.Context, prefix string, attr slog.Attr) zerolog.Context {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return ctx
+	}
+
+	key := prefix + attr.Key
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := prefix
+		if attr.Key != "" {
+			group = key + "."
+		}
+		for _, child := range attr.Value.Group() {
+			ctx = appendContext(ctx, group, child)
+		}
+		return ctx
+	case slog.KindString:
+		return ctx.Str(key, attr.Value.String())
+	case slog.KindInt64:
+		return ctx.Int64(key, attr.Value.Int64())
+	case slog.KindUint64:
+		return ctx.Uint64(key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		return ctx.Float64(key, attr.Value.Float64())
+	case slog.KindBool:
+		return ctx.Bool(key, attr.Value.Bool())
+	case slog.KindDuration:
+		return ctx.Dur(key, attr.Value.Duration())
+	case slog.KindTime:
+		return ctx.Time(key, attr.Value.Time())
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			return ctx.AnErr(key, err)
+		}
+		return ctx.Interface(key, attr.Value.Any())
+	}
+}
@@ -0,0 +1,63 @@
+package zlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.rtnl.ai/httpcache"
+	"go.rtnl.ai/httpcache/zlog"
+)
+
+func TestHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(zlog.New(zerolog.New(buf).Level(zerolog.InfoLevel)))
+
+	logger.Debug("not logged")
+	require.Zero(t, buf.Len())
+
+	logger.With(slog.String("backend", "leveldb")).WithGroup("entry").Warn("cache failure",
+		slog.String("key", "http://example.com"),
+		slog.Int("size", 42),
+		slog.Bool("stale", true),
+		slog.Duration("age", 2*time.Second),
+		slog.Group("vary", slog.String("accept", "text/html")),
+		slog.Any("cause", errors.New("boom")),
+	)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, map[string]any{
+		"level":             "warn",
+		"message":           "cache failure",
+		"backend":           "leveldb",
+		"entry.key":         "http://example.com",
+		"entry.size":        float64(42),
+		"entry.stale":       true,
+		"entry.age":         float64(2000),
+		"entry.vary.accept": "text/html",
+		"entry.cause":       "boom",
+	}, line)
+}
+
+func TestInstall(t *testing.T) {
+	defer httpcache.SetLogger(slog.Default())
+
+	buf := &bytes.Buffer{}
+	zlog.Install(zerolog.New(buf))
+	httpcache.GetLogger().Error("could not store", slog.Any("error", errors.New("boom")))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, map[string]any{
+		"level":     "error",
+		"message":   "could not store",
+		"component": "httpcache",
+		"error":     "boom",
+	}, line)
+}